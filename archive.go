@@ -0,0 +1,262 @@
+package harlog
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Merge combines HAR logs into a single log whose entries are ordered by
+// startedDateTime. Pages are preserved and page IDs that collide between logs
+// are renamed along with the entries referring to them. The resulting version
+// is the highest version among the logs; logs with a major version other than
+// 1 are rejected.
+func Merge(hars ...*HAR) (*HAR, error) {
+	merged := &HAR{
+		Log: HARLog{
+			Version: "1.2",
+			Creator: HARCreator{
+				Name:    "harlog",
+				Version: "1.0",
+			},
+			Entries: []HAREntry{},
+		},
+	}
+
+	var version string
+	var creators []HARCreator
+	pageIDs := make(map[string]bool)
+
+	for i, har := range hars {
+		if har == nil {
+			continue
+		}
+
+		v := har.Log.Version
+		if v == "" {
+			v = "1.2"
+		}
+		if !strings.HasPrefix(v, "1.") {
+			return nil, fmt.Errorf("unsupported HAR version %q in log %d", har.Log.Version, i)
+		}
+		if version == "" || compareVersion(v, version) > 0 {
+			version = v
+		}
+
+		if !containsCreator(creators, har.Log.Creator) {
+			creators = append(creators, har.Log.Creator)
+		}
+		for _, c := range har.Log.Creators {
+			if !containsCreator(creators, c) {
+				creators = append(creators, c)
+			}
+		}
+		if merged.Log.Browser == nil && har.Log.Browser != nil {
+			browser := *har.Log.Browser
+			merged.Log.Browser = &browser
+		}
+
+		// Rename colliding page IDs so that entries keep their pages
+		renamed := make(map[string]string)
+		for _, page := range har.Log.Pages {
+			id := page.ID
+			for n := 2; pageIDs[id]; n++ {
+				id = page.ID + "_" + strconv.Itoa(n)
+			}
+			pageIDs[id] = true
+			if id != page.ID {
+				renamed[page.ID] = id
+				page.ID = id
+			}
+			merged.Log.Pages = append(merged.Log.Pages, page)
+		}
+
+		for _, entry := range har.Log.Entries {
+			if id, ok := renamed[entry.Pageref]; ok {
+				entry.Pageref = id
+			}
+			merged.Log.Entries = append(merged.Log.Entries, entry)
+		}
+	}
+
+	if version != "" {
+		merged.Log.Version = version
+	}
+	switch len(creators) {
+	case 0:
+	case 1:
+		merged.Log.Creator = creators[0]
+	default:
+		merged.Log.Creators = creators
+	}
+
+	sortEntries(merged.Log.Entries)
+	sort.SliceStable(merged.Log.Pages, func(i, j int) bool {
+		return parseStartedDateTime(merged.Log.Pages[i].StartedDateTime).
+			Before(parseStartedDateTime(merged.Log.Pages[j].StartedDateTime))
+	})
+
+	return merged, nil
+}
+
+// SplitFunc returns the name of the group an entry belongs to
+type SplitFunc func(entry *HAREntry) string
+
+// SplitByHost groups entries by the host of the request URL
+func SplitByHost() SplitFunc {
+	return func(entry *HAREntry) string {
+		u, err := url.Parse(entry.Request.URL)
+		if err != nil || u.Host == "" {
+			return "unknown"
+		}
+		return u.Host
+	}
+}
+
+// SplitByTimeWindow groups entries into consecutive windows of the given
+// duration. Group names are the UTC start time of the window.
+func SplitByTimeWindow(window time.Duration) SplitFunc {
+	return func(entry *HAREntry) string {
+		t := parseStartedDateTime(entry.StartedDateTime)
+		if t.IsZero() {
+			return "unknown"
+		}
+		return t.UTC().Truncate(window).Format("20060102-150405")
+	}
+}
+
+// Split divides a HAR log into logs keyed by the group name returned by fn.
+// Each log keeps the version, creator and browser of the source and the pages
+// referenced by its entries.
+func Split(har *HAR, fn SplitFunc) map[string]*HAR {
+	groups := make(map[string]*HAR)
+	for i := range har.Log.Entries {
+		entry := har.Log.Entries[i]
+		key := fn(&entry)
+
+		group, ok := groups[key]
+		if !ok {
+			group = &HAR{
+				Log: HARLog{
+					Version:  har.Log.Version,
+					Creator:  har.Log.Creator,
+					Browser:  har.Log.Browser,
+					Comment:  har.Log.Comment,
+					Creators: har.Log.Creators,
+					Entries:  []HAREntry{},
+				},
+			}
+			groups[key] = group
+		}
+		group.Log.Entries = append(group.Log.Entries, entry)
+	}
+
+	for _, group := range groups {
+		refs := make(map[string]bool)
+		for _, entry := range group.Log.Entries {
+			refs[entry.Pageref] = true
+		}
+		for _, page := range har.Log.Pages {
+			if refs[page.ID] {
+				group.Log.Pages = append(group.Log.Pages, page)
+			}
+		}
+	}
+
+	return groups
+}
+
+// Dedupe returns a copy of the HAR log without duplicate entries. Entries are
+// duplicates when they have the same method, URL, request body, response
+// status and response body; the first occurrence is kept.
+func Dedupe(har *HAR) *HAR {
+	deduped := *har
+	deduped.Log.Entries = make([]HAREntry, 0, len(har.Log.Entries))
+
+	seen := make(map[string]bool)
+	for _, entry := range har.Log.Entries {
+		key := dedupeKey(&entry)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		deduped.Log.Entries = append(deduped.Log.Entries, entry)
+	}
+
+	return &deduped
+}
+
+func dedupeKey(entry *HAREntry) string {
+	var reqBody string
+	if entry.Request.PostData != nil {
+		reqBody = entry.Request.PostData.Text
+	}
+
+	return strings.Join([]string{
+		entry.Request.Method,
+		entry.Request.URL,
+		hashString(reqBody),
+		strconv.Itoa(entry.Response.Status),
+		hashString(entry.Response.Content.Text),
+	}, "\x00")
+}
+
+func hashString(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// sortEntries orders entries by startedDateTime, keeping the original order of
+// entries with equal times. Entries with unparsable times sort first, in their
+// original order.
+func sortEntries(entries []HAREntry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		return parseStartedDateTime(entries[i].StartedDateTime).
+			Before(parseStartedDateTime(entries[j].StartedDateTime))
+	})
+}
+
+// parseStartedDateTime parses an ISO 8601 timestamp, returning the zero time
+// if it is invalid
+func parseStartedDateTime(s string) time.Time {
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// compareVersion compares dotted version strings numerically
+func compareVersion(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func containsCreator(creators []HARCreator, c HARCreator) bool {
+	for _, v := range creators {
+		if v == c {
+			return true
+		}
+	}
+	return false
+}
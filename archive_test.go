@@ -0,0 +1,183 @@
+package harlog
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestEntry(started, method, rawURL string, status int, body string) HAREntry {
+	return HAREntry{
+		StartedDateTime: started,
+		Request: HARRequest{
+			Method: method,
+			URL:    rawURL,
+		},
+		Response: HARResponse{
+			Status:  status,
+			Content: HARContent{Text: body},
+		},
+	}
+}
+
+func TestMerge(t *testing.T) {
+	browser := &HAR{
+		Log: HARLog{
+			Version: "1.2",
+			Creator: HARCreator{Name: "Firefox", Version: "136.0"},
+			Pages: []HARPage{
+				{ID: "page_1", StartedDateTime: "2025-03-09T11:49:14.000+09:00"},
+			},
+			Entries: []HAREntry{
+				newTestEntry("2025-03-09T11:49:15.000+09:00", "GET", "https://example.com/b", 200, "b"),
+				newTestEntry("2025-03-09T11:49:14.000+09:00", "GET", "https://example.com/a", 200, "a"),
+			},
+		},
+	}
+	browser.Log.Entries[0].Pageref = "page_1"
+	browser.Log.Entries[1].Pageref = "page_1"
+
+	other := &HAR{
+		Log: HARLog{
+			Version: "1.1",
+			Creator: HARCreator{Name: "Chrome", Version: "120"},
+			Pages: []HARPage{
+				{ID: "page_1", StartedDateTime: "2025-03-09T02:49:14.500Z"},
+			},
+			Entries: []HAREntry{
+				newTestEntry("2025-03-09T02:49:14.500Z", "POST", "https://example.com/c", 201, "c"),
+			},
+		},
+	}
+	other.Log.Entries[0].Pageref = "page_1"
+
+	merged, err := Merge(browser, other)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if merged.Log.Version != "1.2" {
+		t.Errorf("expected version 1.2, got %s", merged.Log.Version)
+	}
+	if len(merged.Log.Creators) != 2 {
+		t.Errorf("expected 2 creators, got %v", merged.Log.Creators)
+	}
+
+	wantURLs := []string{"https://example.com/a", "https://example.com/c", "https://example.com/b"}
+	if len(merged.Log.Entries) != len(wantURLs) {
+		t.Fatalf("expected %d entries, got %d", len(wantURLs), len(merged.Log.Entries))
+	}
+	for i, want := range wantURLs {
+		if got := merged.Log.Entries[i].Request.URL; got != want {
+			t.Errorf("entry %d: expected %s, got %s", i, want, got)
+		}
+	}
+
+	if len(merged.Log.Pages) != 2 || merged.Log.Pages[1].ID != "page_1_2" {
+		t.Errorf("colliding page ID was not renamed: %+v", merged.Log.Pages)
+	}
+	if merged.Log.Entries[1].Pageref != "page_1_2" {
+		t.Errorf("pageref was not updated: %s", merged.Log.Entries[1].Pageref)
+	}
+	if browser.Log.Entries[0].Request.URL != "https://example.com/b" {
+		t.Error("source log must not be modified")
+	}
+
+	// A single creator is kept as is
+	merged, err = Merge(browser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if merged.Log.Creator.Name != "Firefox" || len(merged.Log.Creators) != 0 {
+		t.Errorf("creator was not preserved: %+v %+v", merged.Log.Creator, merged.Log.Creators)
+	}
+
+	if _, err := Merge(&HAR{Log: HARLog{Version: "2.0"}}); err == nil {
+		t.Error("expected error for unsupported version")
+	}
+}
+
+func TestSortEntries(t *testing.T) {
+	entries := []HAREntry{
+		newTestEntry("2025-03-09T11:49:15Z", "GET", "https://example.com/b", 200, ""),
+		newTestEntry("yesterday", "GET", "https://example.com/x", 200, ""),
+		newTestEntry("2025-03-09T11:49:14Z", "GET", "https://example.com/a", 200, ""),
+		newTestEntry("", "GET", "https://example.com/y", 200, ""),
+	}
+	sortEntries(entries)
+
+	want := []string{"x", "y", "a", "b"}
+	for i, entry := range entries {
+		if entry.Request.URL != "https://example.com/"+want[i] {
+			t.Errorf("entry %d: expected %s, got %s", i, want[i], entry.Request.URL)
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	har := &HAR{
+		Log: HARLog{
+			Version: "1.2",
+			Creator: HARCreator{Name: "Firefox", Version: "136.0"},
+			Pages:   []HARPage{{ID: "page_1"}, {ID: "page_2"}},
+			Entries: []HAREntry{
+				newTestEntry("2025-03-09T11:49:14Z", "GET", "https://a.example.com/", 200, ""),
+				newTestEntry("2025-03-09T11:50:30Z", "GET", "https://b.example.com/", 200, ""),
+				newTestEntry("2025-03-09T11:49:59Z", "GET", "https://a.example.com/x", 200, ""),
+			},
+		},
+	}
+	har.Log.Entries[0].Pageref = "page_1"
+	har.Log.Entries[1].Pageref = "page_2"
+	har.Log.Entries[2].Pageref = "page_1"
+
+	byHost := Split(har, SplitByHost())
+	if len(byHost) != 2 {
+		t.Fatalf("expected 2 groups, got %d", len(byHost))
+	}
+	a := byHost["a.example.com"]
+	if a == nil || len(a.Log.Entries) != 2 {
+		t.Fatalf("unexpected group: %+v", a)
+	}
+	if len(a.Log.Pages) != 1 || a.Log.Pages[0].ID != "page_1" {
+		t.Errorf("unexpected pages: %+v", a.Log.Pages)
+	}
+	if a.Log.Creator.Name != "Firefox" {
+		t.Errorf("creator was not preserved: %+v", a.Log.Creator)
+	}
+
+	byWindow := Split(har, SplitByTimeWindow(time.Minute))
+	if len(byWindow["20250309-114900"].Log.Entries) != 2 {
+		t.Errorf("unexpected groups: %v", byWindow)
+	}
+	if len(byWindow["20250309-115000"].Log.Entries) != 1 {
+		t.Errorf("unexpected groups: %v", byWindow)
+	}
+}
+
+func TestDedupe(t *testing.T) {
+	har := &HAR{
+		Log: HARLog{
+			Entries: []HAREntry{
+				newTestEntry("2025-03-09T11:49:14Z", "GET", "https://example.com/", 200, "ok"),
+				newTestEntry("2025-03-09T11:49:15Z", "GET", "https://example.com/", 200, "ok"),
+				newTestEntry("2025-03-09T11:49:16Z", "GET", "https://example.com/", 500, "ok"),
+				newTestEntry("2025-03-09T11:49:17Z", "GET", "https://example.com/", 200, "changed"),
+				newTestEntry("2025-03-09T11:49:18Z", "POST", "https://example.com/", 200, "ok"),
+			},
+		},
+	}
+	post := newTestEntry("2025-03-09T11:49:19Z", "POST", "https://example.com/", 200, "ok")
+	post.Request.PostData = &HARPostData{Text: "payload"}
+	har.Log.Entries = append(har.Log.Entries, post)
+
+	deduped := Dedupe(har)
+	if len(deduped.Log.Entries) != 5 {
+		t.Errorf("expected 5 entries, got %d", len(deduped.Log.Entries))
+	}
+	if deduped.Log.Entries[0].StartedDateTime != "2025-03-09T11:49:14Z" {
+		t.Error("first occurrence should be kept")
+	}
+	if len(har.Log.Entries) != 6 {
+		t.Error("source log must not be modified")
+	}
+}
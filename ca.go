@@ -0,0 +1,301 @@
+package harlog
+
+import (
+	"container/list"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// leafValidity is the validity period of certificates issued by CA
+const leafValidity = 7 * 24 * time.Hour
+
+// leafCacheSize is the number of issued certificates kept by CA
+const leafCacheSize = 1024
+
+// CA is a local certificate authority that issues certificates for hosts
+// intercepted by Proxy. Clients of the proxy must trust its certificate.
+// Certificates of the most recently used hosts are cached. A CA is safe for
+// concurrent use.
+type CA struct {
+	cert *x509.Certificate
+	key  crypto.Signer
+	// leafKey is shared by all issued certificates to avoid generating a key
+	// for each host
+	leafKey *ecdsa.PrivateKey
+
+	mu sync.Mutex
+	// cache maps hosts to their elements of lru, which holds leafEntry
+	// values, the most recently used first
+	cache     map[string]*list.Element
+	lru       *list.List
+	cacheSize int
+}
+
+type leafEntry struct {
+	host string
+	cert *tls.Certificate
+}
+
+// NewCA generates a CA with a new ECDSA P-256 key, valid for 10 years
+func NewCA() (*CA, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate CA key: %w", err)
+	}
+	serial, err := randomSerial()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "harlog CA", Organization: []string{"harlog"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.AddDate(10, 0, 0),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CA certificate: %w", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CA certificate: %w", err)
+	}
+	return newCA(cert, key)
+}
+
+// LoadCA loads a CA from a PEM encoded certificate and private key. PKCS#8,
+// SEC 1 (EC) and PKCS#1 (RSA) keys are supported.
+func LoadCA(certPEM, keyPEM []byte) (*CA, error) {
+	block, _ := pem.Decode(certPEM)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, errors.New("no PEM encoded CA certificate found")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CA certificate: %w", err)
+	}
+	if !cert.IsCA {
+		return nil, errors.New("certificate is not a CA certificate")
+	}
+
+	block, _ = pem.Decode(keyPEM)
+	if block == nil {
+		return nil, errors.New("no PEM encoded CA key found")
+	}
+	key, err := parsePrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	pub, ok := key.Public().(interface{ Equal(crypto.PublicKey) bool })
+	if !ok || !pub.Equal(cert.PublicKey) {
+		return nil, errors.New("CA key does not match the certificate")
+	}
+	return newCA(cert, key)
+}
+
+// LoadCAFiles loads a CA from PEM files as written by WriteFiles
+func LoadCAFiles(certFile, keyFile string) (*CA, error) {
+	certPEM, err := os.ReadFile(certFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA certificate: %w", err)
+	}
+	keyPEM, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA key: %w", err)
+	}
+	return LoadCA(certPEM, keyPEM)
+}
+
+func newCA(cert *x509.Certificate, key crypto.Signer) (*CA, error) {
+	leafKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate certificate key: %w", err)
+	}
+	return &CA{
+		cert:      cert,
+		key:       key,
+		leafKey:   leafKey,
+		cache:     make(map[string]*list.Element),
+		lru:       list.New(),
+		cacheSize: leafCacheSize,
+	}, nil
+}
+
+func parsePrivateKey(der []byte) (crypto.Signer, error) {
+	if key, err := x509.ParsePKCS8PrivateKey(der); err == nil {
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, errors.New("unsupported CA key type")
+		}
+		return signer, nil
+	}
+	if key, err := x509.ParseECPrivateKey(der); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+	return nil, errors.New("failed to parse CA key")
+}
+
+// Certificate returns the CA certificate
+func (ca *CA) Certificate() *x509.Certificate {
+	return ca.cert
+}
+
+// CertificatePEM returns the PEM encoded CA certificate, which clients of
+// the proxy install as trusted
+func (ca *CA) CertificatePEM() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw})
+}
+
+// KeyPEM returns the PEM encoded PKCS#8 private key of the CA
+func (ca *CA) KeyPEM() ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(ca.key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode CA key: %w", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// WriteFiles writes the PEM encoded certificate and private key. The key file
+// is readable only by the owner.
+func (ca *CA) WriteFiles(certFile, keyFile string) error {
+	keyPEM, err := ca.KeyPEM()
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(certFile, ca.CertificatePEM(), 0644); err != nil {
+		return fmt.Errorf("failed to write CA certificate: %w", err)
+	}
+	if err := os.WriteFile(keyFile, keyPEM, 0600); err != nil {
+		return fmt.Errorf("failed to write CA key: %w", err)
+	}
+	return nil
+}
+
+// CertPool returns a pool containing only the CA certificate, for clients
+// such as tests that trust the proxy
+func (ca *CA) CertPool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	return pool
+}
+
+// issue returns a certificate for host, which is a host name or an IP
+// address, from the cache or newly signed
+func (ca *CA) issue(host string) (*tls.Certificate, error) {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if !validHost(host) {
+		return nil, fmt.Errorf("invalid host name for certificate: %q", host)
+	}
+
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+
+	now := time.Now()
+	if elem, ok := ca.cache[host]; ok {
+		cert := elem.Value.(*leafEntry).cert
+		if now.Add(time.Hour).Before(cert.Leaf.NotAfter) {
+			ca.lru.MoveToFront(elem)
+			return cert, nil
+		}
+		ca.lru.Remove(elem)
+		delete(ca.cache, host)
+	}
+
+	serial, err := randomSerial()
+	if err != nil {
+		return nil, err
+	}
+	notAfter := now.Add(leafValidity)
+	if notAfter.After(ca.cert.NotAfter) {
+		notAfter = ca.cert.NotAfter
+	}
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: host, Organization: []string{"harlog"}},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		template.IPAddresses = []net.IP{ip}
+	} else {
+		template.DNSNames = []string{host}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, ca.leafKey.Public(), ca.key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to issue certificate for %s: %w", host, err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate for %s: %w", host, err)
+	}
+
+	cert := &tls.Certificate{
+		Certificate: [][]byte{der, ca.cert.Raw},
+		PrivateKey:  ca.leafKey,
+		Leaf:        leaf,
+	}
+	ca.cache[host] = ca.lru.PushFront(&leafEntry{host: host, cert: cert})
+	for ca.lru.Len() > ca.cacheSize {
+		oldest := ca.lru.Back()
+		ca.lru.Remove(oldest)
+		delete(ca.cache, oldest.Value.(*leafEntry).host)
+	}
+	return cert, nil
+}
+
+// validHost reports whether host is an IP address or a DNS name that a
+// certificate can be issued for
+func validHost(host string) bool {
+	if net.ParseIP(host) != nil {
+		return true
+	}
+	if host == "" || len(host) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(host, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func randomSerial() (*big.Int, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate serial number: %w", err)
+	}
+	return serial, nil
+}
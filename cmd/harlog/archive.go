@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/m-mizutani/harlog"
+)
+
+func runMerge(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("merge", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	har, err := loadHAR(fs.Args())
+	if err != nil {
+		return err
+	}
+	return writeHAR(stdout, har)
+}
+
+func runDedupe(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("dedupe", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	har, err := loadHAR(fs.Args())
+	if err != nil {
+		return err
+	}
+	return writeHAR(stdout, harlog.Dedupe(har))
+}
+
+func runSplit(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("split", flag.ContinueOnError)
+	by := fs.String("by", "host", "split key: host or window")
+	window := fs.Duration("window", 0, "time window size when splitting by window, e.g. 5m")
+	outDir := fs.String("d", ".", "output directory")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var fn harlog.SplitFunc
+	switch *by {
+	case "host":
+		fn = harlog.SplitByHost()
+	case "window":
+		if *window <= 0 {
+			return fmt.Errorf("-window must be positive when splitting by window")
+		}
+		fn = harlog.SplitByTimeWindow(*window)
+	default:
+		return fmt.Errorf("unknown split key: %s (expected host or window)", *by)
+	}
+
+	har, err := loadHAR(fs.Args())
+	if err != nil {
+		return err
+	}
+
+	groups := harlog.Split(har, fn)
+	keys := make([]string, 0, len(groups))
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	if err := os.MkdirAll(*outDir, 0750); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	for _, key := range keys {
+		var buf bytes.Buffer
+		if err := writeHAR(&buf, groups[key]); err != nil {
+			return err
+		}
+
+		filename := filepath.Join(*outDir, splitFileName(key))
+		if err := os.WriteFile(filename, buf.Bytes(), 0600); err != nil {
+			return fmt.Errorf("failed to write %s: %w", filename, err)
+		}
+		fmt.Fprintln(stdout, filename)
+	}
+
+	return nil
+}
+
+// splitFileName converts a group name to a file name
+func splitFileName(key string) string {
+	replacer := strings.NewReplacer("/", "-", "\\", "-", ":", "-", " ", "-")
+	return replacer.Replace(key) + ".har"
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/m-mizutani/harlog/hardiff"
+)
+
+// errDifferences is returned by diff when the captures differ, so that the
+// command exits with a non-zero status in CI
+var errDifferences = errors.New("captures differ")
+
+func runDiff(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	format := fs.String("o", "text", "output format: text or json")
+	ignore := fs.String("ignore-header", "", "additional response headers to ignore (comma separated)")
+	latencyRatio := fs.Float64("latency-ratio", 0.5, "relative latency increase reported as a regression")
+	latencyMin := fs.Duration("latency-min", 100*time.Millisecond, "minimum latency increase reported as a regression")
+	values := fs.Bool("values", false, "report changed JSON values in addition to structural changes")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return fmt.Errorf("usage: harlog diff [options] <base> <target>")
+	}
+
+	base, err := loadHAR(fs.Args()[:1])
+	if err != nil {
+		return err
+	}
+	target, err := loadHAR(fs.Args()[1:])
+	if err != nil {
+		return err
+	}
+
+	opts := []hardiff.Option{hardiff.WithLatencyThreshold(*latencyRatio, *latencyMin)}
+	if *ignore != "" {
+		opts = append(opts, hardiff.WithIgnoredHeaders(strings.Split(*ignore, ",")...))
+	}
+	if *values {
+		opts = append(opts, hardiff.WithBodyValues())
+	}
+	result := hardiff.Compare(base, target, opts...)
+
+	switch *format {
+	case "text":
+		if err := result.WriteText(stdout); err != nil {
+			return err
+		}
+	case "json":
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(result); err != nil {
+			return fmt.Errorf("failed to encode result: %w", err)
+		}
+	default:
+		return fmt.Errorf("unknown output format: %s (expected text or json)", *format)
+	}
+
+	if result.HasChanges() {
+		return errDifferences
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/m-mizutani/harlog"
+	"github.com/m-mizutani/harlog/harexport"
+)
+
+func runExport(args []string, stdout io.Writer) error {
+	var filter entryFilter
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := fs.String("f", "curl", "export format: curl, httpie, go, postman, insomnia or k6")
+	index := fs.Int("i", -1, "index of the entry among the filtered entries (default: all entries)")
+	var collection collectionFlags
+	collection.register(fs)
+	filter.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := filter.validate(); err != nil {
+		return err
+	}
+
+	var convert func(req *http.Request) (string, error)
+	var write func(w io.Writer, har *harlog.HAR, opts ...harexport.Option) error
+	switch *format {
+	case "curl":
+		convert = harlog.CurlCommand
+	case "httpie":
+		convert = harlog.HTTPieCommand
+	case "go":
+		convert = harlog.GoCode
+	case "postman":
+		write = harexport.WritePostman
+	case "insomnia":
+		write = harexport.WriteInsomnia
+	case "k6":
+		write = harexport.WriteK6
+	default:
+		return fmt.Errorf("unknown export format: %s (expected curl, httpie, go, postman, insomnia or k6)", *format)
+	}
+	opts, err := collection.options()
+	if err != nil {
+		return err
+	}
+
+	har, err := loadHAR(fs.Args())
+	if err != nil {
+		return err
+	}
+
+	entries := filter.apply(har.Log.Entries)
+	if *index >= 0 {
+		if *index >= len(entries) {
+			return fmt.Errorf("entry index %d out of range (%d entries)", *index, len(entries))
+		}
+		entries = entries[*index : *index+1]
+	}
+
+	if write != nil {
+		har.Log.Entries = entries
+		return write(stdout, har, opts...)
+	}
+
+	for i := range entries {
+		msg, err := harlog.ConvertEntry(&entries[i])
+		if err != nil {
+			return err
+		}
+		out, err := convert(msg.Request)
+		if err != nil {
+			return err
+		}
+
+		if i > 0 {
+			fmt.Fprintln(stdout)
+		}
+		fmt.Fprintln(stdout, out)
+	}
+
+	return nil
+}
+
+// collectionFlags holds the options of the postman, insomnia and k6 formats
+type collectionFlags struct {
+	name        string
+	group       string
+	vars        bool
+	authHeaders string
+	examples    bool
+}
+
+func (c *collectionFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&c.name, "name", "harlog", "name of the collection (postman, insomnia, k6)")
+	fs.StringVar(&c.group, "group", "", "group requests into folders by host and/or path, e.g. host,path (postman, insomnia, k6)")
+	fs.BoolVar(&c.vars, "vars", false, "replace base URLs with variables (postman, insomnia, k6)")
+	fs.StringVar(&c.authHeaders, "auth-header", "", "request headers to replace with variables, e.g. Authorization (comma separated; postman, insomnia, k6)")
+	fs.BoolVar(&c.examples, "examples", false, "add recorded responses as examples (postman)")
+}
+
+func (c *collectionFlags) options() ([]harexport.Option, error) {
+	opts := []harexport.Option{harexport.WithName(c.name)}
+
+	var groups []harexport.GroupFunc
+	for _, by := range strings.Split(c.group, ",") {
+		switch strings.TrimSpace(by) {
+		case "":
+		case "host":
+			groups = append(groups, harexport.GroupByHost())
+		case "path":
+			groups = append(groups, harexport.GroupByPath())
+		default:
+			return nil, fmt.Errorf("unknown group: %s (expected host or path)", by)
+		}
+	}
+	if len(groups) > 0 {
+		opts = append(opts, harexport.WithGroupBy(groups...))
+	}
+
+	if c.vars {
+		opts = append(opts, harexport.WithBaseURLVariables())
+	}
+	if c.authHeaders != "" {
+		opts = append(opts, harexport.WithAuthVariables(strings.Split(c.authHeaders, ",")...))
+	}
+	if c.examples {
+		opts = append(opts, harexport.WithExamples())
+	}
+	return opts, nil
+}
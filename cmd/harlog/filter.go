@@ -0,0 +1,174 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/m-mizutani/harlog"
+)
+
+// entryFilter selects HAR entries by request and response attributes
+type entryFilter struct {
+	method      string
+	status      string
+	host        string
+	path        string
+	minDuration time.Duration
+	maxDuration time.Duration
+
+	graphQLOperation string
+	graphQLType      string
+	graphQLErrors    bool
+}
+
+func (f *entryFilter) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.method, "method", "", "HTTP method (comma separated)")
+	fs.StringVar(&f.status, "status", "", "status code, class or range, e.g. 404, 5xx, 200-299 (comma separated)")
+	fs.StringVar(&f.host, "host", "", "host name glob pattern, e.g. *.example.com")
+	fs.StringVar(&f.path, "path", "", "URL path glob pattern, e.g. /api/*/users")
+	fs.DurationVar(&f.minDuration, "min-duration", 0, "minimum total time of the entry")
+	fs.DurationVar(&f.maxDuration, "max-duration", 0, "maximum total time of the entry")
+	fs.StringVar(&f.graphQLOperation, "graphql-operation", "", "GraphQL operation name glob pattern, e.g. Get*")
+	fs.StringVar(&f.graphQLType, "graphql-type", "", "GraphQL operation type: query, mutation or subscription (comma separated)")
+	fs.BoolVar(&f.graphQLErrors, "graphql-errors", false, "only GraphQL responses with errors")
+}
+
+func (f *entryFilter) validate() error {
+	if f.status != "" {
+		if _, err := matchStatus(f.status, 0); err != nil {
+			return err
+		}
+	}
+	for _, pattern := range []string{f.host, f.path, f.graphQLOperation} {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+func (f *entryFilter) match(entry *harlog.HAREntry) bool {
+	if f.method != "" && !containsFold(strings.Split(f.method, ","), entry.Request.Method) {
+		return false
+	}
+
+	if f.status != "" {
+		if ok, _ := matchStatus(f.status, entry.Response.Status); !ok {
+			return false
+		}
+	}
+
+	if f.host != "" || f.path != "" {
+		u, err := url.Parse(entry.Request.URL)
+		if err != nil {
+			return false
+		}
+		if f.host != "" {
+			if ok, _ := path.Match(strings.ToLower(f.host), strings.ToLower(u.Hostname())); !ok {
+				return false
+			}
+		}
+		if f.path != "" {
+			if ok, _ := path.Match(f.path, u.Path); !ok {
+				return false
+			}
+		}
+	}
+
+	if f.graphQLOperation != "" || f.graphQLType != "" || f.graphQLErrors {
+		op := entry.GraphQL
+		if op == nil {
+			return false
+		}
+		if f.graphQLOperation != "" {
+			if ok, _ := path.Match(f.graphQLOperation, op.OperationName); !ok {
+				return false
+			}
+		}
+		if f.graphQLType != "" && !containsFold(strings.Split(f.graphQLType, ","), op.OperationType) {
+			return false
+		}
+		if f.graphQLErrors && op.Errors == 0 {
+			return false
+		}
+	}
+
+	duration := entryDuration(entry)
+	if f.minDuration > 0 && duration < f.minDuration {
+		return false
+	}
+	if f.maxDuration > 0 && duration > f.maxDuration {
+		return false
+	}
+
+	return true
+}
+
+func (f *entryFilter) apply(entries []harlog.HAREntry) []harlog.HAREntry {
+	matched := make([]harlog.HAREntry, 0, len(entries))
+	for i := range entries {
+		if f.match(&entries[i]) {
+			matched = append(matched, entries[i])
+		}
+	}
+	return matched
+}
+
+// matchStatus reports whether status matches expr, a comma separated list of
+// codes ("404"), classes ("5xx") and ranges ("200-299")
+func matchStatus(expr string, status int) (bool, error) {
+	matched := false
+	for _, term := range strings.Split(expr, ",") {
+		term = strings.ToLower(strings.TrimSpace(term))
+
+		var lo, hi int
+		switch {
+		case len(term) == 3 && strings.HasSuffix(term, "xx"):
+			class, err := strconv.Atoi(term[:1])
+			if err != nil {
+				return false, fmt.Errorf("invalid status class: %s", term)
+			}
+			lo, hi = class*100, class*100+99
+
+		case strings.Contains(term, "-"):
+			from, to, _ := strings.Cut(term, "-")
+			var err error
+			if lo, err = strconv.Atoi(from); err != nil {
+				return false, fmt.Errorf("invalid status range: %s", term)
+			}
+			if hi, err = strconv.Atoi(to); err != nil {
+				return false, fmt.Errorf("invalid status range: %s", term)
+			}
+
+		default:
+			code, err := strconv.Atoi(term)
+			if err != nil {
+				return false, fmt.Errorf("invalid status code: %s", term)
+			}
+			lo, hi = code, code
+		}
+
+		if lo <= status && status <= hi {
+			matched = true
+		}
+	}
+	return matched, nil
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(strings.TrimSpace(v), s) {
+			return true
+		}
+	}
+	return false
+}
+
+func entryDuration(entry *harlog.HAREntry) time.Duration {
+	return time.Duration(entry.Time * float64(time.Millisecond))
+}
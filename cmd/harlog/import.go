@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/m-mizutani/harlog/harimport"
+)
+
+func runImport(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	from := fs.String("from", "", "source format: dump, postman, curl-trace or mitmproxy")
+	scheme := fs.String("scheme", "http", "URL scheme of requests with only a path (dump and curl-trace)")
+	strict := fs.Bool("strict", false, "fail if any item cannot be converted")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("import requires exactly one input file (or - for stdin)")
+	}
+
+	var importer func(r io.Reader) (*harimport.Result, error)
+	switch *from {
+	case "dump":
+		importer = func(r io.Reader) (*harimport.Result, error) {
+			return harimport.HTTPDump(r, harimport.WithScheme(*scheme))
+		}
+	case "postman":
+		importer = harimport.Postman
+	case "curl-trace":
+		importer = func(r io.Reader) (*harimport.Result, error) {
+			return harimport.CurlTrace(r, harimport.WithScheme(*scheme))
+		}
+	case "mitmproxy":
+		importer = harimport.Mitmproxy
+	default:
+		return fmt.Errorf("unknown import format: %q (expected dump, postman, curl-trace or mitmproxy)", *from)
+	}
+
+	input := os.Stdin
+	if path := fs.Arg(0); path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %w", path, err)
+		}
+		defer f.Close()
+		input = f
+	}
+
+	result, err := importer(input)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range result.Skipped {
+		fmt.Fprintln(os.Stderr, "skipped", item)
+	}
+	if *strict && len(result.Skipped) > 0 {
+		return fmt.Errorf("%d items could not be converted", len(result.Skipped))
+	}
+
+	return writeHAR(stdout, result.HAR)
+}
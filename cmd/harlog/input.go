@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/m-mizutani/harlog"
+)
+
+// loadHAR reads all given files and directories and merges them into a single
+// HAR log ordered by startedDateTime. "-" reads stdin.
+func loadHAR(paths []string) (*harlog.HAR, error) {
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no input file or directory given")
+	}
+
+	var hars []*harlog.HAR
+	for _, path := range paths {
+		files, err := expandPath(path)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, file := range files {
+			har, err := readHAR(file)
+			if err != nil {
+				return nil, err
+			}
+			hars = append(hars, har)
+		}
+	}
+
+	return harlog.Merge(hars...)
+}
+
+// expandPath returns the HAR and NDJSON files at path. Directories are walked recursively.
+func expandPath(path string) ([]string, error) {
+	if path == "-" {
+		return []string{path}, nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	var files []string
+	err = filepath.WalkDir(path, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && isHARFile(p) {
+			files = append(files, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk directory %s: %w", path, err)
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+// isHARFile reports whether path is a HAR or NDJSON file, possibly gzip or
+// Zstandard compressed
+func isHARFile(path string) bool {
+	path = strings.ToLower(path)
+	path = strings.TrimSuffix(strings.TrimSuffix(path, ".gz"), ".zst")
+	switch filepath.Ext(path) {
+	case ".har", ".ndjson", ".jsonl":
+		return true
+	default:
+		return false
+	}
+}
+
+func readHAR(path string) (*harlog.HAR, error) {
+	if path != "-" {
+		har, err := harlog.ReadHARFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return har, nil
+	}
+
+	data, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stdin: %w", err)
+	}
+	return harlog.ReadHARData(data)
+}
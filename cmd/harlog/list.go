@@ -0,0 +1,35 @@
+package main
+
+import (
+	"flag"
+	"io"
+)
+
+func runList(args []string, stdout io.Writer) error {
+	return listEntries("list", formatTable, args, stdout)
+}
+
+func runFilter(args []string, stdout io.Writer) error {
+	return listEntries("filter", formatHAR, args, stdout)
+}
+
+func listEntries(name, defaultFormat string, args []string, stdout io.Writer) error {
+	var filter entryFilter
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	format := fs.String("o", defaultFormat, "output format: table, jsonl or har")
+	filter.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := filter.validate(); err != nil {
+		return err
+	}
+
+	har, err := loadHAR(fs.Args())
+	if err != nil {
+		return err
+	}
+
+	har.Log.Entries = filter.apply(har.Log.Entries)
+	return writeEntries(stdout, *format, har)
+}
@@ -0,0 +1,72 @@
+// Command harlog inspects and queries HAR files written by the harlog library
+// or exported from browsers.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// command represents a subcommand of the CLI
+type command struct {
+	name    string
+	summary string
+	run     func(args []string, stdout io.Writer) error
+}
+
+func commands() []command {
+	return []command{
+		{name: "list", summary: "list entries as a table", run: runList},
+		{name: "show", summary: "show a single entry with headers and body", run: runShow},
+		{name: "filter", summary: "filter entries and write them as HAR", run: runFilter},
+		{name: "merge", summary: "merge HAR files into one ordered by start time", run: runMerge},
+		{name: "split", summary: "split HAR files by host or time window", run: runSplit},
+		{name: "dedupe", summary: "remove duplicate entries", run: runDedupe},
+		{name: "export", summary: "export requests as curl, httpie or Go code", run: runExport},
+		{name: "openapi", summary: "generate an OpenAPI 3.1 document from traffic", run: runOpenAPI},
+		{name: "diff", summary: "compare two captures of the same scenario", run: runDiff},
+		{name: "replay", summary: "re-send captured requests to another server", run: runReplay},
+		{name: "proxy", summary: "run a forward proxy that records traffic as HAR", run: runProxy},
+		{name: "reverse-proxy", summary: "run a reverse proxy in front of a service that records traffic as HAR", run: runReverseProxy},
+		{name: "import", summary: "convert HTTP dumps, Postman, curl traces or mitmproxy flows to HAR", run: runImport},
+	}
+}
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout, os.Stderr); err != nil {
+		if !errors.Is(err, flag.ErrHelp) && !errors.Is(err, errDifferences) {
+			fmt.Fprintln(os.Stderr, "error:", err)
+		}
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdout, stderr io.Writer) error {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "-help" || args[0] == "help" {
+		printUsage(stderr)
+		return flag.ErrHelp
+	}
+
+	for _, cmd := range commands() {
+		if cmd.name == args[0] {
+			return cmd.run(args[1:], stdout)
+		}
+	}
+
+	printUsage(stderr)
+	return fmt.Errorf("unknown command: %s", args[0])
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: harlog <command> [options] <file or directory>...")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, cmd := range commands() {
+		fmt.Fprintf(w, "  %-14s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run 'harlog <command> -h' for command options.")
+}
@@ -0,0 +1,391 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/m-mizutani/harlog"
+)
+
+const testHARDir = "../../testdata"
+
+func runCommand(t *testing.T, args ...string) string {
+	t.Helper()
+
+	var stdout, stderr bytes.Buffer
+	if err := run(args, &stdout, &stderr); err != nil {
+		t.Fatalf("harlog %s: %v\n%s", strings.Join(args, " "), err, stderr.String())
+	}
+	return stdout.String()
+}
+
+func TestList(t *testing.T) {
+	out := runCommand(t, "list", testHARDir)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected header and 1 entry, got:\n%s", out)
+	}
+	if !strings.Contains(lines[1], "GET") || !strings.Contains(lines[1], "https://github.com/m-mizutani/harlog") {
+		t.Errorf("unexpected row: %s", lines[1])
+	}
+
+	out = runCommand(t, "list", "-status", "4xx,5xx", testHARDir)
+	if lines := strings.Split(strings.TrimSpace(out), "\n"); len(lines) != 1 {
+		t.Errorf("expected no entries, got:\n%s", out)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	out := runCommand(t, "filter", "-method", "get", "-host", "*.com", "-path", "/*/harlog", "-min-duration", "500ms", testHARDir)
+
+	var har harlog.HAR
+	if err := json.Unmarshal([]byte(out), &har); err != nil {
+		t.Fatal(err)
+	}
+	if len(har.Log.Entries) != 1 {
+		t.Fatalf("expected 1 entry, got %d", len(har.Log.Entries))
+	}
+
+	out = runCommand(t, "filter", "-o", "jsonl", "-max-duration", "100ms", testHARDir)
+	if out != "" {
+		t.Errorf("expected no entries, got:\n%s", out)
+	}
+
+	// JSON Lines output is read back as input
+	file := filepath.Join(t.TempDir(), "entries.ndjson")
+	out = runCommand(t, "filter", "-o", "jsonl", "-method", "get", testHARDir)
+	if err := os.WriteFile(file, []byte(out), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out = runCommand(t, "merge", filepath.Dir(file))
+	if err := json.Unmarshal([]byte(out), &har); err != nil {
+		t.Fatal(err)
+	}
+	if len(har.Log.Entries) == 0 {
+		t.Error("expected entries from NDJSON input")
+	}
+}
+
+func TestFilter_GraphQL(t *testing.T) {
+	har := harlog.HAR{Log: harlog.HARLog{Version: "1.2", Entries: []harlog.HAREntry{
+		{Request: harlog.HARRequest{Method: "POST", URL: "https://example.com/graphql"},
+			GraphQL: &harlog.HARGraphQL{OperationName: "GetUser", OperationType: "query"}},
+		{Request: harlog.HARRequest{Method: "POST", URL: "https://example.com/graphql"},
+			GraphQL: &harlog.HARGraphQL{OperationName: "CreateUser", OperationType: "mutation", Errors: 2}},
+		{Request: harlog.HARRequest{Method: "GET", URL: "https://example.com/users"}},
+	}}}
+	data, err := json.Marshal(har)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "graphql.har"), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		args []string
+		want int
+	}{
+		{args: []string{"-graphql-type", "query,subscription"}, want: 1},
+		{args: []string{"-graphql-operation", "*User"}, want: 2},
+		{args: []string{"-graphql-errors"}, want: 1},
+		{args: []string{"-graphql-operation", "Get*", "-graphql-errors"}, want: 0},
+	} {
+		out := runCommand(t, append(append([]string{"filter", "-o", "jsonl"}, tt.args...), dir)...)
+		if got := strings.Count(out, "\n"); got != tt.want {
+			t.Errorf("%v: expected %d entries, got:\n%s", tt.args, tt.want, out)
+		}
+	}
+
+	out := runCommand(t, "list", "-graphql-errors", dir)
+	if !strings.Contains(out, "https://example.com/graphql (mutation CreateUser, 2 errors)") {
+		t.Errorf("expected operation in the URL column, got:\n%s", out)
+	}
+}
+
+func TestShow(t *testing.T) {
+	out := runCommand(t, "show", "-i", "0", testHARDir)
+	for _, want := range []string{
+		"GET https://github.com/m-mizutani/harlog HTTP/2",
+		"User-Agent: Mozilla/5.0",
+		"HTTP/2 200 OK",
+		"Content-Type: text/html; charset=utf-8",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output does not contain %q", want)
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	if err := run([]string{"show", "-i", "1", testHARDir}, &stdout, &stderr); err == nil {
+		t.Error("expected error for out of range index")
+	}
+}
+
+func TestExport(t *testing.T) {
+	out := runCommand(t, "export", "-f", "curl", "-i", "0", testHARDir)
+	if !strings.HasPrefix(out, "curl https://github.com/m-mizutani/harlog") {
+		t.Errorf("unexpected curl command:\n%s", out)
+	}
+
+	out = runCommand(t, "export", "-f", "go", testHARDir)
+	if !strings.Contains(out, `http.NewRequest(http.MethodGet, "https://github.com/m-mizutani/harlog", nil)`) {
+		t.Errorf("unexpected Go code:\n%s", out)
+	}
+
+	out = runCommand(t, "export", "-f", "postman", "-group", "host", "-vars", "-name", "GitHub", testHARDir)
+	var collection map[string]any
+	if err := json.Unmarshal([]byte(out), &collection); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, `"raw": "{{baseUrl}}/m-mizutani/harlog"`) || !strings.Contains(out, `"name": "github.com"`) {
+		t.Errorf("unexpected Postman collection:\n%s", out)
+	}
+
+	out = runCommand(t, "export", "-f", "k6", testHARDir)
+	if !strings.Contains(out, `http.request("GET", "https://github.com/m-mizutani/harlog", null, {`) {
+		t.Errorf("unexpected k6 script:\n%s", out)
+	}
+}
+
+func TestOpenAPI(t *testing.T) {
+	out := runCommand(t, "openapi", "-title", "GitHub", testHARDir)
+
+	var doc map[string]any
+	if err := json.Unmarshal([]byte(out), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc["openapi"] != "3.1.0" {
+		t.Errorf("unexpected document:\n%s", out)
+	}
+	if _, ok := doc["paths"].(map[string]any)["/m-mizutani/harlog"]; !ok {
+		t.Errorf("path not found:\n%s", out)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	dir := t.TempDir()
+	base, target := filepath.Join(dir, "base.har"), filepath.Join(dir, "target.har")
+	writeTestHAR(t, base, testEntry("2025-03-09T11:49:14Z", "https://example.com/users/1"))
+	writeTestHAR(t, target, testEntry("2025-03-09T11:49:14Z", "https://example.com/users/2"))
+
+	if out := runCommand(t, "diff", base, target); !strings.Contains(out, "0 added, 0 removed, 0 changed") {
+		t.Errorf("unexpected output:\n%s", out)
+	}
+
+	writeTestHAR(t, target, testEntry("2025-03-09T11:49:14Z", "https://example.com/orders/1"))
+	var stdout, stderr bytes.Buffer
+	err := run([]string{"diff", base, target}, &stdout, &stderr)
+	if !errors.Is(err, errDifferences) {
+		t.Fatalf("expected errDifferences, got %v", err)
+	}
+	if !strings.Contains(stdout.String(), "1 added, 1 removed, 0 changed") {
+		t.Errorf("unexpected output:\n%s", stdout.String())
+	}
+}
+
+func TestReplay(t *testing.T) {
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		if r.URL.Path != "/users/1" {
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	file := filepath.Join(t.TempDir(), "capture.har")
+	writeTestHAR(t, file,
+		testEntry("2025-03-09T11:49:14Z", "https://example.com/users/1"),
+		testEntry("2025-03-09T11:49:15Z", "https://example.com/users/2"),
+	)
+
+	out := runCommand(t, "replay", "-target", server.URL, "-H", "Authorization: Bearer fresh", "-path", "/users/1", file)
+	if !strings.Contains(out, "1 sent, 0 errors, 0 status mismatches") {
+		t.Errorf("unexpected output:\n%s", out)
+	}
+	if auth != "Bearer fresh" {
+		t.Errorf("header was not overridden: %q", auth)
+	}
+
+	var stdout, stderr bytes.Buffer
+	err := run([]string{"replay", "-target", server.URL, file}, &stdout, &stderr)
+	if !errors.Is(err, errDifferences) {
+		t.Fatalf("expected errDifferences, got %v", err)
+	}
+	if !strings.Contains(stdout.String(), "status: 200 -> 404") {
+		t.Errorf("unexpected output:\n%s", stdout.String())
+	}
+}
+
+func TestImport(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "dump.txt")
+	dump := "GET /users/1 HTTP/1.1\r\nHost: example.com\r\n\r\n" +
+		"HTTP/1.1 200 OK\r\nContent-Type: application/json\r\nContent-Length: 9\r\n\r\n{\"id\": 1}\n" +
+		"HTTP/1.1 204 No Content\r\n\r\n"
+	if err := os.WriteFile(file, []byte(dump), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	out := runCommand(t, "import", "-from", "dump", "-scheme", "https", file)
+	har, err := harlog.ReadHARData([]byte(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(har.Log.Entries) != 1 || har.Log.Entries[0].Request.URL != "https://example.com/users/1" {
+		t.Errorf("unexpected HAR:\n%s", out)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if err := run([]string{"import", "-from", "dump", "-strict", file}, &stdout, &stderr); err == nil {
+		t.Error("expected error for skipped items with -strict")
+	}
+	if err := run([]string{"import", "-from", "pcap", file}, &stdout, &stderr); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
+
+func TestProxy_InvalidFlags(t *testing.T) {
+	for _, args := range [][]string{
+		{"proxy", "-compress", "bz2"},
+		{"proxy", "-upstream", "socks5://localhost:1080"},
+		{"reverse-proxy"},
+		{"reverse-proxy", "-target", "ftp://example.com"},
+		{"reverse-proxy", "-target", "http://example.com", "-compress", "bz2"},
+	} {
+		var stdout, stderr bytes.Buffer
+		if err := run(args, &stdout, &stderr); err == nil {
+			t.Errorf("harlog %s: expected error", strings.Join(args, " "))
+		}
+	}
+}
+
+func TestLoadOrCreateCA(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "ca.pem"), filepath.Join(dir, "ca-key.pem")
+
+	var stdout bytes.Buffer
+	created, err := loadOrCreateCA(certFile, keyFile, &stdout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(stdout.String(), "generated CA certificate") {
+		t.Errorf("expected generation message, got %q", stdout.String())
+	}
+
+	stdout.Reset()
+	loaded, err := loadOrCreateCA(certFile, keyFile, &stdout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("expected no output when loading, got %q", stdout.String())
+	}
+	if !loaded.Certificate().Equal(created.Certificate()) {
+		t.Error("expected the generated CA to be loaded")
+	}
+}
+
+func TestMatchStatus(t *testing.T) {
+	testCases := []struct {
+		expr   string
+		status int
+		want   bool
+	}{
+		{"200", 200, true},
+		{"200", 201, false},
+		{"2xx", 204, true},
+		{"4xx,5xx", 503, true},
+		{"4xx,5xx", 302, false},
+		{"300-399", 304, true},
+		{"300-399", 400, false},
+	}
+
+	for _, tc := range testCases {
+		got, err := matchStatus(tc.expr, tc.status)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tc.want {
+			t.Errorf("matchStatus(%q, %d): expected %v, got %v", tc.expr, tc.status, tc.want, got)
+		}
+	}
+
+	if _, err := matchStatus("abc", 200); err == nil {
+		t.Error("expected error for invalid expression")
+	}
+}
+
+func writeTestHAR(t *testing.T, path string, entries ...harlog.HAREntry) {
+	t.Helper()
+
+	data, err := json.Marshal(harlog.HAR{
+		Log: harlog.HARLog{
+			Version: "1.2",
+			Creator: harlog.HARCreator{Name: "harlog", Version: "1.0"},
+			Entries: entries,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testEntry(started, rawURL string) harlog.HAREntry {
+	return harlog.HAREntry{
+		StartedDateTime: started,
+		Request:         harlog.HARRequest{Method: "GET", URL: rawURL},
+		Response:        harlog.HARResponse{Status: 200},
+	}
+}
+
+func TestMergeSplitDedupe(t *testing.T) {
+	inDir := t.TempDir()
+	writeTestHAR(t, filepath.Join(inDir, "1.har"), testEntry("2025-03-09T11:49:15Z", "https://a.example.com/2"))
+	writeTestHAR(t, filepath.Join(inDir, "2.har"), testEntry("2025-03-09T11:49:14Z", "https://a.example.com/1"))
+	writeTestHAR(t, filepath.Join(inDir, "3.har"),
+		testEntry("2025-03-09T11:49:16Z", "https://b.example.com/"),
+		testEntry("2025-03-09T11:49:17Z", "https://b.example.com/"),
+	)
+
+	var merged harlog.HAR
+	if err := json.Unmarshal([]byte(runCommand(t, "merge", inDir)), &merged); err != nil {
+		t.Fatal(err)
+	}
+	if len(merged.Log.Entries) != 4 || merged.Log.Entries[0].Request.URL != "https://a.example.com/1" {
+		t.Errorf("unexpected merged entries: %+v", merged.Log.Entries)
+	}
+
+	var deduped harlog.HAR
+	if err := json.Unmarshal([]byte(runCommand(t, "dedupe", inDir)), &deduped); err != nil {
+		t.Fatal(err)
+	}
+	if len(deduped.Log.Entries) != 3 {
+		t.Errorf("expected 3 entries, got %d", len(deduped.Log.Entries))
+	}
+
+	outDir := t.TempDir()
+	out := runCommand(t, "split", "-by", "host", "-d", outDir, inDir)
+	if lines := strings.Split(strings.TrimSpace(out), "\n"); len(lines) != 2 {
+		t.Fatalf("expected 2 files, got:\n%s", out)
+	}
+
+	har, err := harlog.ReadHARFile(filepath.Join(outDir, "b.example.com.har"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(har.Log.Entries) != 2 {
+		t.Errorf("expected 2 entries, got %d", len(har.Log.Entries))
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/m-mizutani/harlog/openapi"
+)
+
+func runOpenAPI(args []string, stdout io.Writer) error {
+	var filter entryFilter
+	fs := flag.NewFlagSet("openapi", flag.ContinueOnError)
+	title := fs.String("title", "Generated API", "title of the API")
+	version := fs.String("version", "0.0.0", "version of the API")
+	filter.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := filter.validate(); err != nil {
+		return err
+	}
+
+	har, err := loadHAR(fs.Args())
+	if err != nil {
+		return err
+	}
+	har.Log.Entries = filter.apply(har.Log.Entries)
+
+	doc, err := openapi.GenerateFromHAR(har, openapi.WithTitle(*title), openapi.WithAPIVersion(*version))
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return fmt.Errorf("failed to encode OpenAPI document: %w", err)
+	}
+	return nil
+}
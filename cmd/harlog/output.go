@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/m-mizutani/harlog"
+)
+
+// Output formats supported by list and filter
+const (
+	formatTable = "table"
+	formatJSONL = "jsonl"
+	formatHAR   = "har"
+)
+
+func writeEntries(w io.Writer, format string, har *harlog.HAR) error {
+	switch format {
+	case formatTable:
+		return writeTable(w, har.Log.Entries)
+	case formatJSONL:
+		return writeJSONL(w, har.Log.Entries)
+	case formatHAR:
+		return writeHAR(w, har)
+	default:
+		return fmt.Errorf("unknown output format: %s (expected %s, %s or %s)", format, formatTable, formatJSONL, formatHAR)
+	}
+}
+
+func writeTable(w io.Writer, entries []harlog.HAREntry) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "#\tTIME\tMETHOD\tSTATUS\tSIZE\tDURATION\tURL")
+	for i, entry := range entries {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%d\t%s\t%s\t%s\n",
+			i,
+			formatTime(entry.StartedDateTime),
+			entry.Request.Method,
+			entry.Response.Status,
+			formatSize(entry.Response.Content.Size),
+			entryDuration(&entry).Round(time.Millisecond),
+			entryTarget(&entry),
+		)
+	}
+	return tw.Flush()
+}
+
+// entryTarget returns the URL of entry followed by its GraphQL operation, if
+// any, since GraphQL requests share a URL
+func entryTarget(entry *harlog.HAREntry) string {
+	op := entry.GraphQL
+	if op == nil {
+		return entry.Request.URL
+	}
+	target := entry.Request.URL + " (" + strings.TrimSpace(op.OperationType+" "+op.OperationName)
+	if op.Errors > 0 {
+		target += fmt.Sprintf(", %d errors", op.Errors)
+	}
+	return target + ")"
+}
+
+func writeJSONL(w io.Writer, entries []harlog.HAREntry) error {
+	encoder := json.NewEncoder(w)
+	for _, entry := range entries {
+		if err := encoder.Encode(entry); err != nil {
+			return fmt.Errorf("failed to encode entry: %w", err)
+		}
+	}
+	return nil
+}
+
+func writeHAR(w io.Writer, har *harlog.HAR) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(har); err != nil {
+		return fmt.Errorf("failed to encode HAR: %w", err)
+	}
+	return nil
+}
+
+func formatTime(s string) string {
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return s
+	}
+	return t.Local().Format("2006-01-02 15:04:05")
+}
+
+func formatSize(size int) string {
+	switch {
+	case size < 0:
+		return "-"
+	case size < 1024:
+		return strconv.Itoa(size) + "B"
+	case size < 1024*1024:
+		return fmt.Sprintf("%.1fKB", float64(size)/1024)
+	default:
+		return fmt.Sprintf("%.1fMB", float64(size)/1024/1024)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/m-mizutani/harlog/replay"
+)
+
+func runReplay(args []string, stdout io.Writer) error {
+	var filter entryFilter
+	var headers []string
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	target := fs.String("target", "", "base URL of the server to send requests to (required)")
+	concurrency := fs.Int("c", 1, "maximum number of requests in flight")
+	original := fs.Bool("original-timing", false, "send requests with the recorded timing")
+	speed := fs.Float64("speed", 1, "speed factor for -original-timing")
+	rate := fs.Float64("rate", 0, "send requests at a fixed rate per second")
+	timeout := fs.Duration("timeout", 30*time.Second, "timeout of each request")
+	values := fs.Bool("values", false, "report changed JSON values in addition to structural changes")
+	format := fs.String("o", "text", "output format: text or json")
+	fs.Func("H", "override a request header, e.g. 'Authorization: Bearer xxx' (repeatable)", func(s string) error {
+		if !strings.Contains(s, ":") {
+			return fmt.Errorf("header must be in 'Name: value' form: %s", s)
+		}
+		headers = append(headers, s)
+		return nil
+	})
+	filter.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := filter.validate(); err != nil {
+		return err
+	}
+	if *target == "" {
+		return fmt.Errorf("usage: harlog replay -target <url> [options] <file or directory>...")
+	}
+	if *original && *rate > 0 {
+		return fmt.Errorf("-original-timing and -rate cannot be used together")
+	}
+
+	opts := []replay.Option{
+		replay.WithConcurrency(*concurrency),
+		replay.WithClient(&http.Client{
+			Timeout: *timeout,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		}),
+	}
+	switch {
+	case *original:
+		opts = append(opts, replay.WithOriginalTiming(*speed))
+	case *rate > 0:
+		opts = append(opts, replay.WithRate(*rate))
+	}
+	for _, h := range headers {
+		name, value, _ := strings.Cut(h, ":")
+		opts = append(opts, replay.WithHeader(strings.TrimSpace(name), strings.TrimSpace(value)))
+	}
+	if *values {
+		opts = append(opts, replay.WithBodyValues())
+	}
+
+	replayer, err := replay.New(*target, opts...)
+	if err != nil {
+		return err
+	}
+
+	har, err := loadHAR(fs.Args())
+	if err != nil {
+		return err
+	}
+	har.Log.Entries = filter.apply(har.Log.Entries)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	summary, replayErr := replayer.Replay(ctx, har)
+
+	switch *format {
+	case "text":
+		if err := summary.WriteText(stdout); err != nil {
+			return err
+		}
+	case "json":
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(summary); err != nil {
+			return fmt.Errorf("failed to encode summary: %w", err)
+		}
+	default:
+		return fmt.Errorf("unknown output format: %s (expected text or json)", *format)
+	}
+
+	if replayErr != nil {
+		return replayErr
+	}
+	if summary.HasMismatches() {
+		return errDifferences
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/m-mizutani/harlog"
+)
+
+func runReverseProxy(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("reverse-proxy", flag.ContinueOnError)
+	target := fs.String("target", "", "URL of the service to forward requests to (required)")
+	listen := fs.String("listen", "127.0.0.1:8080", "address to listen on")
+	dir := fs.String("d", ".", "output directory of HAR files")
+	compress := fs.String("compress", "none", "compression of HAR files: none, gzip or zstd")
+	ndjson := fs.String("ndjson", "", "append entries to this NDJSON file instead of writing HAR files")
+	flushInterval := fs.Duration("flush-interval", 0, "interval to flush responses to the client; negative flushes after each write")
+	grace := fs.Duration("grace", 10*time.Second, "time to wait for open connections on shutdown")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	u, err := url.Parse(*target)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("invalid or missing -target URL: %q", *target)
+	}
+	opts, err := recorderOptions(*dir, *compress, *ndjson)
+	if err != nil {
+		return err
+	}
+
+	proxy := harlog.NewReverseProxy(u, opts...)
+	defer proxy.Close()
+	proxy.FlushInterval = *flushInterval
+
+	server := &http.Server{
+		Handler:           proxy,
+		ReadHeaderTimeout: 30 * time.Second,
+	}
+	return serveUntilSignal(*listen, *grace, stdout, "reverse proxy to "+u.String(), server.Serve, server.Shutdown)
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/m-mizutani/harlog"
+)
+
+func runShow(args []string, stdout io.Writer) error {
+	var filter entryFilter
+	fs := flag.NewFlagSet("show", flag.ContinueOnError)
+	index := fs.Int("i", 0, "index of the entry among the filtered entries, as printed by list")
+	format := fs.String("o", "text", "output format: text or json")
+	filter.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := filter.validate(); err != nil {
+		return err
+	}
+
+	har, err := loadHAR(fs.Args())
+	if err != nil {
+		return err
+	}
+
+	entries := filter.apply(har.Log.Entries)
+	if *index < 0 || *index >= len(entries) {
+		return fmt.Errorf("entry index %d out of range (%d entries)", *index, len(entries))
+	}
+	entry := entries[*index]
+
+	switch *format {
+	case "text":
+		return writeEntryText(stdout, &entry)
+	case "json":
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(entry)
+	default:
+		return fmt.Errorf("unknown output format: %s (expected text or json)", *format)
+	}
+}
+
+func writeEntryText(w io.Writer, entry *harlog.HAREntry) error {
+	messages, err := harlog.ConvertHAR(&harlog.HAR{
+		Log: harlog.HARLog{Entries: []harlog.HAREntry{*entry}},
+	})
+	if err != nil {
+		return err
+	}
+	req, resp := messages[0].Request, messages[0].Response
+
+	fmt.Fprintf(w, "Started:  %s\n", formatTime(entry.StartedDateTime))
+	fmt.Fprintf(w, "Duration: %s\n", entryDuration(entry).Round(time.Millisecond))
+	if entry.Comment != "" {
+		fmt.Fprintf(w, "Comment:  %s\n", entry.Comment)
+	}
+	fmt.Fprintln(w)
+
+	fmt.Fprintf(w, "%s %s %s\n", req.Method, req.URL, req.Proto)
+	writeHeaders(w, req.Header)
+	if req.Body != nil {
+		if err := writeBody(w, req.Body, req.Header.Get("Content-Type")); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintln(w)
+
+	fmt.Fprintf(w, "%s %d %s\n", resp.Proto, resp.StatusCode, statusText(resp))
+	writeHeaders(w, resp.Header)
+	return writeBody(w, resp.Body, resp.Header.Get("Content-Type"))
+}
+
+func writeHeaders(w io.Writer, h http.Header) {
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		for _, value := range h[name] {
+			fmt.Fprintf(w, "%s: %s\n", name, value)
+		}
+	}
+}
+
+func writeBody(w io.Writer, body io.Reader, contentType string) error {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return fmt.Errorf("failed to read body: %w", err)
+	}
+	if len(data) == 0 {
+		return nil
+	}
+
+	fmt.Fprintln(w)
+	if strings.Contains(contentType, "json") || json.Valid(data) {
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, data, "", "  "); err == nil {
+			data = buf.Bytes()
+		}
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if !bytes.HasSuffix(data, []byte("\n")) {
+		fmt.Fprintln(w)
+	}
+	return nil
+}
+
+// statusText returns the reason phrase of resp. HAR files written by the
+// RoundTripper store the full status line such as "200 OK" in statusText.
+func statusText(resp *http.Response) string {
+	text := strings.TrimSpace(strings.TrimPrefix(resp.Status, fmt.Sprint(resp.StatusCode)))
+	if text == "" {
+		text = http.StatusText(resp.StatusCode)
+	}
+	return text
+}
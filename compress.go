@@ -0,0 +1,124 @@
+package harlog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression represents the compression of output files
+type Compression int
+
+const (
+	// CompressionNone writes plain HAR files
+	CompressionNone Compression = iota
+	// CompressionGzip writes gzip compressed files with the ".gz" extension
+	CompressionGzip
+	// CompressionZstd writes Zstandard compressed files with the ".zst"
+	// extension
+	CompressionZstd
+)
+
+// extension returns the file extension of c
+func (c Compression) extension() string {
+	switch c {
+	case CompressionGzip:
+		return ".gz"
+	case CompressionZstd:
+		return ".zst"
+	default:
+		return ""
+	}
+}
+
+// compressionFor returns the compression selected by the extension of
+// filename, or fallback if the extension is not a compressed one
+func compressionFor(filename string, fallback Compression) Compression {
+	switch {
+	case strings.HasSuffix(filename, ".gz"):
+		return CompressionGzip
+	case strings.HasSuffix(filename, ".zst"):
+		return CompressionZstd
+	default:
+		return fallback
+	}
+}
+
+// compressWriter wraps w so that written data is compressed with c at level.
+// Level 0 selects the default level of the algorithm. The returned writer
+// must be closed to flush the compressed data.
+func compressWriter(w io.Writer, c Compression, level int) (io.WriteCloser, error) {
+	switch c {
+	case CompressionGzip:
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+		zw, err := gzip.NewWriterLevel(w, level)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gzip compression level: %w", err)
+		}
+		return zw, nil
+
+	case CompressionZstd:
+		opts := []zstd.EOption{zstd.WithEncoderConcurrency(1)}
+		if level != 0 {
+			opts = append(opts, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+		}
+		zw, err := zstd.NewWriter(w, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create zstd writer: %w", err)
+		}
+		return zw, nil
+
+	default:
+		return nopWriteCloser{w}, nil
+	}
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// decompress returns data decompressed if it starts with the magic bytes of
+// gzip or Zstandard, and data as it is otherwise
+func decompress(data []byte) ([]byte, error) {
+	switch {
+	case bytes.HasPrefix(data, gzipMagic):
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read gzip data: %w", err)
+		}
+		defer zr.Close()
+		out, err := io.ReadAll(zr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read gzip data: %w", err)
+		}
+		return out, nil
+
+	case bytes.HasPrefix(data, zstdMagic):
+		zr, err := zstd.NewReader(nil, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create zstd reader: %w", err)
+		}
+		defer zr.Close()
+		out, err := zr.DecodeAll(data, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read zstd data: %w", err)
+		}
+		return out, nil
+
+	default:
+		return data, nil
+	}
+}
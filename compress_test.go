@@ -0,0 +1,117 @@
+package harlog
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCompression(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(strings.Repeat(`{"name": "alice"}`, 100)))
+	}))
+	defer server.Close()
+
+	testCases := []struct {
+		name   string
+		opts   func(dir string) []Option
+		suffix string
+		magic  []byte
+	}{
+		{name: "gzip option", opts: func(string) []Option { return []Option{WithCompression(CompressionGzip)} }, suffix: ".har.gz", magic: gzipMagic},
+		{name: "zstd option", opts: func(string) []Option { return []Option{WithCompression(CompressionZstd), WithCompressionLevel(19)} }, suffix: ".har.zst", magic: zstdMagic},
+		{
+			name: "extension",
+			opts: func(dir string) []Option {
+				return []Option{WithFileNameFn(func(req *http.Request) string {
+					return filepath.Join(dir, "capture.har.zst")
+				})}
+			},
+			suffix: "capture.har.zst",
+			magic:  zstdMagic,
+		},
+		{
+			name: "extension over option",
+			opts: func(dir string) []Option {
+				return []Option{WithCompression(CompressionZstd), WithFileNameFn(func(req *http.Request) string {
+					return filepath.Join(dir, "capture.har.gz")
+				})}
+			},
+			suffix: "capture.har.gz",
+			magic:  gzipMagic,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			logger := New(append([]Option{WithOutputDir(tmpDir)}, tc.opts(tmpDir)...)...)
+
+			client := &http.Client{Transport: logger}
+			resp, err := client.Get(server.URL + "/users")
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+
+			files, err := filepath.Glob(filepath.Join(tmpDir, "*"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(files) != 1 || !strings.HasSuffix(files[0], tc.suffix) {
+				t.Fatalf("expected a file ending with %s, got %v", tc.suffix, files)
+			}
+
+			data, err := os.ReadFile(files[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.HasPrefix(data, tc.magic) {
+				t.Errorf("unexpected magic bytes: %x", data[:4])
+			}
+
+			messages, err := ParseHARFile(files[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(messages) != 1 || messages[0].Request.URL.Path != "/users" {
+				t.Errorf("unexpected messages: %+v", messages)
+			}
+		})
+	}
+}
+
+func TestCompression_InvalidLevel(t *testing.T) {
+	tmpDir := t.TempDir()
+	logger := New(
+		WithOutputDir(tmpDir),
+		WithCompression(CompressionGzip),
+		WithCompressionLevel(42),
+	)
+	entry := &HAREntry{Request: HARRequest{Method: "GET", URL: "https://example.com/"}}
+	if _, err := logger.saveHAR(httptest.NewRequest("GET", "/", nil), []HAREntry{*entry}, ""); err == nil {
+		t.Error("expected error for invalid level")
+	}
+	if files, _ := os.ReadDir(tmpDir); len(files) != 0 {
+		t.Errorf("expected no partial file, got %d files", len(files))
+	}
+}
+
+func TestDecompress_Plain(t *testing.T) {
+	data := []byte(`{"log": {}}`)
+	out, err := decompress(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, data) {
+		t.Errorf("expected data as it is, got %s", out)
+	}
+}
@@ -0,0 +1,213 @@
+package harlog
+
+import (
+	"context"
+	"maps"
+	"sync"
+)
+
+// captureControl holds per-request logging decisions carried in a context
+type captureControl struct {
+	mu          sync.Mutex
+	mutable     bool
+	capture     *bool
+	bodyCapture *bool
+	fileName    string
+	comment     string
+	tags        map[string]string
+	linkID      string
+}
+
+type controlCtxKey struct{}
+
+func controlFrom(ctx context.Context) *captureControl {
+	if ctx == nil {
+		return nil
+	}
+	c, _ := ctx.Value(controlCtxKey{}).(*captureControl)
+	return c
+}
+
+// clone returns an immutable copy of c. It is safe to call on nil.
+func (c *captureControl) clone() *captureControl {
+	if c == nil {
+		return &captureControl{}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return &captureControl{
+		capture:     c.capture,
+		bodyCapture: c.bodyCapture,
+		fileName:    c.fileName,
+		comment:     c.comment,
+		tags:        maps.Clone(c.tags),
+		linkID:      c.linkID,
+	}
+}
+
+// updateControl applies fn to the control stored in ctx. Controls installed by
+// Middleware are updated in place so that a handler can change the decision
+// for the request being served; otherwise a derived context is returned.
+func updateControl(ctx context.Context, fn func(c *captureControl)) context.Context {
+	if c := controlFrom(ctx); c != nil && c.mutable {
+		c.mu.Lock()
+		fn(c)
+		c.mu.Unlock()
+		return ctx
+	}
+
+	c := controlFrom(ctx).clone()
+	fn(c)
+	return context.WithValue(ctx, controlCtxKey{}, c)
+}
+
+// withMutableControl installs a control that handlers can update in place
+func withMutableControl(ctx context.Context) (context.Context, *captureControl) {
+	c := controlFrom(ctx).clone()
+	c.mutable = true
+	return context.WithValue(ctx, controlCtxKey{}, c), c
+}
+
+// Skip marks the request associated with ctx as not to be logged.
+//
+// On the client side, use the returned context for the outgoing request. In a
+// handler wrapped by Middleware, calling Skip(r.Context()) is sufficient.
+func Skip(ctx context.Context) context.Context {
+	return updateControl(ctx, func(c *captureControl) {
+		c.capture = boolPtr(false)
+	})
+}
+
+// Capture marks the request associated with ctx as to be logged, even if the
+// Logger was configured with WithDefaultCapture(false)
+func Capture(ctx context.Context) context.Context {
+	return updateControl(ctx, func(c *captureControl) {
+		c.capture = boolPtr(true)
+	})
+}
+
+// WithBodyCapture enables or disables recording of request and response bodies
+// for the request associated with ctx
+func WithBodyCapture(ctx context.Context, enabled bool) context.Context {
+	return updateControl(ctx, func(c *captureControl) {
+		c.bodyCapture = boolPtr(enabled)
+	})
+}
+
+// WithFileName overrides the HAR file name for the request associated with
+// ctx. Relative names are resolved against the output directory.
+func WithFileName(ctx context.Context, name string) context.Context {
+	return updateControl(ctx, func(c *captureControl) {
+		c.fileName = name
+	})
+}
+
+// WithComment sets the comment field of the HAR entry
+func WithComment(ctx context.Context, comment string) context.Context {
+	return updateControl(ctx, func(c *captureControl) {
+		c.comment = comment
+	})
+}
+
+// WithTag attaches a key/value pair to the HAR entry. Tags are written to the
+// custom "_tags" field of the entry.
+func WithTag(ctx context.Context, key, value string) context.Context {
+	return updateControl(ctx, func(c *captureControl) {
+		if c.tags == nil {
+			c.tags = make(map[string]string)
+		}
+		c.tags[key] = value
+	})
+}
+
+// TagsFrom returns a copy of the tags attached to ctx
+func TagsFrom(ctx context.Context) map[string]string {
+	c := controlFrom(ctx)
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return maps.Clone(c.tags)
+}
+
+// withLinkID links the entries recorded for the request associated with ctx
+// by id
+func withLinkID(ctx context.Context, id string) context.Context {
+	return updateControl(ctx, func(c *captureControl) {
+		c.linkID = id
+	})
+}
+
+// fileNameFrom returns the file name set by WithFileName, if any
+func fileNameFrom(ctx context.Context) string {
+	c := controlFrom(ctx)
+	if c == nil {
+		return ""
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.fileName
+}
+
+func boolPtr(v bool) *bool {
+	return &v
+}
+
+// shouldCapture reports whether the request controlled by c should be logged
+func (l *Logger) shouldCapture(c *captureControl) bool {
+	if c != nil {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.capture != nil {
+			return *c.capture
+		}
+	}
+	return l.captureByDefault
+}
+
+// shouldCaptureBody reports whether bodies of the request controlled by c
+// should be recorded
+func (l *Logger) shouldCaptureBody(c *captureControl) bool {
+	if c != nil {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.bodyCapture != nil {
+			return *c.bodyCapture
+		}
+	}
+	return l.captureBodyByDefault
+}
+
+// applyControl copies the per-request settings of c into entry
+func (l *Logger) applyControl(c *captureControl, entry *HAREntry) {
+	// The operation is read from the bodies before they may be dropped
+	detectGraphQL(entry)
+
+	if !l.shouldCaptureBody(c) {
+		if entry.Request.PostData != nil {
+			entry.Request.PostData.Text = ""
+		}
+		entry.Response.Content.Text = ""
+		for i := range entry.WebSocketMessages {
+			entry.WebSocketMessages[i].Data = ""
+		}
+		for i := range entry.EventStream {
+			entry.EventStream[i].Data = ""
+		}
+		if entry.GraphQL != nil {
+			entry.GraphQL.Variables = nil
+		}
+	}
+
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry.Comment = c.comment
+	entry.Tags = maps.Clone(c.tags)
+	if c.linkID != "" {
+		entry.Link = &HARLink{ID: c.linkID}
+	}
+}
@@ -0,0 +1,255 @@
+package harlog
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func readSingleEntry(t *testing.T, harFile string) HAREntry {
+	t.Helper()
+
+	harData, err := os.ReadFile(harFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var har HAR
+	if err := json.Unmarshal(harData, &har); err != nil {
+		t.Fatal(err)
+	}
+	if len(har.Log.Entries) != 1 {
+		t.Fatalf("expected 1 entry, got %d", len(har.Log.Entries))
+	}
+	return har.Log.Entries[0]
+}
+
+func countHARFiles(t *testing.T, dir string) int {
+	t.Helper()
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.har"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(files)
+}
+
+func TestMiddleware_ContextControl(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/skip":
+			Skip(r.Context())
+		case "/tagged":
+			WithComment(r.Context(), "debug session")
+			WithTag(r.Context(), "user", "alice")
+			WithFileName(r.Context(), "tagged.har")
+		case "/nobody":
+			WithBodyCapture(r.Context(), false)
+			WithFileName(r.Context(), "nobody.har")
+		}
+		_, _ = w.Write([]byte("secret response"))
+	})
+
+	logger := New(WithOutputDir(tmpDir))
+	server := httptest.NewServer(logger.Middleware(handler))
+	defer server.Close()
+
+	for _, path := range []string{"/skip", "/tagged", "/nobody"} {
+		resp, err := http.Post(server.URL+path, "text/plain", strings.NewReader("secret request"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+
+	if n := countHARFiles(t, tmpDir); n != 2 {
+		t.Errorf("expected 2 HAR files, got %d", n)
+	}
+
+	tagged := readSingleEntry(t, filepath.Join(tmpDir, "tagged.har"))
+	if tagged.Comment != "debug session" {
+		t.Errorf("comment mismatch: got %q", tagged.Comment)
+	}
+	if tagged.Tags["user"] != "alice" {
+		t.Errorf("tag mismatch: got %v", tagged.Tags)
+	}
+	if tagged.Response.Content.Text != "secret response" {
+		t.Errorf("response body mismatch: got %q", tagged.Response.Content.Text)
+	}
+
+	nobody := readSingleEntry(t, filepath.Join(tmpDir, "nobody.har"))
+	if nobody.Request.PostData == nil || nobody.Request.PostData.Text != "" {
+		t.Errorf("request body should not be recorded: %+v", nobody.Request.PostData)
+	}
+	if nobody.Response.Content.Text != "" {
+		t.Errorf("response body should not be recorded: got %q", nobody.Response.Content.Text)
+	}
+	if nobody.Response.Content.Size != len("secret response") {
+		t.Errorf("response size mismatch: got %d", nobody.Response.Content.Size)
+	}
+}
+
+func TestMiddleware_RequestBodyStreaming(t *testing.T) {
+	rec := NewRecorder()
+	read := make(chan string)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/reject" {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+		// The first chunk arrives before the client sends the rest
+		buf := make([]byte, 5)
+		if _, err := io.ReadFull(r.Body, buf); err != nil {
+			t.Error(err)
+		}
+		read <- string(buf)
+		_, _ = io.Copy(io.Discard, r.Body)
+	})
+
+	logger := New(WithFileOutput(false), WithSink(rec))
+	server := httptest.NewServer(logger.Middleware(handler))
+	defer server.Close()
+
+	pr, pw := io.Pipe()
+	go func() {
+		_, _ = io.WriteString(pw, "hello")
+		<-read
+		_, _ = io.WriteString(pw, " world")
+		pw.Close()
+	}()
+	resp, err := http.Post(server.URL+"/upload", "text/plain", pr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	entry := waitEntry(t, rec, http.MethodPost, "/upload")
+	if entry.Request.PostData == nil || entry.Request.PostData.Text != "hello world" || entry.Request.BodySize != 11 {
+		t.Errorf("expected body read by the handler, got %+v", entry.Request.PostData)
+	}
+
+	// Bodies the handler does not read are not recorded
+	resp, err = http.Post(server.URL+"/reject", "text/plain", strings.NewReader("too large"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	entry = waitEntry(t, rec, http.MethodPost, "/reject")
+	if entry.Request.PostData == nil || entry.Request.PostData.Text != "" {
+		t.Errorf("expected unread body to be empty, got %+v", entry.Request.PostData)
+	}
+}
+
+func TestMiddleware_OptIn(t *testing.T) {
+	rec := NewRecorder()
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A debug flag turns capture on for this request only
+		if r.URL.Query().Get("debug") == "1" {
+			Capture(r.Context())
+			WithBodyCapture(r.Context(), true)
+		}
+		_, _ = io.Copy(io.Discard, r.Body)
+		_, _ = w.Write([]byte("hello"))
+	})
+
+	logger := New(WithFileOutput(false), WithSink(rec), WithDefaultCapture(false), WithDefaultBodyCapture(false))
+	server := httptest.NewServer(logger.Middleware(handler))
+	defer server.Close()
+
+	for _, query := range []string{"", "?debug=1"} {
+		resp, err := http.Post(server.URL+"/upload"+query, "text/plain", strings.NewReader("payload"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	entry := waitEntry(t, rec, http.MethodPost, "/upload?debug=1")
+	if entry.Request.PostData == nil || entry.Request.PostData.Text != "payload" {
+		t.Errorf("expected body of the opted-in request, got %+v", entry.Request.PostData)
+	}
+	if entry.Response.Content.Text != "hello" {
+		t.Errorf("expected response body, got %q", entry.Response.Content.Text)
+	}
+	if n := rec.Len(); n != 1 {
+		t.Errorf("expected 1 entry, got %d", n)
+	}
+}
+
+func TestRoundTrip_ContextControl(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("full body"))
+	}))
+	defer server.Close()
+
+	// Nothing is logged unless the request opts in
+	logger := New(
+		WithOutputDir(tmpDir),
+		WithDefaultCapture(false),
+		WithDefaultBodyCapture(false),
+	)
+	client := &http.Client{Transport: logger}
+
+	get := func(ctx context.Context) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if string(body) != "full body" {
+			t.Errorf("body mismatch: got %q", string(body))
+		}
+	}
+
+	get(context.Background())
+	if n := countHARFiles(t, tmpDir); n != 0 {
+		t.Fatalf("expected no HAR files, got %d", n)
+	}
+
+	ctx := Capture(context.Background())
+	ctx = WithBodyCapture(ctx, true)
+	ctx = WithTag(ctx, "debug", "1")
+	get(WithFileName(ctx, "debug.har"))
+
+	// Deriving a context must not modify its parent
+	get(WithFileName(WithBodyCapture(ctx, false), "headers-only.har"))
+
+	entry := readSingleEntry(t, filepath.Join(tmpDir, "debug.har"))
+	if entry.Response.Content.Text != "full body" {
+		t.Errorf("response body mismatch: got %q", entry.Response.Content.Text)
+	}
+	if entry.Tags["debug"] != "1" {
+		t.Errorf("tag mismatch: got %v", entry.Tags)
+	}
+
+	entry = readSingleEntry(t, filepath.Join(tmpDir, "headers-only.har"))
+	if entry.Response.Content.Text != "" {
+		t.Errorf("response body should not be recorded: got %q", entry.Response.Content.Text)
+	}
+	if entry.Response.Status != http.StatusOK {
+		t.Errorf("status mismatch: got %d", entry.Response.Status)
+	}
+
+	if n := countHARFiles(t, tmpDir); n != 2 {
+		t.Errorf("expected 2 HAR files, got %d", n)
+	}
+}
@@ -0,0 +1,212 @@
+package harlog
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// CurlCommand returns a shell command that reproduces req with curl. Text
+// bodies are passed with --data-binary; binary bodies are piped via stdin.
+func CurlCommand(req *http.Request) (string, error) {
+	body, err := readRequestBody(req)
+	if err != nil {
+		return "", err
+	}
+
+	// Each element is written on its own line
+	lines := []string{"curl"}
+	if req.Method != http.MethodGet || len(body) > 0 {
+		lines[0] += " -X " + shellQuote(req.Method)
+	}
+	lines[0] += " " + shellQuote(req.URL.String())
+
+	for _, h := range exportHeaders(req) {
+		lines = append(lines, "-H "+shellQuote(h.Name+": "+h.Value))
+	}
+	if req.Header.Get("Accept-Encoding") != "" {
+		lines = append(lines, "--compressed")
+	}
+
+	var pipe string
+	if len(body) > 0 {
+		if isPrintable(body) {
+			lines = append(lines, "--data-binary "+shellQuote(string(body)))
+		} else {
+			pipe = printfCommand(body) + " | "
+			lines = append(lines, "--data-binary @-")
+		}
+	}
+
+	return pipe + strings.Join(lines, " \\\n  "), nil
+}
+
+// HTTPieCommand returns a shell command that reproduces req with HTTPie
+func HTTPieCommand(req *http.Request) (string, error) {
+	body, err := readRequestBody(req)
+	if err != nil {
+		return "", err
+	}
+
+	// Each element is written on its own line
+	lines := []string{"http"}
+	var pipe string
+	switch {
+	case len(body) == 0:
+		lines[0] += " --ignore-stdin"
+	case isPrintable(body):
+		lines[0] += " --raw " + shellQuote(string(body))
+	default:
+		pipe = printfCommand(body) + " | "
+	}
+	lines[0] += " " + shellQuote(req.Method) + " " + shellQuote(req.URL.String())
+
+	for _, h := range exportHeaders(req) {
+		if h.Value == "" {
+			lines = append(lines, shellQuote(h.Name+";"))
+		} else {
+			lines = append(lines, shellQuote(h.Name+":"+h.Value))
+		}
+	}
+
+	return pipe + strings.Join(lines, " \\\n  "), nil
+}
+
+// GoCode returns a Go snippet that builds req with http.NewRequest and sends
+// it with http.DefaultClient. The snippet is meant to be pasted into a function
+// returning an error.
+func GoCode(req *http.Request) (string, error) {
+	body, err := readRequestBody(req)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	bodyExpr := "nil"
+	if len(body) > 0 {
+		b.WriteString("body := strings.NewReader(" + strconv.Quote(string(body)) + ")\n")
+		bodyExpr = "body"
+	}
+
+	fmt.Fprintf(&b, "req, err := http.NewRequest(%s, %s, %s)\n",
+		goMethod(req.Method), strconv.Quote(req.URL.String()), bodyExpr)
+	b.WriteString("if err != nil {\n\treturn err\n}\n")
+
+	headers := exportHeaders(req)
+	for i, h := range headers {
+		fn := "Set"
+		if i > 0 && headers[i-1].Name == h.Name {
+			fn = "Add"
+		}
+		fmt.Fprintf(&b, "req.Header.%s(%s, %s)\n", fn, strconv.Quote(h.Name), strconv.Quote(h.Value))
+	}
+	if req.Host != "" && req.Host != req.URL.Host {
+		fmt.Fprintf(&b, "req.Host = %s\n", strconv.Quote(req.Host))
+	}
+
+	b.WriteString("\nresp, err := http.DefaultClient.Do(req)\n")
+	b.WriteString("if err != nil {\n\treturn err\n}\n")
+	b.WriteString("defer resp.Body.Close()\n")
+
+	return b.String(), nil
+}
+
+// readRequestBody returns the body of req and restores it for further use
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+// exportHeaders returns the headers of req worth reproducing, sorted by name.
+// Headers computed by the client, such as Content-Length and HTTP/2
+// pseudo-headers, are omitted.
+func exportHeaders(req *http.Request) []HARHeader {
+	names := make([]string, 0, len(req.Header))
+	for name := range req.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	headers := make([]HARHeader, 0, len(names))
+	for _, name := range names {
+		switch {
+		case strings.HasPrefix(name, ":"):
+			continue
+		case strings.EqualFold(name, "Content-Length"):
+			continue
+		case strings.EqualFold(name, "Host") && req.Header.Get(name) == req.URL.Host:
+			continue
+		}
+		for _, value := range req.Header[name] {
+			headers = append(headers, HARHeader{Name: name, Value: value})
+		}
+	}
+	return headers
+}
+
+// shellQuote quotes s for POSIX shells
+func shellQuote(s string) string {
+	if s != "" && strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' ||
+			strings.ContainsRune("-_./:=@,+%", r))
+	}) < 0 {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// printfCommand returns a printf command writing data to stdout. Bytes other
+// than printable ASCII are written as octal escapes.
+func printfCommand(data []byte) string {
+	var b strings.Builder
+	for _, c := range data {
+		switch {
+		case c == '\\':
+			b.WriteString(`\\`)
+		case c == '\'':
+			b.WriteString(`'\''`)
+		case c >= 0x20 && c < 0x7f:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, `\0%03o`, c)
+		}
+	}
+	return "printf '%b' '" + b.String() + "'"
+}
+
+// isPrintable reports whether data is UTF-8 text without control characters
+// other than whitespace
+func isPrintable(data []byte) bool {
+	if !utf8.Valid(data) {
+		return false
+	}
+	for _, c := range data {
+		if c < 0x20 && c != '\n' && c != '\r' && c != '\t' || c == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+func goMethod(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return "http.Method" + method[:1] + strings.ToLower(method[1:])
+	default:
+		return strconv.Quote(method)
+	}
+}
@@ -0,0 +1,179 @@
+package harlog
+
+import (
+	"go/parser"
+	"go/token"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func newExportTestRequest(t *testing.T, body string) *http.Request {
+	t.Helper()
+
+	msg, err := ConvertEntry(&HAREntry{
+		Request: HARRequest{
+			Method:      http.MethodPost,
+			URL:         "https://api.example.com/users?name=it's",
+			HTTPVersion: "HTTP/1.1",
+			Headers: []HARHeader{
+				{Name: "Host", Value: "api.example.com"},
+				{Name: "Content-Type", Value: "application/json"},
+				{Name: "Content-Length", Value: "100"},
+				{Name: "X-Quote", Value: `a 'quoted' "value"`},
+			},
+			PostData: &HARPostData{MimeType: "application/json", Text: body},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msg.Request
+}
+
+func TestCurlCommand(t *testing.T) {
+	req := newExportTestRequest(t, `{"name": "it's me"}`)
+	cmd, err := CurlCommand(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		"curl",
+		"-X POST",
+		`'https://api.example.com/users?name=it'\''s'`,
+		`-H 'X-Quote: a '\''quoted'\'' "value"'`,
+		`--data-binary '{"name": "it'\''s me"}'`,
+	} {
+		if !strings.Contains(cmd, want) {
+			t.Errorf("command does not contain %q:\n%s", want, cmd)
+		}
+	}
+	if strings.Contains(cmd, "Content-Length") || strings.Contains(cmd, "Host:") {
+		t.Errorf("computed headers should be omitted:\n%s", cmd)
+	}
+
+	// The body can still be read after export
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != `{"name": "it's me"}` {
+		t.Errorf("body was consumed: %q", string(body))
+	}
+}
+
+func TestCurlCommand_Run(t *testing.T) {
+	if _, err := exec.LookPath("curl"); err != nil {
+		t.Skip("curl is not installed")
+	}
+
+	binary := "\x00\x01binary\\'\xff"
+	// The handler runs in another goroutine than the test, so the request it
+	// receives is handed over
+	type received struct{ body, header string }
+	got := make(chan received, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got <- received{body: string(body), header: r.Header.Get("X-Quote")}
+	}))
+	defer server.Close()
+
+	for _, body := range []string{`{"name": "it's me"}`, binary} {
+		req := newExportTestRequest(t, body)
+		req.URL.Scheme = "http"
+		req.URL.Host = strings.TrimPrefix(server.URL, "http://")
+
+		cmd, err := CurlCommand(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out, err := exec.Command("sh", "-c", cmd+" --silent").CombinedOutput(); err != nil {
+			t.Fatalf("failed to run %s: %v\n%s", cmd, err, out)
+		}
+
+		r := <-got
+		if r.body != body {
+			t.Errorf("body mismatch\nwant: %q\ngot: %q", body, r.body)
+		}
+		if r.header != `a 'quoted' "value"` {
+			t.Errorf("header mismatch: %q", r.header)
+		}
+	}
+}
+
+func TestHTTPieCommand(t *testing.T) {
+	cmd, err := HTTPieCommand(newExportTestRequest(t, "hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		"http --raw hello POST",
+		"Content-Type:application/json",
+		`'X-Quote:a '\''quoted'\'' "value"'`,
+	} {
+		if !strings.Contains(cmd, want) {
+			t.Errorf("command does not contain %q:\n%s", want, cmd)
+		}
+	}
+
+	cmd, err = HTTPieCommand(newExportTestRequest(t, "\x00"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(cmd, `printf '%b' '\0000' | http POST`) {
+		t.Errorf("binary body should be piped:\n%s", cmd)
+	}
+}
+
+func TestExport_QuotesMethod(t *testing.T) {
+	const method = "GET|`id`&&x"
+	req, err := http.NewRequest(method, "http://example.com/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, export := range map[string]func(*http.Request) (string, error){
+		"curl":   CurlCommand,
+		"httpie": HTTPieCommand,
+	} {
+		cmd, err := export(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(cmd, " '"+method+"' ") {
+			t.Errorf("%s: expected quoted method, got:\n%s", name, cmd)
+		}
+	}
+}
+
+func TestGoCode(t *testing.T) {
+	req := newExportTestRequest(t, "line1\nline2")
+	req.Header.Add("Accept", "text/plain")
+	req.Header.Add("Accept", "application/json")
+
+	code, err := GoCode(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		`body := strings.NewReader("line1\nline2")`,
+		`http.NewRequest(http.MethodPost, "https://api.example.com/users?name=it's", body)`,
+		`req.Header.Set("Accept", "text/plain")`,
+		`req.Header.Add("Accept", "application/json")`,
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("code does not contain %q:\n%s", want, code)
+		}
+	}
+
+	src := "package main\nfunc f() error {\n" + code + "return nil\n}\n"
+	if _, err := parser.ParseFile(token.NewFileSet(), "snippet.go", src, 0); err != nil {
+		t.Errorf("generated code does not parse: %v\n%s", err, code)
+	}
+}
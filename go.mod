@@ -2,4 +2,7 @@ module github.com/m-mizutani/harlog
 
 go 1.21
 
-require github.com/google/uuid v1.6.0
+require (
+	github.com/google/uuid v1.6.0
+	github.com/klauspost/compress v1.17.11
+)
@@ -0,0 +1,249 @@
+package harlog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime"
+	"strings"
+)
+
+// Types of GraphQL operations
+const (
+	GraphQLQuery        = "query"
+	GraphQLMutation     = "mutation"
+	GraphQLSubscription = "subscription"
+)
+
+type graphQLCtxKey struct{}
+
+// GraphQLFrom returns the GraphQL operation of the entry being saved, so that
+// a function set by WithFileNameFn can name files after it. It returns nil
+// for other requests.
+func GraphQLFrom(ctx context.Context) *HARGraphQL {
+	op, _ := ctx.Value(graphQLCtxKey{}).(*HARGraphQL)
+	return op
+}
+
+// graphQLRequest is the body of a GraphQL request sent as JSON
+type graphQLRequest struct {
+	Query         *string                    `json:"query"`
+	OperationName string                     `json:"operationName"`
+	Variables     json.RawMessage            `json:"variables"`
+	Extensions    map[string]json.RawMessage `json:"extensions"`
+}
+
+// detectGraphQL sets the GraphQL field of entry if its request is a GraphQL
+// operation, sent as a JSON or application/graphql body or in the query
+// string, and counts the errors of its response. Batched operations are not
+// recognized.
+func detectGraphQL(entry *HAREntry) {
+	req, ok := parseGraphQLRequest(&entry.Request)
+	if !ok {
+		return
+	}
+
+	op := &HARGraphQL{OperationName: req.OperationName}
+	if req.Query != nil {
+		ops, ok := parseGraphQLOperations(*req.Query)
+		if !ok {
+			return
+		}
+		selected, found := ops[0], req.OperationName == ""
+		for _, o := range ops {
+			if req.OperationName != "" && o.name == req.OperationName {
+				selected, found = o, true
+			}
+		}
+		if found {
+			op.OperationName, op.OperationType = selected.name, selected.typ
+		}
+	} else if _, persisted := req.Extensions["persistedQuery"]; !persisted || req.OperationName == "" {
+		// Only persisted queries are sent without the document
+		return
+	}
+	if v := bytes.TrimSpace(req.Variables); len(v) > 0 && string(v) != "null" {
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, v); err == nil {
+			op.Variables = buf.Bytes()
+		}
+	}
+	op.Errors = countGraphQLErrors(entry.Response.Content.Text)
+	entry.GraphQL = op
+}
+
+// parseGraphQLRequest extracts the operation of req from its body or query
+// string
+func parseGraphQLRequest(req *HARRequest) (graphQLRequest, bool) {
+	var r graphQLRequest
+	if pd := req.PostData; pd != nil && pd.Text != "" {
+		mediaType, _, _ := mime.ParseMediaType(pd.MimeType)
+		if mediaType == "application/graphql" {
+			r.Query = &pd.Text
+			return r, true
+		}
+		text := strings.TrimSpace(pd.Text)
+		if !strings.HasPrefix(text, "{") || json.Unmarshal([]byte(text), &r) != nil {
+			return r, false
+		}
+		return r, true
+	}
+
+	for _, q := range req.QueryString {
+		switch q.Name {
+		case "query":
+			query := q.Value
+			r.Query = &query
+		case "operationName":
+			r.OperationName = q.Value
+		case "variables":
+			r.Variables = json.RawMessage(q.Value)
+		case "extensions":
+			_ = json.Unmarshal([]byte(q.Value), &r.Extensions)
+		}
+	}
+	return r, r.Query != nil || r.Extensions != nil
+}
+
+// countGraphQLErrors returns the number of errors in a GraphQL response body
+func countGraphQLErrors(body string) int {
+	body = strings.TrimSpace(body)
+	if !strings.HasPrefix(body, "{") {
+		return 0
+	}
+	var resp struct {
+		Errors []json.RawMessage `json:"errors"`
+	}
+	if err := json.Unmarshal([]byte(body), &resp); err != nil {
+		return 0
+	}
+	return len(resp.Errors)
+}
+
+// graphQLOperation is an operation defined in a GraphQL document
+type graphQLOperation struct {
+	typ  string
+	name string
+}
+
+// parseGraphQLOperations returns the operations defined in a GraphQL
+// document, reading no further than needed to find their types and names.
+// A selection set without a keyword is an anonymous query. It reports false
+// if the text is not a document defining an operation.
+func parseGraphQLOperations(doc string) ([]graphQLOperation, bool) {
+	var (
+		ops        []graphQLOperation
+		pending    *graphQLOperation
+		expectName bool
+		fragment   bool
+		depth      int
+		parens     int
+	)
+	for i := 0; i < len(doc); {
+		c := doc[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+			i++
+			continue
+		case strings.HasPrefix(doc[i:], "\xef\xbb\xbf"):
+			i += 3
+			continue
+		case c == '#':
+			for i < len(doc) && doc[i] != '\n' && doc[i] != '\r' {
+				i++
+			}
+			continue
+		case strings.HasPrefix(doc[i:], `"""`):
+			end := strings.Index(doc[i+3:], `"""`)
+			for end >= 0 && doc[i+3+end-1] == '\\' {
+				next := strings.Index(doc[i+3+end+3:], `"""`)
+				if next < 0 {
+					end = -1
+					break
+				}
+				end += 3 + next
+			}
+			if end < 0 {
+				return nil, false
+			}
+			i += 3 + end + 3
+		case c == '"':
+			j := i + 1
+			for ; j < len(doc) && doc[j] != '"'; j++ {
+				if doc[j] == '\\' {
+					j++
+				}
+				if j < len(doc) && (doc[j] == '\n' || doc[j] == '\r') {
+					return nil, false
+				}
+			}
+			if j >= len(doc) {
+				return nil, false
+			}
+			i = j + 1
+		case isNameStart(c):
+			j := i + 1
+			for j < len(doc) && (isNameStart(doc[j]) || doc[j] >= '0' && doc[j] <= '9') {
+				j++
+			}
+			name := doc[i:j]
+			i = j
+			if depth > 0 || parens > 0 {
+				continue
+			}
+			if expectName {
+				pending.name, expectName = name, false
+				continue
+			}
+			switch name {
+			case GraphQLQuery, GraphQLMutation, GraphQLSubscription:
+				if pending == nil && !fragment {
+					pending, expectName = &graphQLOperation{typ: name}, true
+				}
+			case "fragment":
+				fragment = true
+			default:
+				if pending == nil && !fragment {
+					return nil, false
+				}
+			}
+			continue
+		case c == '{':
+			if depth == 0 && parens == 0 {
+				switch {
+				case pending != nil:
+					ops = append(ops, *pending)
+				case !fragment:
+					ops = append(ops, graphQLOperation{typ: GraphQLQuery})
+				}
+				pending, fragment = nil, false
+			}
+			depth++
+			i++
+		case c == '}':
+			if depth--; depth < 0 {
+				return nil, false
+			}
+			i++
+		case c == '(':
+			parens++
+			i++
+		case c == ')':
+			if parens--; parens < 0 {
+				return nil, false
+			}
+			i++
+		default:
+			i++
+		}
+		expectName = false
+	}
+	if depth != 0 || parens != 0 || pending != nil || len(ops) == 0 {
+		return nil, false
+	}
+	return ops, true
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
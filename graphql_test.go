@@ -0,0 +1,152 @@
+package harlog
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestParseGraphQLOperations(t *testing.T) {
+	tests := []struct {
+		doc  string
+		want []graphQLOperation
+	}{
+		{doc: "{ viewer { login } }", want: []graphQLOperation{{typ: "query"}}},
+		{doc: "query GetUser($id: ID = \"{\") @cached { user(id: $id) { name } }", want: []graphQLOperation{{typ: "query", name: "GetUser"}}},
+		{
+			doc:  "# comment {\nfragment F on User { name }\nmutation CreateUser { createUser(input: {name: \"\"\"a \\\"\"\" }\"\"\"}) { ...F } }\nsubscription { events }",
+			want: []graphQLOperation{{typ: "mutation", name: "CreateUser"}, {typ: "subscription"}},
+		},
+		{doc: "shoes"},
+		{doc: "query { unbalanced"},
+		{doc: "fragment F on User { name }"},
+		{doc: ""},
+	}
+	for _, tt := range tests {
+		got, ok := parseGraphQLOperations(tt.doc)
+		if ok != (tt.want != nil) {
+			t.Errorf("%q: expected ok %v, got %v", tt.doc, tt.want != nil, ok)
+			continue
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%q: expected %+v, got %+v", tt.doc, tt.want, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%q: expected %+v, got %+v", tt.doc, tt.want, got)
+			}
+		}
+	}
+}
+
+func TestDetectGraphQL(t *testing.T) {
+	tests := []struct {
+		name    string
+		request HARRequest
+		body    string
+		want    *HARGraphQL
+	}{
+		{
+			name: "json",
+			request: HARRequest{PostData: &HARPostData{
+				MimeType: "application/json",
+				Text:     `{"query":"query A { a } mutation B { b }","operationName":"B","variables":{ "x": 1 }}`,
+			}},
+			body: `{"data":null,"errors":[{"message":"denied"}]}`,
+			want: &HARGraphQL{OperationName: "B", OperationType: "mutation", Variables: []byte(`{"x":1}`), Errors: 1},
+		},
+		{
+			name:    "application/graphql",
+			request: HARRequest{PostData: &HARPostData{MimeType: "application/graphql", Text: "{ a }"}},
+			body:    `{"data":{"a":1}}`,
+			want:    &HARGraphQL{OperationType: "query"},
+		},
+		{
+			name: "query string",
+			request: HARRequest{QueryString: []HARQuery{
+				{Name: "query", Value: "query Search { s }"},
+				{Name: "variables", Value: "null"},
+			}},
+			want: &HARGraphQL{OperationName: "Search", OperationType: "query"},
+		},
+		{
+			name: "persisted query",
+			request: HARRequest{PostData: &HARPostData{
+				Text: `{"operationName":"Feed","extensions":{"persistedQuery":{"version":1,"sha256Hash":"abc"}}}`,
+			}},
+			want: &HARGraphQL{OperationName: "Feed"},
+		},
+		{
+			name:    "search API",
+			request: HARRequest{PostData: &HARPostData{MimeType: "application/json", Text: `{"query":"red shoes"}`}},
+		},
+		{
+			name:    "plain request",
+			request: HARRequest{QueryString: []HARQuery{{Name: "q", Value: "x"}}},
+		},
+	}
+	for _, tt := range tests {
+		entry := &HAREntry{Request: tt.request, Response: HARResponse{Content: HARContent{Text: tt.body}}}
+		detectGraphQL(entry)
+		got := entry.GraphQL
+		if (got == nil) != (tt.want == nil) {
+			t.Errorf("%s: expected %+v, got %+v", tt.name, tt.want, got)
+			continue
+		}
+		if got == nil {
+			continue
+		}
+		if got.OperationName != tt.want.OperationName || got.OperationType != tt.want.OperationType ||
+			string(got.Variables) != string(tt.want.Variables) || got.Errors != tt.want.Errors {
+			t.Errorf("%s: expected %+v, got %+v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestGraphQL_Middleware(t *testing.T) {
+	dir := t.TempDir()
+	rec := NewRecorder()
+	logger := New(WithOutputDir(dir), WithSink(rec))
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The operation is recorded without the variables
+		WithBodyCapture(r.Context(), false)
+		_, _ = io.Copy(io.Discard, r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"errors":[{"message":"not found"}]}`))
+	})
+	server := httptest.NewServer(logger.Middleware(handler))
+	defer server.Close()
+
+	body := `{"query":"query GetUser($id: ID!) { user(id: $id) { name } }","variables":{"id":"secret"}}`
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/graphql", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	entry := waitEntry(t, rec, http.MethodPost, "/graphql")
+	op := entry.GraphQL
+	if op == nil || op.OperationName != "GetUser" || op.OperationType != GraphQLQuery || op.Errors != 1 {
+		t.Fatalf("expected GraphQL operation with errors, got %+v", op)
+	}
+	if op.Variables != nil || entry.Request.PostData.Text != "" {
+		t.Errorf("expected variables to be dropped with the body, got %s", op.Variables)
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || !strings.HasSuffix(files[0].Name(), "-POST-"+strings.ReplaceAll(strings.TrimPrefix(server.URL, "http://"), ":", "-")+"-graphql-GetUser.har") {
+		t.Errorf("expected file named after the operation, got %v", files)
+	}
+}
@@ -1,29 +1,146 @@
 package harlog
 
 import (
+	"bufio"
 	"bytes"
 	"io"
+	"net"
 	"net/http"
+	"sync"
 	"time"
 )
 
 // responseWriter is a wrapper for http.ResponseWriter that captures the response
 type responseWriter struct {
 	http.ResponseWriter
-	statusCode int
-	body       []byte
+	statusCode  int
+	wroteHeader bool
+	body        []byte
+
+	// informational holds the 1xx responses sent before the final one
+	informational []HARInformational
+
+	// ws records the frames of a hijacked WebSocket connection. It is
+	// created by newWSRecorder when the connection is hijacked, if the
+	// request is captured by then.
+	ws            *wsRecorder
+	newWSRecorder func() *wsRecorder
+	hijacked      bool
+
+	// events parses a text/event-stream response instead of keeping its body.
+	// It is created by newEventStream when such a response starts.
+	events         *eventStream
+	newEventStream func() *eventStream
 }
 
 func (rw *responseWriter) WriteHeader(statusCode int) {
+	// Informational responses such as 103 Early Hints precede the final one
+	if statusCode >= 100 && statusCode < 200 && statusCode != http.StatusSwitchingProtocols && !rw.wroteHeader {
+		headers, _ := splitTrailers(rw.Header())
+		rw.informational = append(rw.informational, newInformational(statusCode, headers))
+		rw.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
 	rw.statusCode = statusCode
+	rw.detectEventStream()
+	rw.wroteHeader = true
 	rw.ResponseWriter.WriteHeader(statusCode)
 }
 
 func (rw *responseWriter) Write(b []byte) (int, error) {
-	rw.body = append(rw.body, b...)
+	rw.detectEventStream()
+	rw.wroteHeader = true
+	if rw.events != nil {
+		_, _ = rw.events.Write(b)
+	} else {
+		rw.body = append(rw.body, b...)
+	}
 	return rw.ResponseWriter.Write(b)
 }
 
+// detectEventStream checks the headers when the response starts
+func (rw *responseWriter) detectEventStream() {
+	if rw.wroteHeader || !isEventStream(rw.Header()) {
+		return
+	}
+	rw.events = rw.newEventStream()
+}
+
+// Flush sends buffered data to the client so that streaming handlers work
+// behind Middleware
+func (rw *responseWriter) Flush() {
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the underlying ResponseWriter for http.ResponseController
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// Hijack implements http.Hijacker. Frames sent over a hijacked WebSocket
+// connection are recorded until the connection is closed.
+func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := http.NewResponseController(rw.ResponseWriter).Hijack()
+	if err != nil || rw.newWSRecorder == nil {
+		return conn, brw, err
+	}
+	if rw.ws = rw.newWSRecorder(); rw.ws == nil {
+		return conn, brw, err
+	}
+	rw.hijacked = true
+
+	// Unless sent with WriteHeader, the handshake response is written to the
+	// connection by the handler
+	wc := rw.ws.serverConn(conn, brw.Reader, !rw.wroteHeader)
+	return wc, bufio.NewReadWriter(bufio.NewReader(wc), bufio.NewWriter(wc)), nil
+}
+
+// requestBody passes the body of a request through to the handler, keeping
+// a copy of what it reads if start reports so on the first read
+type requestBody struct {
+	io.ReadCloser
+	start func() bool
+
+	mu   sync.Mutex
+	buf  *bytes.Buffer
+	once sync.Once
+}
+
+func (b *requestBody) Read(p []byte) (int, error) {
+	b.once.Do(func() {
+		if b.start != nil && b.start() {
+			b.mu.Lock()
+			b.buf = &bytes.Buffer{}
+			b.mu.Unlock()
+		}
+	})
+	n, err := b.ReadCloser.Read(p)
+	if b.buf != nil {
+		b.mu.Lock()
+		b.buf.Write(p[:n])
+		b.mu.Unlock()
+	}
+	return n, err
+}
+
+// record sets the body read so far as the post data of req. Bodies that are
+// not read or not kept are recorded as empty.
+func (b *requestBody) record(req *HARRequest, mimeType string) {
+	if b.ReadCloser == nil || req.Method == http.MethodGet {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	req.PostData = &HARPostData{MimeType: mimeType}
+	req.BodySize = 0
+	if b.buf != nil {
+		req.PostData.Text = b.buf.String()
+		req.BodySize = b.buf.Len()
+	}
+}
+
 // ServeHTTP implements http.Handler interface for backward compatibility
 func (l *Logger) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if l.handler == nil {
@@ -35,9 +152,13 @@ func (l *Logger) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 // Middleware creates a new middleware handler
 func (l *Logger) Middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, ctrl := withMutableControl(r.Context())
+		r = r.WithContext(ctx)
+
 		start := time.Now()
 		harEntry := &HAREntry{
 			StartedDateTime: start.Format(time.RFC3339),
+			TLS:             captureTLS(r.TLS),
 		}
 
 		// Create a response wrapper to capture the response
@@ -47,31 +168,111 @@ func (l *Logger) Middleware(next http.Handler) http.Handler {
 			body:           make([]byte, 0),
 		}
 
-		// Record request
-		harEntry.Request = l.captureRequest(r)
+		// Record request. The body is copied as the handler reads it, so that
+		// uploads are streamed and the handler may reject them unread. Whether
+		// it is kept is decided on the first read, after the handler has had
+		// the chance to opt in.
+		harEntry.Request = l.captureRequest(r, false)
+		body := &requestBody{ReadCloser: r.Body}
+		if r.Body != nil {
+			continues := expectsContinue(r) && r.Body != http.NoBody
+			body.start = func() bool {
+				// Reading the body sends 100 Continue to the client, unless the
+				// final response has already started
+				if continues && !rw.wroteHeader {
+					rw.informational = append(rw.informational, newInformational(http.StatusContinue, nil))
+				}
+				// Bodies are kept even without body capture, which drops them
+				// once the GraphQL operation has been read from them
+				return l.shouldCapture(ctrl) && r.Method != http.MethodGet
+			}
+			r.Body = body
+		}
+		if isWebSocketUpgrade(r) {
+			// Offers of extensions are removed before the handler sees them
+			if l.shouldCapture(ctrl) {
+				r = l.withoutWebSocketExtensions(r)
+			}
+			rw.newWSRecorder = func() *wsRecorder {
+				if !l.shouldCapture(ctrl) {
+					return nil
+				}
+				return l.newWSRecorder()
+			}
+		}
+
+		// Save long-lived event streams at checkpoints
+		var checkpointPath string
+		rw.newEventStream = func() *eventStream {
+			stream := l.newEventStream()
+			resp := l.captureResponse(rw)
+			stream.startCheckpoints(l.eventStreamCheckpoint, func() {
+				if !l.shouldCapture(ctrl) {
+					return
+				}
+				snapshot := *harEntry
+				body.record(&snapshot.Request, r.Header.Get("Content-Type"))
+				snapshot.Response = resp
+				stream.apply(&snapshot)
+				snapshot.Time = float64(time.Since(start).Milliseconds())
+				checkpointPath = l.checkpointEntry(r, l.inboundEntry(ctrl, &snapshot), checkpointPath)
+			})
+			return stream
+		}
 
 		// Call the next handler
 		next.ServeHTTP(rw, r)
+		if rw.events != nil {
+			rw.events.close()
+		}
+		body.record(&harEntry.Request, r.Header.Get("Content-Type"))
+
+		// A WebSocket connection may outlive the handler, so its entry is saved
+		// once the connection is closed
+		if rw.hijacked {
+			harEntry.Time = float64(time.Since(start).Milliseconds())
+			rw.ws.whenClosed(func(messages []HARWebSocketMessage, dropped int) {
+				if !l.shouldCapture(ctrl) {
+					return
+				}
+				harEntry.Response = l.captureHandshake(rw)
+				if !negotiatedWebSocketExtension(harEntry.Response.Headers) {
+					harEntry.WebSocketMessages, harEntry.WebSocketMessagesDropped = messages, dropped
+				}
+				l.writeEntry(r, l.inboundEntry(ctrl, harEntry))
+			})
+			return
+		}
+
+		// The handler may have opted out of logging via Skip
+		if !l.shouldCapture(ctrl) {
+			return
+		}
 
 		// Record response
 		harEntry.Response = l.captureResponse(rw)
-		harEntry.Time = float64(time.Since(start).Milliseconds())
-
-		// Save HAR entry
-		if err := l.saveHAR(r, harEntry); err != nil {
-			l.logger.Error("failed to save HAR",
-				"error", err,
-				"path", r.URL.Path,
-				"method", r.Method,
-				"host", r.Host,
-			)
+		if rw.events != nil {
+			rw.events.apply(harEntry)
 		}
+		harEntry.Time = float64(time.Since(start).Milliseconds())
+		l.writeEntryTo(r, l.inboundEntry(ctrl, harEntry), checkpointPath)
 	})
 }
 
-func (l *Logger) captureRequest(r *http.Request) HARRequest {
+// inboundEntry applies the per-request settings to entry, recorded for a
+// received request, and returns it
+func (l *Logger) inboundEntry(ctrl *captureControl, entry *HAREntry) *HAREntry {
+	l.applyControl(ctrl, entry)
+	if entry.Link != nil {
+		entry.Link.Role = LinkInbound
+	}
+	return entry
+}
+
+// convertHeaders converts http.Header to a list of HAR headers
+func convertHeaders(h http.Header) []HARHeader {
 	headers := make([]HARHeader, 0)
-	for name, values := range r.Header {
+	for name, values := range h {
 		for _, value := range values {
 			headers = append(headers, HARHeader{
 				Name:  name,
@@ -79,6 +280,11 @@ func (l *Logger) captureRequest(r *http.Request) HARRequest {
 			})
 		}
 	}
+	return headers
+}
+
+func (l *Logger) captureRequest(r *http.Request, withBody bool) HARRequest {
+	headers := convertHeaders(r.Header)
 
 	queryString := make([]HARQuery, 0)
 	for name, values := range r.URL.Query() {
@@ -101,7 +307,7 @@ func (l *Logger) captureRequest(r *http.Request) HARRequest {
 	}
 
 	// Capture request body if present
-	if r.Body != nil && r.Method != http.MethodGet {
+	if withBody && r.Body != nil && r.Method != http.MethodGet {
 		body, err := io.ReadAll(r.Body)
 		if err == nil {
 			req.PostData = &HARPostData{
@@ -117,28 +323,44 @@ func (l *Logger) captureRequest(r *http.Request) HARRequest {
 	return req
 }
 
-func (l *Logger) captureResponse(rw *responseWriter) HARResponse {
-	headers := make([]HARHeader, 0)
-	for name, values := range rw.Header() {
-		for _, value := range values {
-			headers = append(headers, HARHeader{
-				Name:  name,
-				Value: value,
-			})
-		}
+// captureHandshake records the response to a WebSocket handshake hijacked
+// from rw
+func (l *Logger) captureHandshake(rw *responseWriter) HARResponse {
+	resp := rw.ws.handshakeResponse()
+	if resp == nil {
+		rw.body = nil
+		return l.captureResponse(rw)
 	}
+	return HARResponse{
+		Status:      resp.StatusCode,
+		StatusText:  http.StatusText(resp.StatusCode),
+		HTTPVersion: resp.Proto,
+		Headers:     convertHeaders(resp.Header),
+		Content: HARContent{
+			MimeType: resp.Header.Get("Content-Type"),
+		},
+		RedirectURL: resp.Header.Get("Location"),
+		HeadersSize: -1, // Not implemented
+	}
+}
+
+func (l *Logger) captureResponse(rw *responseWriter) HARResponse {
+	headers, trailers := splitTrailers(rw.Header())
 
 	return HARResponse{
 		Status:      rw.statusCode,
 		StatusText:  http.StatusText(rw.statusCode),
 		HTTPVersion: "HTTP/1.1",
-		Headers:     headers,
+		Headers:     convertHeaders(headers),
+		Trailers:    convertHeaders(trailers),
 		Content: HARContent{
 			Size:     len(rw.body),
 			MimeType: rw.Header().Get("Content-Type"),
 			Text:     string(rw.body),
 		},
-		HeadersSize: -1, // Not implemented
-		BodySize:    len(rw.body),
+		RedirectURL:   headers.Get("Location"),
+		HeadersSize:   -1, // Not implemented
+		BodySize:      len(rw.body),
+		Informational: rw.informational,
 	}
 }
@@ -0,0 +1,143 @@
+package hardiff
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/m-mizutani/harlog"
+)
+
+// CompareBodies reports the differences between two response bodies in the
+// same way as Compare. Only WithBodyValues affects the result.
+func CompareBodies(base, target *harlog.HARContent, opts ...Option) []BodyChange {
+	c := &comparer{ignoredHeaders: make(map[string]bool)}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c.compareBodies(base, target)
+}
+
+func (c *comparer) compareBodies(base, target *harlog.HARContent) []BodyChange {
+	bv, bJSON := decodeJSON(base.Text)
+	tv, tJSON := decodeJSON(target.Text)
+
+	switch {
+	case bJSON && tJSON:
+		return c.compareValues("$", bv, tv, nil)
+
+	case bJSON != tJSON:
+		return []BodyChange{{
+			Path:   "$",
+			Kind:   KindType,
+			Base:   bodyKind(base.Text, bJSON),
+			Target: bodyKind(target.Text, tJSON),
+		}}
+
+	case c.bodyValues && base.Text != target.Text:
+		return []BodyChange{{Path: "$", Kind: KindChanged}}
+
+	default:
+		return nil
+	}
+}
+
+func decodeJSON(text string) (any, bool) {
+	if text == "" {
+		return nil, false
+	}
+	decoder := json.NewDecoder(bytes.NewReader([]byte(text)))
+	decoder.UseNumber()
+	var v any
+	if err := decoder.Decode(&v); err != nil {
+		return nil, false
+	}
+	return v, true
+}
+
+func bodyKind(text string, isJSON bool) string {
+	switch {
+	case isJSON:
+		return "json"
+	case text == "":
+		return "empty"
+	default:
+		return "text"
+	}
+}
+
+// compareValues appends differences between two decoded JSON values to
+// changes. Arrays are compared by the structure of their first element.
+func (c *comparer) compareValues(path string, base, target any, changes []BodyChange) []BodyChange {
+	bt, tt := jsonType(base), jsonType(target)
+	if bt != tt {
+		return append(changes, BodyChange{Path: path, Kind: KindType, Base: bt, Target: tt})
+	}
+
+	switch b := base.(type) {
+	case map[string]any:
+		t := target.(map[string]any)
+		keys := make([]string, 0, len(b)+len(t))
+		for key := range b {
+			keys = append(keys, key)
+		}
+		for key := range t {
+			if _, ok := b[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			bv, inBase := b[key]
+			tv, inTarget := t[key]
+			p := path + "." + key
+			switch {
+			case !inTarget:
+				changes = append(changes, BodyChange{Path: p, Kind: KindRemoved, Base: jsonType(bv)})
+			case !inBase:
+				changes = append(changes, BodyChange{Path: p, Kind: KindAdded, Target: jsonType(tv)})
+			default:
+				changes = c.compareValues(p, bv, tv, changes)
+			}
+		}
+
+	case []any:
+		t := target.([]any)
+		if len(b) > 0 && len(t) > 0 {
+			changes = c.compareValues(path+"[]", b[0], t[0], changes)
+		}
+
+	default:
+		if c.bodyValues && fmt.Sprint(base) != fmt.Sprint(target) {
+			changes = append(changes, BodyChange{
+				Path:   path,
+				Kind:   KindChanged,
+				Base:   fmt.Sprint(base),
+				Target: fmt.Sprint(target),
+			})
+		}
+	}
+
+	return changes
+}
+
+func jsonType(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,304 @@
+// Package hardiff compares two HAR captures of the same scenario and reports
+// behavioural changes between them.
+//
+// Entries are aligned by method, host and URL path template (see
+// harlog.PathTemplate) and by their order of occurrence. Aligned entries are
+// compared for status changes, response header differences, structural JSON
+// body differences and latency regressions.
+package hardiff
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/m-mizutani/harlog"
+)
+
+// DefaultIgnoredHeaders are response headers whose values are expected to
+// change between captures
+var DefaultIgnoredHeaders = []string{
+	"Age", "Cf-Ray", "Content-Length", "Date", "Etag", "Expires",
+	"Last-Modified", "Nel", "Report-To", "Server-Timing", "Set-Cookie",
+	"X-Amzn-Trace-Id", "X-Request-Id",
+}
+
+// Option represents a configuration option for Compare
+type Option func(*comparer)
+
+// WithIgnoredHeaders adds response headers to exclude from comparison
+func WithIgnoredHeaders(names ...string) Option {
+	return func(c *comparer) {
+		for _, name := range names {
+			c.ignoredHeaders[http.CanonicalHeaderKey(name)] = true
+		}
+	}
+}
+
+// WithoutDefaultIgnoredHeaders compares headers listed in
+// DefaultIgnoredHeaders as well
+func WithoutDefaultIgnoredHeaders() Option {
+	return func(c *comparer) {
+		for _, name := range DefaultIgnoredHeaders {
+			delete(c.ignoredHeaders, http.CanonicalHeaderKey(name))
+		}
+	}
+}
+
+// WithLatencyThreshold sets when a slower response is reported as a
+// regression: the target time must exceed the base time by the given ratio
+// and by at least minDelta (default: 0.5 and 100ms)
+func WithLatencyThreshold(ratio float64, minDelta time.Duration) Option {
+	return func(c *comparer) {
+		c.latencyRatio = ratio
+		c.latencyMinDelta = minDelta
+	}
+}
+
+// WithBodyValues reports changed JSON values and changed non-JSON bodies in
+// addition to structural differences
+func WithBodyValues() Option {
+	return func(c *comparer) {
+		c.bodyValues = true
+	}
+}
+
+type comparer struct {
+	ignoredHeaders  map[string]bool
+	latencyRatio    float64
+	latencyMinDelta time.Duration
+	bodyValues      bool
+}
+
+// Result represents the differences between two HAR captures
+type Result struct {
+	Added   []EntryRef  `json:"added,omitempty"`
+	Removed []EntryRef  `json:"removed,omitempty"`
+	Changed []EntryDiff `json:"changed,omitempty"`
+}
+
+// HasChanges reports whether any difference was found
+func (r *Result) HasChanges() bool {
+	return len(r.Added) > 0 || len(r.Removed) > 0 || len(r.Changed) > 0
+}
+
+// EntryRef identifies an entry in one of the captures
+type EntryRef struct {
+	Index  int    `json:"index"`
+	Method string `json:"method"`
+	URL    string `json:"url"`
+}
+
+// EntryDiff represents the differences between two aligned entries
+type EntryDiff struct {
+	Key     string         `json:"key"`
+	Base    EntryRef       `json:"base"`
+	Target  EntryRef       `json:"target"`
+	Status  *StatusChange  `json:"status,omitempty"`
+	Headers []HeaderChange `json:"headers,omitempty"`
+	Body    []BodyChange   `json:"body,omitempty"`
+	Latency *LatencyChange `json:"latency,omitempty"`
+}
+
+// StatusChange represents a changed response status code
+type StatusChange struct {
+	Base   int `json:"base"`
+	Target int `json:"target"`
+}
+
+// Change kinds of headers and body elements
+const (
+	KindAdded   = "added"
+	KindRemoved = "removed"
+	KindChanged = "changed"
+	KindType    = "type"
+)
+
+// HeaderChange represents a response header that was added, removed or
+// changed
+type HeaderChange struct {
+	Name   string `json:"name"`
+	Kind   string `json:"kind"`
+	Base   string `json:"base,omitempty"`
+	Target string `json:"target,omitempty"`
+}
+
+// BodyChange represents a difference in the response body. Path is a JSON
+// path such as "$.users[].name"; for type changes Base and Target hold JSON
+// type names.
+type BodyChange struct {
+	Path   string `json:"path"`
+	Kind   string `json:"kind"`
+	Base   string `json:"base,omitempty"`
+	Target string `json:"target,omitempty"`
+}
+
+// LatencyChange represents a latency regression
+type LatencyChange struct {
+	Base   time.Duration `json:"base"`
+	Target time.Duration `json:"target"`
+}
+
+// Compare reports the differences from base to target
+func Compare(base, target *harlog.HAR, opts ...Option) *Result {
+	c := &comparer{
+		ignoredHeaders:  make(map[string]bool),
+		latencyRatio:    0.5,
+		latencyMinDelta: 100 * time.Millisecond,
+	}
+	for _, name := range DefaultIgnoredHeaders {
+		c.ignoredHeaders[http.CanonicalHeaderKey(name)] = true
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	baseGroups, baseKeys := groupEntries(base)
+	targetGroups, targetKeys := groupEntries(target)
+
+	result := &Result{}
+	for _, key := range mergeKeys(baseKeys, targetKeys) {
+		b, t := baseGroups[key], targetGroups[key]
+		for i := 0; i < len(b) || i < len(t); i++ {
+			switch {
+			case i >= len(t):
+				result.Removed = append(result.Removed, newRef(base, b[i]))
+			case i >= len(b):
+				result.Added = append(result.Added, newRef(target, t[i]))
+			default:
+				if d := c.compareEntries(base, target, b[i], t[i]); d != nil {
+					d.Key = key + "#" + strconv.Itoa(i)
+					result.Changed = append(result.Changed, *d)
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(result.Added, func(i, j int) bool { return result.Added[i].Index < result.Added[j].Index })
+	sort.SliceStable(result.Removed, func(i, j int) bool { return result.Removed[i].Index < result.Removed[j].Index })
+	sort.SliceStable(result.Changed, func(i, j int) bool { return result.Changed[i].Base.Index < result.Changed[j].Base.Index })
+
+	return result
+}
+
+// EntryKey returns the key used to align entries: the method, host and path
+// template of the request URL
+func EntryKey(entry *harlog.HAREntry) string {
+	u, err := url.Parse(entry.Request.URL)
+	if err != nil {
+		return entry.Request.Method + " " + entry.Request.URL
+	}
+	return entry.Request.Method + " " + u.Host + harlog.PathTemplate(u.Path)
+}
+
+// groupEntries returns entry indexes grouped by key, and keys in order of
+// first occurrence
+func groupEntries(har *harlog.HAR) (map[string][]int, []string) {
+	groups := make(map[string][]int)
+	var keys []string
+	for i := range har.Log.Entries {
+		key := EntryKey(&har.Log.Entries[i])
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], i)
+	}
+	return groups, keys
+}
+
+func mergeKeys(a, b []string) []string {
+	seen := make(map[string]bool, len(a))
+	keys := make([]string, 0, len(a)+len(b))
+	for _, key := range append(append([]string{}, a...), b...) {
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func newRef(har *harlog.HAR, index int) EntryRef {
+	entry := &har.Log.Entries[index]
+	return EntryRef{
+		Index:  index,
+		Method: entry.Request.Method,
+		URL:    entry.Request.URL,
+	}
+}
+
+func (c *comparer) compareEntries(base, target *harlog.HAR, bi, ti int) *EntryDiff {
+	b, t := &base.Log.Entries[bi], &target.Log.Entries[ti]
+	d := &EntryDiff{
+		Base:   newRef(base, bi),
+		Target: newRef(target, ti),
+	}
+
+	if b.Response.Status != t.Response.Status {
+		d.Status = &StatusChange{Base: b.Response.Status, Target: t.Response.Status}
+	}
+	d.Headers = c.compareHeaders(b.Response.Headers, t.Response.Headers)
+	d.Body = c.compareBodies(&b.Response.Content, &t.Response.Content)
+
+	bt := time.Duration(b.Time * float64(time.Millisecond))
+	tt := time.Duration(t.Time * float64(time.Millisecond))
+	if tt-bt >= c.latencyMinDelta && float64(tt) > float64(bt)*(1+c.latencyRatio) {
+		d.Latency = &LatencyChange{Base: bt, Target: tt}
+	}
+
+	if d.Status == nil && len(d.Headers) == 0 && len(d.Body) == 0 && d.Latency == nil {
+		return nil
+	}
+	return d
+}
+
+func (c *comparer) compareHeaders(base, target []harlog.HARHeader) []HeaderChange {
+	b, t := c.headerMap(base), c.headerMap(target)
+
+	names := make([]string, 0, len(b)+len(t))
+	for name := range b {
+		names = append(names, name)
+	}
+	for name := range t {
+		if _, ok := b[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var changes []HeaderChange
+	for _, name := range names {
+		bv, inBase := b[name]
+		tv, inTarget := t[name]
+		switch {
+		case !inTarget:
+			changes = append(changes, HeaderChange{Name: name, Kind: KindRemoved, Base: bv})
+		case !inBase:
+			changes = append(changes, HeaderChange{Name: name, Kind: KindAdded, Target: tv})
+		case bv != tv:
+			changes = append(changes, HeaderChange{Name: name, Kind: KindChanged, Base: bv, Target: tv})
+		}
+	}
+	return changes
+}
+
+// headerMap joins values of each header, skipping ignored and HTTP/2
+// pseudo-headers
+func (c *comparer) headerMap(headers []harlog.HARHeader) map[string]string {
+	m := make(map[string]string)
+	for _, h := range headers {
+		name := http.CanonicalHeaderKey(h.Name)
+		if c.ignoredHeaders[name] || strings.HasPrefix(name, ":") {
+			continue
+		}
+		if v, ok := m[name]; ok {
+			m[name] = v + ", " + h.Value
+		} else {
+			m[name] = h.Value
+		}
+	}
+	return m
+}
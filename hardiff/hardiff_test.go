@@ -0,0 +1,154 @@
+package hardiff
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/m-mizutani/harlog"
+)
+
+func newEntry(method, rawURL string, status int, elapsed float64, body string, headers ...harlog.HARHeader) harlog.HAREntry {
+	return harlog.HAREntry{
+		Time: elapsed,
+		Request: harlog.HARRequest{
+			Method: method,
+			URL:    rawURL,
+		},
+		Response: harlog.HARResponse{
+			Status:  status,
+			Headers: headers,
+			Content: harlog.HARContent{Text: body},
+		},
+	}
+}
+
+func newHAR(entries ...harlog.HAREntry) *harlog.HAR {
+	return &harlog.HAR{Log: harlog.HARLog{Version: "1.2", Entries: entries}}
+}
+
+func TestCompare(t *testing.T) {
+	base := newHAR(
+		newEntry("GET", "https://api.example.com/users/1", 200, 100, `{"id": 1, "name": "alice", "age": 20}`,
+			harlog.HARHeader{Name: "Content-Type", Value: "application/json"},
+			harlog.HARHeader{Name: "Date", Value: "Sun, 09 Mar 2025 02:49:14 GMT"},
+			harlog.HARHeader{Name: "X-Old", Value: "1"},
+		),
+		newEntry("GET", "https://api.example.com/health", 200, 10, "ok"),
+		newEntry("DELETE", "https://api.example.com/users/1", 204, 50, ""),
+	)
+	target := newHAR(
+		newEntry("GET", "https://api.example.com/health", 200, 10, "ok"),
+		newEntry("GET", "https://api.example.com/users/2", 500, 400, `{"id": 2, "name": "bob", "age": "20", "email": "b@example.com"}`,
+			harlog.HARHeader{Name: "Content-Type", Value: "application/json; charset=utf-8"},
+			harlog.HARHeader{Name: "Date", Value: "Mon, 10 Mar 2025 02:49:14 GMT"},
+		),
+		newEntry("POST", "https://api.example.com/users", 201, 50, ""),
+	)
+
+	result := Compare(base, target)
+	if !result.HasChanges() {
+		t.Fatal("expected changes")
+	}
+
+	if len(result.Removed) != 1 || result.Removed[0].Method != "DELETE" {
+		t.Errorf("unexpected removed entries: %+v", result.Removed)
+	}
+	if len(result.Added) != 1 || result.Added[0].Method != "POST" {
+		t.Errorf("unexpected added entries: %+v", result.Added)
+	}
+	if len(result.Changed) != 1 {
+		t.Fatalf("expected 1 changed entry, got %+v", result.Changed)
+	}
+
+	d := result.Changed[0]
+	if d.Key != "GET api.example.com/users/{userId}#0" {
+		t.Errorf("unexpected key: %s", d.Key)
+	}
+	if d.Status == nil || d.Status.Base != 200 || d.Status.Target != 500 {
+		t.Errorf("unexpected status change: %+v", d.Status)
+	}
+
+	wantHeaders := []HeaderChange{
+		{Name: "Content-Type", Kind: KindChanged, Base: "application/json", Target: "application/json; charset=utf-8"},
+		{Name: "X-Old", Kind: KindRemoved, Base: "1"},
+	}
+	if len(d.Headers) != len(wantHeaders) {
+		t.Fatalf("unexpected header changes: %+v", d.Headers)
+	}
+	for i, want := range wantHeaders {
+		if d.Headers[i] != want {
+			t.Errorf("header change %d: expected %+v, got %+v", i, want, d.Headers[i])
+		}
+	}
+
+	wantBody := []BodyChange{
+		{Path: "$.age", Kind: KindType, Base: "number", Target: "string"},
+		{Path: "$.email", Kind: KindAdded, Target: "string"},
+	}
+	if len(d.Body) != len(wantBody) {
+		t.Fatalf("unexpected body changes: %+v", d.Body)
+	}
+	for i, want := range wantBody {
+		if d.Body[i] != want {
+			t.Errorf("body change %d: expected %+v, got %+v", i, want, d.Body[i])
+		}
+	}
+
+	if d.Latency == nil || d.Latency.Target != 400*time.Millisecond {
+		t.Errorf("unexpected latency change: %+v", d.Latency)
+	}
+
+	var buf bytes.Buffer
+	if err := result.WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"- DELETE https://api.example.com/users/1",
+		"+ POST https://api.example.com/users",
+		"~ GET https://api.example.com/users/2",
+		"status: 200 -> 500",
+		"body $.age: type number -> string",
+		"latency: 100ms -> 400ms (+300%)",
+		"1 added, 1 removed, 1 changed",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("report does not contain %q:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestCompare_Options(t *testing.T) {
+	base := newHAR(newEntry("GET", "https://example.com/", 200, 100, `{"name": "alice", "items": [{"id": 1}]}`,
+		harlog.HARHeader{Name: "X-Version", Value: "1"},
+	))
+	target := newHAR(newEntry("GET", "https://example.com/", 200, 180, `{"name": "bob", "items": [{"id": "1"}]}`,
+		harlog.HARHeader{Name: "X-Version", Value: "2"},
+	))
+
+	result := Compare(base, target, WithIgnoredHeaders("x-version"))
+	if len(result.Changed) != 1 {
+		t.Fatalf("expected 1 changed entry, got %+v", result)
+	}
+	d := result.Changed[0]
+	if len(d.Headers) != 0 || d.Latency != nil {
+		t.Errorf("unexpected changes: %+v", d)
+	}
+	if len(d.Body) != 1 || d.Body[0].Path != "$.items[].id" {
+		t.Errorf("unexpected body changes: %+v", d.Body)
+	}
+
+	result = Compare(base, target, WithBodyValues(), WithLatencyThreshold(0.5, 50*time.Millisecond))
+	d = result.Changed[0]
+	if len(d.Body) != 2 || d.Body[1] != (BodyChange{Path: "$.name", Kind: KindChanged, Base: "alice", Target: "bob"}) {
+		t.Errorf("unexpected body changes: %+v", d.Body)
+	}
+	if d.Latency == nil {
+		t.Error("expected latency regression")
+	}
+
+	if Compare(base, base).HasChanges() {
+		t.Error("identical captures should have no changes")
+	}
+}
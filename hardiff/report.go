@@ -0,0 +1,69 @@
+package hardiff
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"time"
+)
+
+// WriteText writes a human readable report. Lines start with "+" for added
+// requests, "-" for removed requests and "~" for changed requests, followed
+// by a summary line.
+func (r *Result) WriteText(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	for _, ref := range r.Removed {
+		fmt.Fprintf(bw, "- %s %s (removed, base #%d)\n", ref.Method, ref.URL, ref.Index)
+	}
+	for _, ref := range r.Added {
+		fmt.Fprintf(bw, "+ %s %s (added, target #%d)\n", ref.Method, ref.URL, ref.Index)
+	}
+
+	for _, d := range r.Changed {
+		fmt.Fprintf(bw, "~ %s %s (base #%d, target #%d)\n", d.Target.Method, d.Target.URL, d.Base.Index, d.Target.Index)
+		if d.Status != nil {
+			fmt.Fprintf(bw, "    status: %d -> %d\n", d.Status.Base, d.Status.Target)
+		}
+		for _, h := range d.Headers {
+			switch h.Kind {
+			case KindAdded:
+				fmt.Fprintf(bw, "    header %s: added %q\n", h.Name, h.Target)
+			case KindRemoved:
+				fmt.Fprintf(bw, "    header %s: removed %q\n", h.Name, h.Base)
+			default:
+				fmt.Fprintf(bw, "    header %s: %q -> %q\n", h.Name, h.Base, h.Target)
+			}
+		}
+		for _, b := range d.Body {
+			switch b.Kind {
+			case KindAdded:
+				fmt.Fprintf(bw, "    body %s: added (%s)\n", b.Path, b.Target)
+			case KindRemoved:
+				fmt.Fprintf(bw, "    body %s: removed (%s)\n", b.Path, b.Base)
+			case KindType:
+				fmt.Fprintf(bw, "    body %s: type %s -> %s\n", b.Path, b.Base, b.Target)
+			default:
+				if b.Base == "" && b.Target == "" {
+					fmt.Fprintf(bw, "    body %s: changed\n", b.Path)
+				} else {
+					fmt.Fprintf(bw, "    body %s: %s -> %s\n", b.Path, b.Base, b.Target)
+				}
+			}
+		}
+		if l := d.Latency; l != nil {
+			fmt.Fprintf(bw, "    latency: %s -> %s (%+.0f%%)\n",
+				l.Base.Round(time.Millisecond), l.Target.Round(time.Millisecond), latencyIncrease(l))
+		}
+	}
+
+	fmt.Fprintf(bw, "%d added, %d removed, %d changed\n", len(r.Added), len(r.Removed), len(r.Changed))
+	return bw.Flush()
+}
+
+func latencyIncrease(l *LatencyChange) float64 {
+	if l.Base <= 0 {
+		return 0
+	}
+	return float64(l.Target-l.Base) / float64(l.Base) * 100
+}
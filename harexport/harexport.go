@@ -0,0 +1,296 @@
+// Package harexport converts HAR captures into Postman collections,
+// Insomnia exports and k6 load test scripts.
+//
+// Requests can be grouped into folders by host and path, and base URLs and
+// authentication headers can be replaced by variables so that the exported
+// requests can be pointed at another environment or given fresh credentials.
+package harexport
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/m-mizutani/harlog"
+)
+
+// Option represents a configuration option for the exporters
+type Option func(*config)
+
+// GroupFunc returns the folder path of an entry, outermost first
+type GroupFunc func(entry *harlog.HAREntry) []string
+
+// GroupByHost groups entries by the host of the request URL
+func GroupByHost() GroupFunc {
+	return func(entry *harlog.HAREntry) []string {
+		u, err := url.Parse(entry.Request.URL)
+		if err != nil || u.Host == "" {
+			return nil
+		}
+		return []string{u.Host}
+	}
+}
+
+// GroupByPath groups entries by the segments of the URL path template
+// without the last one, e.g. "/api/users/42" is put in folder "api/users"
+func GroupByPath() GroupFunc {
+	return func(entry *harlog.HAREntry) []string {
+		u, err := url.Parse(entry.Request.URL)
+		if err != nil {
+			return nil
+		}
+		segments := strings.Split(strings.Trim(harlog.PathTemplate(u.Path), "/"), "/")
+		return segments[:len(segments)-1]
+	}
+}
+
+// WithName sets the name of the collection, workspace or script
+// (default: "harlog")
+func WithName(name string) Option {
+	return func(c *config) {
+		c.name = name
+	}
+}
+
+// WithGroupBy puts requests in nested folders. Folders returned by the given
+// functions are concatenated, e.g. WithGroupBy(GroupByHost(), GroupByPath()).
+func WithGroupBy(fns ...GroupFunc) Option {
+	return func(c *config) {
+		c.groupBy = append(c.groupBy, fns...)
+	}
+}
+
+// WithBaseURLVariables replaces the scheme and host of request URLs with
+// variables, named baseUrl for the first host, baseUrl2 for the second and so
+// on. The recorded values are used as initial values of the variables.
+func WithBaseURLVariables() Option {
+	return func(c *config) {
+		c.baseURLVariables = true
+	}
+}
+
+// WithAuthVariables replaces the values of the given request headers with
+// variables named after the header, e.g. authorization or xApiKey
+// (default: Authorization)
+func WithAuthVariables(headers ...string) Option {
+	return func(c *config) {
+		if len(headers) == 0 {
+			headers = []string{"Authorization"}
+		}
+		for _, name := range headers {
+			c.authHeaders[http.CanonicalHeaderKey(name)] = true
+		}
+	}
+}
+
+// WithExamples adds the recorded responses as examples of Postman requests
+func WithExamples() Option {
+	return func(c *config) {
+		c.examples = true
+	}
+}
+
+type config struct {
+	name             string
+	groupBy          []GroupFunc
+	baseURLVariables bool
+	authHeaders      map[string]bool
+	examples         bool
+}
+
+// variable is a collection variable with its initial value
+type variable struct {
+	name  string
+	value string
+}
+
+// header is a request header whose value is either a literal or a variable
+type header struct {
+	name     string
+	value    string
+	variable string
+}
+
+// request is a format independent representation of an exported request
+type request struct {
+	name    string
+	method  string
+	url     *url.URL
+	baseVar string
+	headers []header
+	body    *harlog.HARPostData
+	entry   *harlog.HAREntry
+}
+
+// urlWithRef returns the request URL, with the scheme and host replaced by
+// ref(baseVar) if a base URL variable is used
+func (r *request) urlWithRef(ref func(name string) string) string {
+	if r.baseVar == "" {
+		return r.url.String()
+	}
+	rest := *r.url
+	rest.Scheme, rest.Host, rest.User = "", "", nil
+	return ref(r.baseVar) + rest.String()
+}
+
+// folder is a node of the request tree
+type folder struct {
+	name     string
+	folders  []*folder
+	requests []*request
+}
+
+func (f *folder) child(name string) *folder {
+	for _, sub := range f.folders {
+		if sub.name == name {
+			return sub
+		}
+	}
+	sub := &folder{name: name}
+	f.folders = append(f.folders, sub)
+	return sub
+}
+
+// collection is the format independent result of converting a HAR log
+type collection struct {
+	name      string
+	variables []variable
+	root      *folder
+	examples  bool
+}
+
+func newCollection(har *harlog.HAR, opts []Option) *collection {
+	cfg := &config{
+		name:        "harlog",
+		authHeaders: make(map[string]bool),
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	c := &collection{name: cfg.name, root: &folder{}, examples: cfg.examples}
+	baseVars := make(map[string]string)
+	authVars := make(map[string]string)
+
+	for i := range har.Log.Entries {
+		entry := &har.Log.Entries[i]
+		u, err := url.Parse(entry.Request.URL)
+		if err != nil {
+			continue
+		}
+
+		req := &request{
+			name:   entry.Request.Method + " " + u.EscapedPath(),
+			method: entry.Request.Method,
+			url:    u,
+			body:   entry.Request.PostData,
+			entry:  entry,
+		}
+		if req.body != nil && req.body.Text == "" {
+			req.body = nil
+		}
+
+		if cfg.baseURLVariables && u.Host != "" {
+			origin := u.Scheme + "://" + u.Host
+			if _, ok := baseVars[origin]; !ok {
+				baseVars[origin] = c.addVariable("baseUrl", origin)
+			}
+			req.baseVar = baseVars[origin]
+		}
+
+		for _, h := range exportHeaders(entry.Request.Headers) {
+			name := http.CanonicalHeaderKey(h.Name)
+			if cfg.authHeaders[name] {
+				key := name + "\x00" + h.Value
+				if _, ok := authVars[key]; !ok {
+					authVars[key] = c.addVariable(variableName(name), h.Value)
+				}
+				req.headers = append(req.headers, header{name: h.Name, variable: authVars[key]})
+				continue
+			}
+			req.headers = append(req.headers, header{name: h.Name, value: h.Value})
+		}
+
+		f := c.root
+		for _, fn := range cfg.groupBy {
+			for _, name := range fn(entry) {
+				if name != "" {
+					f = f.child(name)
+				}
+			}
+		}
+		f.requests = append(f.requests, req)
+	}
+
+	return c
+}
+
+// addVariable adds a variable named base, or base followed by a number if
+// the name is taken, and returns its name
+func (c *collection) addVariable(base, value string) string {
+	name := base
+	for n := 2; c.hasVariable(name); n++ {
+		name = base + strconv.Itoa(n)
+	}
+	c.variables = append(c.variables, variable{name: name, value: value})
+	return name
+}
+
+func (c *collection) hasVariable(name string) bool {
+	for _, v := range c.variables {
+		if v.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// variableName converts a header name to a lower camel case variable name,
+// e.g. "X-Api-Key" to "xApiKey"
+func variableName(headerName string) string {
+	var b strings.Builder
+	for i, part := range strings.FieldsFunc(headerName, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		part = strings.ToLower(part)
+		if i > 0 {
+			part = strings.ToUpper(part[:1]) + part[1:]
+		}
+		b.WriteString(part)
+	}
+	return b.String()
+}
+
+// exportHeaders returns the request headers worth reproducing. Headers
+// computed by the client, such as Content-Length and HTTP/2 pseudo-headers,
+// are omitted.
+func exportHeaders(headers []harlog.HARHeader) []harlog.HARHeader {
+	exported := make([]harlog.HARHeader, 0, len(headers))
+	for _, h := range headers {
+		switch {
+		case strings.HasPrefix(h.Name, ":"):
+			continue
+		case strings.EqualFold(h.Name, "Content-Length"),
+			strings.EqualFold(h.Name, "Host"),
+			strings.EqualFold(h.Name, "Connection"):
+			continue
+		}
+		exported = append(exported, h)
+	}
+	return exported
+}
+
+func writeJSON(w io.Writer, v any) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		return fmt.Errorf("failed to encode export: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,228 @@
+package harexport
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/m-mizutani/harlog"
+)
+
+func newTestHAR() *harlog.HAR {
+	auth := harlog.HARHeader{Name: "Authorization", Value: "Bearer secret"}
+	return &harlog.HAR{
+		Log: harlog.HARLog{
+			Version: "1.2",
+			Entries: []harlog.HAREntry{
+				{
+					Request: harlog.HARRequest{
+						Method:  "GET",
+						URL:     "https://api.example.com/v1/users/42?fields=name&q=a%20b",
+						Headers: []harlog.HARHeader{auth, {Name: ":authority", Value: "api.example.com"}, {Name: "Accept", Value: "application/json"}},
+					},
+					Response: harlog.HARResponse{
+						Status:  200,
+						Headers: []harlog.HARHeader{{Name: "Content-Type", Value: "application/json"}},
+						Content: harlog.HARContent{Text: `{"id": 42}`},
+					},
+				},
+				{
+					Request: harlog.HARRequest{
+						Method:   "POST",
+						URL:      "https://api.example.com/v1/users",
+						Headers:  []harlog.HARHeader{auth, {Name: "Content-Type", Value: "application/json"}, {Name: "Content-Length", Value: "24"}},
+						PostData: &harlog.HARPostData{MimeType: "application/json", Text: `{"name": "it's \"me\""}`},
+					},
+					Response: harlog.HARResponse{Status: 201},
+				},
+				{
+					Request: harlog.HARRequest{
+						Method:  "GET",
+						URL:     "http://localhost:8080/health",
+						Headers: []harlog.HARHeader{{Name: "X-Api-Key", Value: "key"}},
+					},
+					Response: harlog.HARResponse{Status: 204},
+				},
+			},
+		},
+	}
+}
+
+var testOptions = []Option{
+	WithName("test"),
+	WithGroupBy(GroupByHost(), GroupByPath()),
+	WithBaseURLVariables(),
+	WithAuthVariables("Authorization", "x-api-key"),
+}
+
+func TestPostman(t *testing.T) {
+	pc := Postman(newTestHAR(), append(testOptions, WithExamples())...)
+
+	if pc.Info.Name != "test" || pc.Info.Schema != PostmanSchema {
+		t.Errorf("unexpected info: %+v", pc.Info)
+	}
+
+	wantVars := []PostmanVariable{
+		{Key: "baseUrl", Value: "https://api.example.com"},
+		{Key: "authorization", Value: "Bearer secret"},
+		{Key: "baseUrl2", Value: "http://localhost:8080"},
+		{Key: "xApiKey", Value: "key"},
+	}
+	if len(pc.Variable) != len(wantVars) {
+		t.Fatalf("unexpected variables: %+v", pc.Variable)
+	}
+	for i, want := range wantVars {
+		if pc.Variable[i] != want {
+			t.Errorf("variable %d: expected %+v, got %+v", i, want, pc.Variable[i])
+		}
+	}
+
+	// api.example.com / v1 / users / GET, and v1 / POST
+	if len(pc.Item) != 2 || pc.Item[0].Name != "api.example.com" || pc.Item[1].Name != "localhost:8080" {
+		t.Fatalf("unexpected folders: %+v", pc.Item)
+	}
+	v1 := pc.Item[0].Item[0]
+	if v1.Name != "v1" || len(v1.Item) != 2 || v1.Item[0].Name != "users" || v1.Item[1].Name != "POST /v1/users" {
+		t.Fatalf("unexpected v1 folder: %+v", v1)
+	}
+
+	get := v1.Item[0].Item[0]
+	if get.Name != "GET /v1/users/42" {
+		t.Errorf("unexpected name: %s", get.Name)
+	}
+	u := get.Request.URL
+	if u.Raw != "{{baseUrl}}/v1/users/42?fields=name&q=a%20b" || u.Host[0] != "{{baseUrl}}" || strings.Join(u.Path, "/") != "v1/users/42" {
+		t.Errorf("unexpected URL: %+v", u)
+	}
+	if len(u.Query) != 2 || u.Query[1] != (PostmanHeader{Key: "q", Value: "a b"}) {
+		t.Errorf("unexpected query: %+v", u.Query)
+	}
+	if len(get.Request.Header) != 2 || get.Request.Header[0] != (PostmanHeader{Key: "Authorization", Value: "{{authorization}}"}) {
+		t.Errorf("unexpected headers: %+v", get.Request.Header)
+	}
+	if len(get.Response) != 1 || get.Response[0].Code != 200 || get.Response[0].Status != "OK" || get.Response[0].Body != `{"id": 42}` {
+		t.Errorf("unexpected example: %+v", get.Response)
+	}
+
+	post := v1.Item[1].Request
+	if post.Body == nil || post.Body.Raw != `{"name": "it's \"me\""}` || post.Body.Options.Raw.Language != "json" {
+		t.Errorf("unexpected body: %+v", post.Body)
+	}
+	for _, h := range post.Header {
+		if h.Key == "Content-Length" {
+			t.Error("Content-Length must not be exported")
+		}
+	}
+}
+
+func TestPostman_WithoutOptions(t *testing.T) {
+	pc := Postman(newTestHAR())
+	if len(pc.Item) != 3 || pc.Variable != nil {
+		t.Fatalf("expected flat items without variables: %+v", pc)
+	}
+	u := pc.Item[2].Request.URL
+	if u.Raw != "http://localhost:8080/health" || u.Protocol != "http" || strings.Join(u.Host, ".") != "localhost" || u.Port != "8080" {
+		t.Errorf("unexpected URL: %+v", u)
+	}
+	if pc.Item[0].Response != nil {
+		t.Error("examples must be added only WithExamples")
+	}
+}
+
+func TestInsomnia(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteInsomnia(&buf, newTestHAR(), testOptions...); err != nil {
+		t.Fatal(err)
+	}
+	var export InsomniaExport
+	if err := json.Unmarshal(buf.Bytes(), &export); err != nil {
+		t.Fatal(err)
+	}
+
+	if export.Type != "export" || export.ExportFormat != 4 {
+		t.Errorf("unexpected export: %+v", export)
+	}
+
+	ids := make(map[string]InsomniaResource)
+	counts := make(map[string]int)
+	for _, r := range export.Resources {
+		ids[r.ID] = r
+		counts[r.Type]++
+	}
+	if counts[InsomniaWorkspace] != 1 || counts[InsomniaEnvironment] != 1 || counts[InsomniaRequestGroup] != 4 || counts[InsomniaRequest] != 3 {
+		t.Errorf("unexpected resources: %v", counts)
+	}
+
+	env := ids["env_harlog"]
+	if env.Data["baseUrl"] != "https://api.example.com" || env.Data["xApiKey"] != "key" {
+		t.Errorf("unexpected environment: %+v", env.Data)
+	}
+
+	for _, r := range export.Resources {
+		if r.Type != InsomniaRequest || r.Method != "POST" {
+			continue
+		}
+		if r.URL != "{{ _.baseUrl }}/v1/users" {
+			t.Errorf("unexpected URL: %s", r.URL)
+		}
+		if r.Headers[0].Value != "{{ _.authorization }}" {
+			t.Errorf("unexpected headers: %+v", r.Headers)
+		}
+		if r.Body == nil || r.Body.MimeType != "application/json" {
+			t.Errorf("unexpected body: %+v", r.Body)
+		}
+		if parent := ids[r.ParentID]; parent.Name != "v1" || ids[parent.ParentID].Name != "api.example.com" {
+			t.Errorf("unexpected parent: %+v", parent)
+		}
+	}
+}
+
+func TestWriteK6(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteK6(&buf, newTestHAR(), testOptions...); err != nil {
+		t.Fatal(err)
+	}
+	script := buf.String()
+
+	for _, want := range []string{
+		`const BASE_URL = __ENV.BASE_URL || "https://api.example.com";`,
+		`const BASE_URL_2 = __ENV.BASE_URL_2 || "http://localhost:8080";`,
+		`const X_API_KEY = __ENV.X_API_KEY || "key";`,
+		`group("api.example.com", function () {`,
+		`res = http.request("POST", BASE_URL + "/v1/users", "{\"name\": \"it's \\\"me\\\"\"}", {`,
+		`"Authorization": AUTHORIZATION,`,
+		`check(res, { "status is 204": (r) => r.status === 204 });`,
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("script does not contain %q:\n%s", want, script)
+		}
+	}
+
+	if _, err := exec.LookPath("node"); err != nil {
+		return
+	}
+	file := filepath.Join(t.TempDir(), "script.mjs")
+	if err := os.WriteFile(file, buf.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := exec.Command("node", "--check", file).CombinedOutput(); err != nil {
+		t.Errorf("invalid script: %v\n%s\n%s", err, out, script)
+	}
+}
+
+func TestEnvName(t *testing.T) {
+	for name, want := range map[string]string{
+		"baseUrl":       "BASE_URL",
+		"baseUrl2":      "BASE_URL_2",
+		"authorization": "AUTHORIZATION",
+		"xApiKey":       "X_API_KEY",
+	} {
+		if got := envName(name); got != want {
+			t.Errorf("envName(%q): expected %s, got %s", name, want, got)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package harexport
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/m-mizutani/harlog"
+)
+
+// InsomniaExport represents an Insomnia export (format version 4)
+type InsomniaExport struct {
+	Type         string             `json:"_type"`
+	ExportFormat int                `json:"__export_format"`
+	ExportSource string             `json:"__export_source"`
+	Resources    []InsomniaResource `json:"resources"`
+}
+
+// InsomniaResource represents a workspace, environment, request group or
+// request. Fields not used by the resource type are omitted.
+type InsomniaResource struct {
+	ID       string            `json:"_id"`
+	Type     string            `json:"_type"`
+	ParentID string            `json:"parentId"`
+	Name     string            `json:"name"`
+	Data     map[string]string `json:"data,omitempty"`
+	Method   string            `json:"method,omitempty"`
+	URL      string            `json:"url,omitempty"`
+	Headers  []InsomniaHeader  `json:"headers,omitempty"`
+	Body     *InsomniaBody     `json:"body,omitempty"`
+}
+
+// InsomniaHeader represents a request header
+type InsomniaHeader struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// InsomniaBody represents a request body
+type InsomniaBody struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+// Insomnia resource types
+const (
+	InsomniaWorkspace    = "workspace"
+	InsomniaEnvironment  = "environment"
+	InsomniaRequestGroup = "request_group"
+	InsomniaRequest      = "request"
+)
+
+// Insomnia converts the entries of har into an Insomnia export with a
+// workspace, a base environment holding the variables, request groups for
+// folders and requests
+func Insomnia(har *harlog.HAR, opts ...Option) *InsomniaExport {
+	c := newCollection(har, opts)
+
+	export := &InsomniaExport{
+		Type:         "export",
+		ExportFormat: 4,
+		ExportSource: "harlog",
+	}
+	workspaceID := "wrk_harlog"
+	export.Resources = append(export.Resources,
+		InsomniaResource{ID: workspaceID, Type: InsomniaWorkspace, Name: c.name},
+	)
+
+	env := InsomniaResource{
+		ID:       "env_harlog",
+		Type:     InsomniaEnvironment,
+		ParentID: workspaceID,
+		Name:     "Base Environment",
+		Data:     map[string]string{},
+	}
+	for _, v := range c.variables {
+		env.Data[v.name] = v.value
+	}
+	export.Resources = append(export.Resources, env)
+
+	var groups, requests int
+	var walk func(f *folder, parentID string)
+	walk = func(f *folder, parentID string) {
+		for _, sub := range f.folders {
+			groups++
+			id := fmt.Sprintf("fld_%d", groups)
+			export.Resources = append(export.Resources,
+				InsomniaResource{ID: id, Type: InsomniaRequestGroup, ParentID: parentID, Name: sub.name},
+			)
+			walk(sub, id)
+		}
+		for _, req := range f.requests {
+			requests++
+			export.Resources = append(export.Resources,
+				insomniaRequest(req, fmt.Sprintf("req_%d", requests), parentID),
+			)
+		}
+	}
+	walk(c.root, workspaceID)
+
+	return export
+}
+
+// WriteInsomnia writes the entries of har as Insomnia export JSON
+func WriteInsomnia(w io.Writer, har *harlog.HAR, opts ...Option) error {
+	return writeJSON(w, Insomnia(har, opts...))
+}
+
+func insomniaRef(name string) string {
+	return "{{ _." + name + " }}"
+}
+
+func insomniaRequest(req *request, id, parentID string) InsomniaResource {
+	r := InsomniaResource{
+		ID:       id,
+		Type:     InsomniaRequest,
+		ParentID: parentID,
+		Name:     req.name,
+		Method:   req.method,
+		URL:      req.urlWithRef(insomniaRef),
+	}
+	for _, h := range req.headers {
+		value := h.value
+		if h.variable != "" {
+			value = insomniaRef(h.variable)
+		}
+		r.Headers = append(r.Headers, InsomniaHeader{Name: h.name, Value: value})
+	}
+	if req.body != nil {
+		r.Body = &InsomniaBody{MimeType: req.body.MimeType, Text: req.body.Text}
+	}
+	return r
+}
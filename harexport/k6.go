@@ -0,0 +1,122 @@
+package harexport
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+
+	"github.com/m-mizutani/harlog"
+)
+
+// WriteK6 writes the entries of har as a k6 script. Requests are sent in
+// order by a single virtual user and checked for the recorded status code;
+// folders become k6 groups. Variables are read from environment variables,
+// e.g. BASE_URL, falling back to the recorded values.
+func WriteK6(w io.Writer, har *harlog.HAR, opts ...Option) error {
+	c := newCollection(har, opts)
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintf(bw, "// %s: generated by harlog\n", c.name)
+	fmt.Fprintln(bw, "import http from 'k6/http';")
+	fmt.Fprintln(bw, "import { check, group } from 'k6';")
+	fmt.Fprintln(bw)
+
+	if len(c.variables) > 0 {
+		for _, v := range c.variables {
+			fmt.Fprintf(bw, "const %s = __ENV.%s || %s;\n", envName(v.name), envName(v.name), jsString(v.value))
+		}
+		fmt.Fprintln(bw)
+	}
+
+	fmt.Fprintln(bw, "export const options = {")
+	fmt.Fprintln(bw, "  vus: 1,")
+	fmt.Fprintln(bw, "  iterations: 1,")
+	fmt.Fprintln(bw, "};")
+	fmt.Fprintln(bw)
+	fmt.Fprintln(bw, "export default function () {")
+	fmt.Fprintln(bw, "  let res;")
+	fmt.Fprintln(bw)
+	writeK6Folder(bw, c.root, "  ")
+	fmt.Fprintln(bw, "}")
+
+	return bw.Flush()
+}
+
+func writeK6Folder(w io.Writer, f *folder, indent string) {
+	for i, sub := range f.folders {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "%sgroup(%s, function () {\n", indent, jsString(sub.name))
+		writeK6Folder(w, sub, indent+"  ")
+		fmt.Fprintf(w, "%s});\n", indent)
+	}
+	for i, req := range f.requests {
+		if i > 0 || len(f.folders) > 0 {
+			fmt.Fprintln(w)
+		}
+		writeK6Request(w, req, indent)
+	}
+}
+
+func writeK6Request(w io.Writer, req *request, indent string) {
+	rawURL := jsString(req.url.String())
+	if req.baseVar != "" {
+		// The URL without scheme and host is appended to the variable
+		rest := req.urlWithRef(func(string) string { return "" })
+		rawURL = envName(req.baseVar) + " + " + jsString(rest)
+	}
+
+	body := "null"
+	if req.body != nil {
+		body = jsString(req.body.Text)
+	}
+
+	fmt.Fprintf(w, "%s// %s\n", indent, req.name)
+	fmt.Fprintf(w, "%sres = http.request(%s, %s, %s, {\n", indent, jsString(req.method), rawURL, body)
+	fmt.Fprintf(w, "%s  headers: {\n", indent)
+	for _, h := range req.headers {
+		value := jsString(h.value)
+		if h.variable != "" {
+			value = envName(h.variable)
+		}
+		fmt.Fprintf(w, "%s    %s: %s,\n", indent, jsString(h.name), value)
+	}
+	fmt.Fprintf(w, "%s  },\n", indent)
+	fmt.Fprintf(w, "%s});\n", indent)
+
+	if status := req.entry.Response.Status; status > 0 {
+		fmt.Fprintf(w, "%scheck(res, { %s: (r) => r.status === %d });\n",
+			indent, jsString(fmt.Sprintf("status is %d", status)), status)
+	}
+}
+
+// envName converts a lower camel case variable name to an environment
+// variable name, e.g. "baseUrl2" to "BASE_URL_2"
+func envName(name string) string {
+	var b strings.Builder
+	var prev rune
+	for i, r := range name {
+		if i > 0 && (unicode.IsUpper(r) || unicode.IsDigit(r) && !unicode.IsDigit(prev)) {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToUpper(r))
+		prev = r
+	}
+	return b.String()
+}
+
+// jsString returns s as a JavaScript string literal
+func jsString(s string) string {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(s); err != nil {
+		return `""`
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
@@ -0,0 +1,216 @@
+package harexport
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/m-mizutani/harlog"
+)
+
+// PostmanSchema is the schema URL of Postman Collection v2.1
+const PostmanSchema = "https://schema.getpostman.com/json/collection/v2.1.0/collection.json"
+
+// PostmanCollection represents a Postman Collection v2.1
+type PostmanCollection struct {
+	Info     PostmanInfo       `json:"info"`
+	Item     []PostmanItem     `json:"item"`
+	Variable []PostmanVariable `json:"variable,omitempty"`
+}
+
+// PostmanInfo represents the info object of a collection
+type PostmanInfo struct {
+	Name   string `json:"name"`
+	Schema string `json:"schema"`
+}
+
+// PostmanItem represents a request or, if Item is set, a folder
+type PostmanItem struct {
+	Name     string            `json:"name"`
+	Item     []PostmanItem     `json:"item,omitempty"`
+	Request  *PostmanRequest   `json:"request,omitempty"`
+	Response []PostmanResponse `json:"response,omitempty"`
+}
+
+// PostmanRequest represents a request of an item
+type PostmanRequest struct {
+	Method string          `json:"method"`
+	Header []PostmanHeader `json:"header"`
+	URL    PostmanURL      `json:"url"`
+	Body   *PostmanBody    `json:"body,omitempty"`
+}
+
+// PostmanHeader represents a header or query parameter
+type PostmanHeader struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// PostmanURL represents a request URL split into its parts
+type PostmanURL struct {
+	Raw      string          `json:"raw"`
+	Protocol string          `json:"protocol,omitempty"`
+	Host     []string        `json:"host,omitempty"`
+	Port     string          `json:"port,omitempty"`
+	Path     []string        `json:"path,omitempty"`
+	Query    []PostmanHeader `json:"query,omitempty"`
+}
+
+// PostmanBody represents a raw request body
+type PostmanBody struct {
+	Mode    string              `json:"mode"`
+	Raw     string              `json:"raw"`
+	Options *PostmanBodyOptions `json:"options,omitempty"`
+}
+
+// PostmanBodyOptions holds the language of a raw body for syntax highlighting
+type PostmanBodyOptions struct {
+	Raw struct {
+		Language string `json:"language"`
+	} `json:"raw"`
+}
+
+// PostmanResponse represents a saved example response
+type PostmanResponse struct {
+	Name            string          `json:"name"`
+	OriginalRequest *PostmanRequest `json:"originalRequest,omitempty"`
+	Status          string          `json:"status,omitempty"`
+	Code            int             `json:"code"`
+	Header          []PostmanHeader `json:"header"`
+	Body            string          `json:"body"`
+}
+
+// PostmanVariable represents a collection variable
+type PostmanVariable struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// Postman converts the entries of har into a Postman Collection v2.1
+func Postman(har *harlog.HAR, opts ...Option) *PostmanCollection {
+	c := newCollection(har, opts)
+	pc := &PostmanCollection{
+		Info: PostmanInfo{Name: c.name, Schema: PostmanSchema},
+		Item: postmanItems(c.root, c.examples),
+	}
+	for _, v := range c.variables {
+		pc.Variable = append(pc.Variable, PostmanVariable{Key: v.name, Value: v.value})
+	}
+	return pc
+}
+
+// WritePostman writes the entries of har as Postman Collection v2.1 JSON
+func WritePostman(w io.Writer, har *harlog.HAR, opts ...Option) error {
+	return writeJSON(w, Postman(har, opts...))
+}
+
+func postmanItems(f *folder, examples bool) []PostmanItem {
+	items := []PostmanItem{}
+	for _, sub := range f.folders {
+		items = append(items, PostmanItem{Name: sub.name, Item: postmanItems(sub, examples)})
+	}
+	for _, req := range f.requests {
+		item := PostmanItem{Name: req.name, Request: postmanRequest(req)}
+		if examples && req.entry.Response.Status > 0 {
+			item.Response = []PostmanResponse{postmanResponse(req)}
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+func postmanRef(name string) string {
+	return "{{" + name + "}}"
+}
+
+func postmanRequest(req *request) *PostmanRequest {
+	pr := &PostmanRequest{
+		Method: req.method,
+		Header: []PostmanHeader{},
+		URL:    postmanURL(req),
+	}
+	for _, h := range req.headers {
+		value := h.value
+		if h.variable != "" {
+			value = postmanRef(h.variable)
+		}
+		pr.Header = append(pr.Header, PostmanHeader{Key: h.name, Value: value})
+	}
+
+	if req.body != nil {
+		pr.Body = &PostmanBody{Mode: "raw", Raw: req.body.Text}
+		if lang := bodyLanguage(req.body.MimeType); lang != "" {
+			pr.Body.Options = &PostmanBodyOptions{}
+			pr.Body.Options.Raw.Language = lang
+		}
+	}
+	return pr
+}
+
+func postmanURL(req *request) PostmanURL {
+	u := req.url
+	pu := PostmanURL{Raw: req.urlWithRef(postmanRef)}
+
+	if req.baseVar != "" {
+		pu.Host = []string{postmanRef(req.baseVar)}
+	} else if u.Host != "" {
+		pu.Protocol = u.Scheme
+		pu.Host = strings.Split(u.Hostname(), ".")
+		pu.Port = u.Port()
+	}
+	if p := strings.TrimPrefix(u.Path, "/"); p != "" {
+		pu.Path = strings.Split(p, "/")
+	}
+
+	for _, pair := range strings.Split(u.RawQuery, "&") {
+		if pair == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(pair, "=")
+		if k, err := url.QueryUnescape(key); err == nil {
+			key = k
+		}
+		if v, err := url.QueryUnescape(value); err == nil {
+			value = v
+		}
+		pu.Query = append(pu.Query, PostmanHeader{Key: key, Value: value})
+	}
+	return pu
+}
+
+func postmanResponse(req *request) PostmanResponse {
+	resp := &req.entry.Response
+	pr := PostmanResponse{
+		Name:            fmt.Sprintf("%d %s", resp.Status, req.name),
+		OriginalRequest: postmanRequest(req),
+		Status:          resp.StatusText,
+		Code:            resp.Status,
+		Header:          []PostmanHeader{},
+		Body:            resp.Content.Text,
+	}
+	if pr.Status == "" {
+		pr.Status = http.StatusText(resp.Status)
+	}
+	for _, h := range resp.Headers {
+		pr.Header = append(pr.Header, PostmanHeader{Key: h.Name, Value: h.Value})
+	}
+	return pr
+}
+
+// bodyLanguage returns the Postman language of a body with the MIME type
+func bodyLanguage(mimeType string) string {
+	switch {
+	case strings.Contains(mimeType, "json"):
+		return "json"
+	case strings.Contains(mimeType, "xml"):
+		return "xml"
+	case strings.HasPrefix(mimeType, "text/html"):
+		return "html"
+	case strings.HasPrefix(mimeType, "text/"):
+		return "text"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,18 @@
+module github.com/m-mizutani/harlog/hargrpc
+
+go 1.22.0
+
+require (
+	github.com/m-mizutani/harlog v0.1.0
+	google.golang.org/grpc v1.71.1
+	google.golang.org/protobuf v1.36.4
+)
+
+require (
+	github.com/google/uuid v1.6.0 // indirect
+	github.com/klauspost/compress v1.18.0 // indirect
+	golang.org/x/net v0.34.0 // indirect
+	golang.org/x/sys v0.29.0 // indirect
+	golang.org/x/text v0.21.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20250115164207-1a7da9e5054f // indirect
+)
@@ -0,0 +1,563 @@
+// Package hargrpc decodes the protobuf bodies of gRPC, gRPC-Web and Connect
+// calls recorded by harlog into JSON, and records gRPC calls made or served
+// with grpc-go through interceptors.
+//
+// Bodies are decoded with the descriptors of the called method, looked up by
+// the path of the request in protoregistry.GlobalFiles or in a descriptor set
+// loaded by LoadDescriptorSet. Messages of unknown methods are decoded
+// without a schema into objects keyed by field number. Framed bodies are
+// written as JSON arrays of messages, and the outcome of the call, read from
+// trailers, is stored in the custom "_grpc" field of the entry.
+package hargrpc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/m-mizutani/harlog"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// Protocols of recorded calls
+const (
+	ProtocolGRPC    = "grpc"
+	ProtocolGRPCWeb = "grpc-web"
+	ProtocolConnect = "connect"
+)
+
+// Option represents a configuration option for Decoder
+type Option func(*Decoder)
+
+// WithFiles sets the descriptors used to decode messages (default:
+// protoregistry.GlobalFiles)
+func WithFiles(files *protoregistry.Files) Option {
+	return func(d *Decoder) {
+		d.files = files
+	}
+}
+
+// LoadDescriptorSet reads a FileDescriptorSet, as written by
+// protoc --descriptor_set_out --include_imports or buf build, from path
+func LoadDescriptorSet(path string) (*protoregistry.Files, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read descriptor set: %w", err)
+	}
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("failed to parse descriptor set: %w", err)
+	}
+	files, err := protodesc.NewFiles(&set)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build descriptors: %w", err)
+	}
+	return files, nil
+}
+
+// Decoder decodes the bodies of gRPC, gRPC-Web and Connect entries
+type Decoder struct {
+	files *protoregistry.Files
+	types *dynamicpb.Types
+}
+
+// New creates a Decoder configured by opts
+func New(opts ...Option) *Decoder {
+	d := &Decoder{files: protoregistry.GlobalFiles}
+	for _, opt := range opts {
+		opt(d)
+	}
+	d.types = dynamicpb.NewTypes(d.files)
+	return d
+}
+
+// WithDecoding returns a harlog option decoding the entries recorded by the
+// Logger with a Decoder configured by opts
+func WithDecoding(opts ...Option) harlog.Option {
+	return harlog.WithProcessor(New(opts...).Process)
+}
+
+// Process implements harlog.Processor
+func (d *Decoder) Process(_ *http.Request, entry *harlog.HAREntry) {
+	d.DecodeEntry(entry)
+}
+
+// DecodeEntry replaces the bodies of entry, if it is a gRPC, gRPC-Web or
+// Connect call, with JSON and sets its GRPC field. The bodies must be the
+// raw bytes received, so entries are decoded as they are recorded rather
+// than after they have been written to a HAR file. Entries whose GRPC field
+// is already set are left as is.
+func (d *Decoder) DecodeEntry(entry *harlog.HAREntry) {
+	if entry.GRPC != nil {
+		return
+	}
+	f, ok := requestFormat(entry.Request.Headers)
+	if !ok {
+		return
+	}
+	service, method := splitMethod(entry.Request.URL)
+	info := &harlog.HARGRPC{
+		Service:  service,
+		Method:   method,
+		Protocol: f.protocol,
+	}
+	var input, output protoreflect.MessageDescriptor
+	if md := d.findMethod(service, method); md != nil {
+		input, output = md.Input(), md.Output()
+	}
+
+	if pd := entry.Request.PostData; pd != nil && pd.Text != "" {
+		if text, _, ok := d.decodeBody(f, []byte(pd.Text), contentEncoding(f, entry.Request.Headers), input); ok {
+			pd.Text = text
+		}
+	}
+
+	resp := &entry.Response
+	var ends []frame
+	if rf, ok := parseFormat(resp.Content.MimeType); ok && resp.Content.Text != "" {
+		text, frames, ok := d.decodeBody(rf, []byte(resp.Content.Text), contentEncoding(rf, resp.Headers), output)
+		if ok {
+			resp.Content.Text = text
+			ends = frames
+		}
+	}
+
+	switch f.protocol {
+	case ProtocolGRPC, ProtocolGRPCWeb:
+		// gRPC-Web sends the trailers in the last frame of the body
+		for _, end := range ends {
+			resp.Trailers = append(resp.Trailers, parseTrailerFrame(end.data)...)
+		}
+		// Trailers-only responses carry the status in the headers
+		if !setStatus(info, resp.Trailers) {
+			setStatus(info, resp.Headers)
+		}
+	case ProtocolConnect:
+		if f.framed {
+			for _, end := range ends {
+				setEndStream(info, resp, end.data)
+			}
+		} else {
+			setUnaryStatus(info, resp)
+		}
+	}
+	entry.GRPC = info
+}
+
+// format describes how messages are encoded in a body
+type format struct {
+	protocol string
+	// framed is set for bodies made of length-prefixed messages
+	framed bool
+	// json is set for messages encoded in JSON rather than protobuf
+	json bool
+	// base64 is set for bodies encoded in base64, as with grpc-web-text
+	base64 bool
+}
+
+// parseFormat returns the format of bodies of contentType
+func parseFormat(contentType string) (format, bool) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return format{}, false
+	}
+	base, codec, _ := strings.Cut(mediaType, "+")
+
+	f := format{framed: true}
+	switch base {
+	case "application/grpc":
+		f.protocol = ProtocolGRPC
+	case "application/grpc-web":
+		f.protocol = ProtocolGRPCWeb
+	case "application/grpc-web-text":
+		f.protocol, f.base64 = ProtocolGRPCWeb, true
+	case "application/connect":
+		f.protocol = ProtocolConnect
+	case "application/proto":
+		// Unary Connect calls send a single message
+		f.protocol, f.framed = ProtocolConnect, false
+	default:
+		return format{}, false
+	}
+
+	switch codec {
+	case "", "proto":
+	case "json":
+		f.json = true
+	default:
+		return format{}, false
+	}
+	if !f.framed && codec != "" {
+		return format{}, false
+	}
+	return f, true
+}
+
+// requestFormat returns the format of a request with headers. Unary Connect
+// calls in JSON are recognized by their Connect-Protocol-Version header.
+func requestFormat(headers []harlog.HARHeader) (format, bool) {
+	contentType := header(headers, "Content-Type")
+	if f, ok := parseFormat(contentType); ok {
+		return f, true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err == nil && mediaType == "application/json" && header(headers, "Connect-Protocol-Version") != "" {
+		return format{protocol: ProtocolConnect, json: true}, true
+	}
+	return format{}, false
+}
+
+// contentEncoding returns the compression of the frames of a body with
+// headers
+func contentEncoding(f format, headers []harlog.HARHeader) string {
+	switch {
+	case !f.framed:
+		return ""
+	case f.protocol == ProtocolConnect:
+		return header(headers, "Connect-Content-Encoding")
+	default:
+		return header(headers, "Grpc-Encoding")
+	}
+}
+
+// header returns the first value of the header name
+func header(headers []harlog.HARHeader, name string) string {
+	for _, h := range headers {
+		if strings.EqualFold(h.Name, name) {
+			return h.Value
+		}
+	}
+	return ""
+}
+
+// splitMethod returns the service and method named by the last two segments
+// of the path of rawURL, e.g. /pkg.Service/Method
+func splitMethod(rawURL string) (service, method string) {
+	path := rawURL
+	if u, err := url.Parse(rawURL); err == nil {
+		path = u.Path
+	}
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	if len(segments) < 2 {
+		return "", strings.Join(segments, "")
+	}
+	return segments[len(segments)-2], segments[len(segments)-1]
+}
+
+// findMethod returns the descriptor of the method, or nil if it is unknown
+func (d *Decoder) findMethod(service, method string) protoreflect.MethodDescriptor {
+	name := protoreflect.FullName(service)
+	if !name.IsValid() {
+		return nil
+	}
+	desc, err := d.files.FindDescriptorByName(name)
+	if err != nil {
+		return nil
+	}
+	sd, ok := desc.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil
+	}
+	return sd.Methods().ByName(protoreflect.Name(method))
+}
+
+// Flags of frames
+const (
+	flagCompressed = 0x01
+	// flagEndStream marks the last frame of a Connect stream, which holds
+	// the status and trailers in JSON
+	flagEndStream = 0x02
+	// flagTrailer marks the gRPC-Web frame holding the trailers
+	flagTrailer = 0x80
+)
+
+// frame represents a length-prefixed message
+type frame struct {
+	flags byte
+	data  []byte
+}
+
+var errTruncated = errors.New("truncated frame")
+
+// splitFrames splits body into frames made of a flags byte, a 4-byte big
+// endian length and the message
+func splitFrames(body []byte) ([]frame, error) {
+	var frames []frame
+	for len(body) > 0 {
+		if len(body) < 5 {
+			return nil, errTruncated
+		}
+		size := binary.BigEndian.Uint32(body[1:5])
+		if uint64(len(body)-5) < uint64(size) {
+			return nil, errTruncated
+		}
+		frames = append(frames, frame{flags: body[0], data: body[5 : 5+size]})
+		body = body[5+size:]
+	}
+	return frames, nil
+}
+
+// decodeBody converts body to JSON text: an array of the messages for
+// framed bodies and the message otherwise. The trailer or end-stream frames
+// of framed bodies are returned apart. It reports false if the body is
+// malformed.
+func (d *Decoder) decodeBody(f format, body []byte, encoding string, desc protoreflect.MessageDescriptor) (string, []frame, bool) {
+	if f.base64 {
+		decoded, err := decodeBase64Chunks(body)
+		if err != nil {
+			return "", nil, false
+		}
+		body = decoded
+	}
+	if !f.framed {
+		if f.json {
+			return "", nil, false
+		}
+		return string(d.decodeMessage(f, desc, body)), nil, true
+	}
+
+	frames, err := splitFrames(body)
+	if err != nil {
+		return "", nil, false
+	}
+	messages := make([]json.RawMessage, 0, len(frames))
+	var ends []frame
+	for _, fr := range frames {
+		data, decompressed := fr.data, true
+		if fr.flags&flagCompressed != 0 {
+			if data, err = decompress(encoding, data); err != nil {
+				decompressed = false
+			}
+		}
+		isEnd := (f.protocol == ProtocolGRPCWeb && fr.flags&flagTrailer != 0) ||
+			(f.protocol == ProtocolConnect && fr.flags&flagEndStream != 0)
+		switch {
+		case isEnd:
+			if decompressed {
+				ends = append(ends, frame{flags: fr.flags, data: data})
+			}
+		case !decompressed:
+			messages = append(messages, base64JSON(fr.data))
+		default:
+			messages = append(messages, d.decodeMessage(f, desc, data))
+		}
+	}
+	out, err := json.Marshal(messages)
+	if err != nil {
+		return "", nil, false
+	}
+	return string(out), ends, true
+}
+
+// decodeMessage converts a message to JSON with desc if possible, without a
+// schema otherwise, and to a base64 string as a last resort
+func (d *Decoder) decodeMessage(f format, desc protoreflect.MessageDescriptor, data []byte) json.RawMessage {
+	if f.json {
+		if json.Valid(data) {
+			return compact(data)
+		}
+		out, _ := json.Marshal(string(data))
+		return out
+	}
+	if desc != nil {
+		msg := dynamicpb.NewMessage(desc)
+		if err := (proto.UnmarshalOptions{Resolver: d.types}).Unmarshal(data, msg); err == nil {
+			if out, err := (protojson.MarshalOptions{Resolver: d.types}).Marshal(msg); err == nil {
+				return compact(out)
+			}
+		}
+	}
+	if fields, ok := decodeWire(data, 0); ok {
+		if out, err := json.Marshal(fields); err == nil {
+			return out
+		}
+	}
+	return base64JSON(data)
+}
+
+// compact removes insignificant spaces, which protojson adds at random, from
+// valid JSON
+func compact(data []byte) json.RawMessage {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, data); err != nil {
+		return data
+	}
+	return buf.Bytes()
+}
+
+func base64JSON(data []byte) json.RawMessage {
+	out, _ := json.Marshal(base64.StdEncoding.EncodeToString(data))
+	return out
+}
+
+// decodeBase64Chunks decodes a grpc-web-text body, which may be made of
+// several padded base64 chunks
+func decodeBase64Chunks(body []byte) ([]byte, error) {
+	body = bytes.Join(bytes.Fields(body), nil)
+	var out []byte
+	for len(body) > 0 {
+		n := len(body)
+		if i := bytes.IndexByte(body, '='); i >= 0 {
+			for n = i; n < len(body) && body[n] == '='; n++ {
+			}
+		}
+		chunk, err := base64.StdEncoding.DecodeString(string(body[:n]))
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, chunk...)
+		body = body[n:]
+	}
+	return out, nil
+}
+
+// decompress decompresses a frame compressed with encoding
+func decompress(encoding string, data []byte) ([]byte, error) {
+	if encoding != "gzip" {
+		return nil, fmt.Errorf("unsupported encoding %q", encoding)
+	}
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// parseTrailerFrame parses the trailers of a gRPC-Web trailer frame, which
+// are formatted as HTTP/1 headers
+func parseTrailerFrame(data []byte) []harlog.HARHeader {
+	var trailers []harlog.HARHeader
+	for _, line := range strings.Split(string(data), "\n") {
+		name, value, ok := strings.Cut(strings.TrimRight(line, "\r"), ":")
+		if !ok {
+			continue
+		}
+		trailers = append(trailers, harlog.HARHeader{
+			Name:  strings.ToLower(strings.TrimSpace(name)),
+			Value: strings.TrimSpace(value),
+		})
+	}
+	return trailers
+}
+
+// setStatus sets the status of info from the grpc-status and grpc-message
+// fields of headers and reports whether they hold one
+func setStatus(info *harlog.HARGRPC, headers []harlog.HARHeader) bool {
+	code, err := strconv.Atoi(header(headers, "Grpc-Status"))
+	if err != nil {
+		return false
+	}
+	// The message is percent-encoded
+	message := header(headers, "Grpc-Message")
+	if unescaped, err := url.PathUnescape(message); err == nil {
+		message = unescaped
+	}
+	info.Code, info.Status, info.Message = code, codeName(code), message
+	return true
+}
+
+// connectError represents the error of a Connect call
+type connectError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// setEndStream sets the status of info from a Connect end-stream frame and
+// adds its metadata to the trailers of resp
+func setEndStream(info *harlog.HARGRPC, resp *harlog.HARResponse, data []byte) {
+	var end struct {
+		Error    *connectError       `json:"error"`
+		Metadata map[string][]string `json:"metadata"`
+	}
+	if err := json.Unmarshal(data, &end); err != nil {
+		return
+	}
+	for _, name := range sortedKeys(end.Metadata) {
+		for _, value := range end.Metadata[name] {
+			resp.Trailers = append(resp.Trailers, harlog.HARHeader{Name: name, Value: value})
+		}
+	}
+	if end.Error == nil {
+		info.Code, info.Status = 0, codeName(0)
+		return
+	}
+	setConnectError(info, end.Error)
+}
+
+// setUnaryStatus sets the status of info from the response of a unary
+// Connect call, whose errors are sent as JSON
+func setUnaryStatus(info *harlog.HARGRPC, resp *harlog.HARResponse) {
+	if resp.Status == http.StatusOK {
+		info.Code, info.Status = 0, codeName(0)
+		return
+	}
+	var e connectError
+	if err := json.Unmarshal([]byte(resp.Content.Text), &e); err == nil && e.Code != "" {
+		setConnectError(info, &e)
+	}
+}
+
+func setConnectError(info *harlog.HARGRPC, e *connectError) {
+	code := 2 // Unknown
+	for i := 1; i < len(codeNames); i++ {
+		if connectCodeName(i) == e.Code {
+			code = i
+		}
+	}
+	info.Code, info.Status, info.Message = code, codeName(code), e.Message
+}
+
+// codeNames are the names of gRPC status codes
+var codeNames = []string{
+	"OK",
+	"CANCELLED",
+	"UNKNOWN",
+	"INVALID_ARGUMENT",
+	"DEADLINE_EXCEEDED",
+	"NOT_FOUND",
+	"ALREADY_EXISTS",
+	"PERMISSION_DENIED",
+	"RESOURCE_EXHAUSTED",
+	"FAILED_PRECONDITION",
+	"ABORTED",
+	"OUT_OF_RANGE",
+	"UNIMPLEMENTED",
+	"INTERNAL",
+	"UNAVAILABLE",
+	"DATA_LOSS",
+	"UNAUTHENTICATED",
+}
+
+// codeName returns the name of a gRPC status code
+func codeName(code int) string {
+	if code < 0 || code >= len(codeNames) {
+		return "CODE_" + strconv.Itoa(code)
+	}
+	return codeNames[code]
+}
+
+// connectCodeName returns the name of a status code in the Connect protocol
+func connectCodeName(code int) string {
+	if code == 1 {
+		return "canceled"
+	}
+	return strings.ToLower(codeName(code))
+}
@@ -0,0 +1,272 @@
+package hargrpc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/m-mizutani/harlog"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// encodeFrame returns a length-prefixed frame of data
+func encodeFrame(flags byte, data []byte) []byte {
+	b := make([]byte, 5, 5+len(data))
+	b[0] = flags
+	binary.BigEndian.PutUint32(b[1:], uint32(len(data)))
+	return append(b, data...)
+}
+
+func mustMarshal(t *testing.T, m proto.Message) []byte {
+	t.Helper()
+	b, err := proto.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// grpcEntry returns an entry of a call to path with the bodies and headers
+func grpcEntry(path, contentType string, request, response []byte, respHeaders, trailers []harlog.HARHeader) *harlog.HAREntry {
+	return &harlog.HAREntry{
+		Request: harlog.HARRequest{
+			Method:  "POST",
+			URL:     "https://example.com" + path,
+			Headers: []harlog.HARHeader{{Name: "Content-Type", Value: contentType}},
+			PostData: &harlog.HARPostData{
+				MimeType: contentType,
+				Text:     string(request),
+			},
+		},
+		Response: harlog.HARResponse{
+			Status:  200,
+			Headers: append([]harlog.HARHeader{{Name: "Content-Type", Value: contentType}}, respHeaders...),
+			Content: harlog.HARContent{
+				MimeType: contentType,
+				Text:     string(response),
+			},
+			Trailers: trailers,
+		},
+	}
+}
+
+func checkStatus(t *testing.T, entry *harlog.HAREntry, want harlog.HARGRPC) {
+	t.Helper()
+	if entry.GRPC == nil {
+		t.Fatal("expected gRPC details")
+	}
+	if *entry.GRPC != want {
+		t.Errorf("expected %+v, got %+v", want, *entry.GRPC)
+	}
+}
+
+func TestDecodeEntry_GRPC(t *testing.T) {
+	req := mustMarshal(t, &grpc_health_v1.HealthCheckRequest{Service: "harlog"})
+	resp := mustMarshal(t, &grpc_health_v1.HealthCheckResponse{Status: grpc_health_v1.HealthCheckResponse_SERVING})
+	entry := grpcEntry("/grpc.health.v1.Health/Check", "application/grpc",
+		encodeFrame(0, req), encodeFrame(0, resp), nil,
+		[]harlog.HARHeader{{Name: "Grpc-Status", Value: "0"}})
+
+	New().DecodeEntry(entry)
+
+	if got := entry.Request.PostData.Text; got != `[{"service":"harlog"}]` {
+		t.Errorf("expected decoded request, got %s", got)
+	}
+	if got := entry.Response.Content.Text; got != `[{"status":"SERVING"}]` {
+		t.Errorf("expected decoded response, got %s", got)
+	}
+	checkStatus(t, entry, harlog.HARGRPC{
+		Service: "grpc.health.v1.Health", Method: "Check", Protocol: ProtocolGRPC, Code: 0, Status: "OK",
+	})
+
+	// Decoding twice leaves the entry as is
+	New().DecodeEntry(entry)
+	if got := entry.Request.PostData.Text; got != `[{"service":"harlog"}]` {
+		t.Errorf("expected entry not to be decoded again, got %s", got)
+	}
+}
+
+func TestDecodeEntry_TrailersOnly(t *testing.T) {
+	req := mustMarshal(t, &grpc_health_v1.HealthCheckRequest{Service: "missing"})
+	entry := grpcEntry("/grpc.health.v1.Health/Check", "application/grpc+proto",
+		encodeFrame(0, req), nil,
+		[]harlog.HARHeader{
+			{Name: "Grpc-Status", Value: "5"},
+			{Name: "Grpc-Message", Value: "unknown%20service"},
+		}, nil)
+
+	New().DecodeEntry(entry)
+
+	checkStatus(t, entry, harlog.HARGRPC{
+		Service: "grpc.health.v1.Health", Method: "Check", Protocol: ProtocolGRPC,
+		Code: 5, Status: "NOT_FOUND", Message: "unknown service",
+	})
+}
+
+func TestDecodeEntry_Compressed(t *testing.T) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, _ = zw.Write(mustMarshal(t, &grpc_health_v1.HealthCheckRequest{Service: "harlog"}))
+	_ = zw.Close()
+
+	entry := grpcEntry("/grpc.health.v1.Health/Check", "application/grpc",
+		encodeFrame(flagCompressed, buf.Bytes()), nil, nil, nil)
+	entry.Request.Headers = append(entry.Request.Headers, harlog.HARHeader{Name: "grpc-encoding", Value: "gzip"})
+
+	New().DecodeEntry(entry)
+
+	if got := entry.Request.PostData.Text; got != `[{"service":"harlog"}]` {
+		t.Errorf("expected decompressed request, got %s", got)
+	}
+}
+
+func TestDecodeEntry_GRPCWebText(t *testing.T) {
+	resp := mustMarshal(t, &grpc_health_v1.HealthCheckResponse{Status: grpc_health_v1.HealthCheckResponse_NOT_SERVING})
+	// Each frame is encoded separately, as streamed by servers
+	body := base64.StdEncoding.EncodeToString(encodeFrame(0, resp)) +
+		base64.StdEncoding.EncodeToString(encodeFrame(flagTrailer, []byte("grpc-status: 14\r\ngrpc-message: down\r\n")))
+	entry := grpcEntry("/grpc.health.v1.Health/Check", "application/grpc-web-text", nil, []byte(body), nil, nil)
+
+	New().DecodeEntry(entry)
+
+	if got := entry.Response.Content.Text; got != `[{"status":"NOT_SERVING"}]` {
+		t.Errorf("expected decoded response without trailer frame, got %s", got)
+	}
+	if len(entry.Response.Trailers) != 2 || entry.Response.Trailers[0].Name != "grpc-status" {
+		t.Errorf("expected trailers of the trailer frame, got %+v", entry.Response.Trailers)
+	}
+	checkStatus(t, entry, harlog.HARGRPC{
+		Service: "grpc.health.v1.Health", Method: "Check", Protocol: ProtocolGRPCWeb,
+		Code: 14, Status: "UNAVAILABLE", Message: "down",
+	})
+}
+
+func TestDecodeEntry_Connect(t *testing.T) {
+	req := mustMarshal(t, &grpc_health_v1.HealthCheckRequest{Service: "harlog"})
+
+	t.Run("stream", func(t *testing.T) {
+		end := `{"error":{"code":"permission_denied","message":"no"},"metadata":{"x-id":["1"]}}`
+		entry := grpcEntry("/grpc.health.v1.Health/Watch", "application/connect+proto",
+			encodeFrame(0, req), encodeFrame(flagEndStream, []byte(end)), nil, nil)
+
+		New().DecodeEntry(entry)
+
+		if got := entry.Response.Content.Text; got != `[]` {
+			t.Errorf("expected no messages, got %s", got)
+		}
+		if len(entry.Response.Trailers) != 1 || entry.Response.Trailers[0].Value != "1" {
+			t.Errorf("expected metadata as trailers, got %+v", entry.Response.Trailers)
+		}
+		checkStatus(t, entry, harlog.HARGRPC{
+			Service: "grpc.health.v1.Health", Method: "Watch", Protocol: ProtocolConnect,
+			Code: 7, Status: "PERMISSION_DENIED", Message: "no",
+		})
+	})
+
+	t.Run("unary", func(t *testing.T) {
+		resp := mustMarshal(t, &grpc_health_v1.HealthCheckResponse{Status: grpc_health_v1.HealthCheckResponse_SERVING})
+		entry := grpcEntry("/grpc.health.v1.Health/Check", "application/proto", req, resp, nil, nil)
+
+		New().DecodeEntry(entry)
+
+		if got := entry.Request.PostData.Text; got != `{"service":"harlog"}` {
+			t.Errorf("expected decoded request, got %s", got)
+		}
+		if got := entry.Response.Content.Text; got != `{"status":"SERVING"}` {
+			t.Errorf("expected decoded response, got %s", got)
+		}
+		checkStatus(t, entry, harlog.HARGRPC{
+			Service: "grpc.health.v1.Health", Method: "Check", Protocol: ProtocolConnect, Status: "OK",
+		})
+	})
+
+	t.Run("unary error", func(t *testing.T) {
+		entry := grpcEntry("/grpc.health.v1.Health/Check", "application/json", []byte(`{"service":"x"}`),
+			[]byte(`{"code":"canceled","message":"bye"}`), nil, nil)
+		entry.Request.Headers = append(entry.Request.Headers, harlog.HARHeader{Name: "Connect-Protocol-Version", Value: "1"})
+		entry.Response.Status = 408
+
+		New().DecodeEntry(entry)
+
+		if got := entry.Request.PostData.Text; got != `{"service":"x"}` {
+			t.Errorf("expected JSON request as is, got %s", got)
+		}
+		checkStatus(t, entry, harlog.HARGRPC{
+			Service: "grpc.health.v1.Health", Method: "Check", Protocol: ProtocolConnect,
+			Code: 1, Status: "CANCELLED", Message: "bye",
+		})
+	})
+}
+
+func TestDecodeEntry_Unknown(t *testing.T) {
+	nested := mustMarshal(t, &grpc_health_v1.HealthCheckRequest{Service: "inner"})
+	msg := append([]byte{0x08, 0x96, 0x01, 0x08, 0x02}, protoBytes(2, nested)...)
+	msg = append(msg, protoBytes(3, []byte{0xff, 0x00})...)
+
+	entry := grpcEntry("/example.Unknown/Call", "application/grpc", encodeFrame(0, msg), []byte("\x00\x00"), nil, nil)
+	New().DecodeEntry(entry)
+
+	if got, want := entry.Request.PostData.Text, `[{"1":[150,2],"2":{"1":"inner"},"3":"/wA="}]`; got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+	// Truncated bodies are left as is
+	if got := entry.Response.Content.Text; got != "\x00\x00" {
+		t.Errorf("expected truncated response as is, got %q", got)
+	}
+	checkStatus(t, entry, harlog.HARGRPC{Service: "example.Unknown", Method: "Call", Protocol: ProtocolGRPC})
+}
+
+// protoBytes returns a length-delimited field
+func protoBytes(num byte, data []byte) []byte {
+	return append([]byte{num<<3 | 2, byte(len(data))}, data...)
+}
+
+func TestDecodeEntry_NotGRPC(t *testing.T) {
+	entry := grpcEntry("/api/users", "application/json", []byte(`{}`), []byte(`{}`), nil, nil)
+	New().DecodeEntry(entry)
+	if entry.GRPC != nil {
+		t.Errorf("expected no gRPC details, got %+v", entry.GRPC)
+	}
+}
+
+func TestLoadDescriptorSet(t *testing.T) {
+	set := &descriptorpb.FileDescriptorSet{
+		File: []*descriptorpb.FileDescriptorProto{
+			protodesc.ToFileDescriptorProto(grpc_health_v1.File_grpc_health_v1_health_proto),
+		},
+	}
+	path := filepath.Join(t.TempDir(), "health.binpb")
+	if err := os.WriteFile(path, mustMarshal(t, set), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := LoadDescriptorSet(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := mustMarshal(t, &grpc_health_v1.HealthCheckRequest{Service: "harlog"})
+	entry := grpcEntry("/grpc.health.v1.Health/Check", "application/grpc", encodeFrame(0, req), nil, nil, nil)
+	New(WithFiles(files)).DecodeEntry(entry)
+	if got := entry.Request.PostData.Text; got != `[{"service":"harlog"}]` {
+		t.Errorf("expected request decoded with the descriptor set, got %s", got)
+	}
+
+	// Without the descriptors, the message is decoded without a schema
+	entry = grpcEntry("/grpc.health.v1.Health/Check", "application/grpc", encodeFrame(0, req), nil, nil, nil)
+	New(WithFiles(&protoregistry.Files{})).DecodeEntry(entry)
+	if got := entry.Request.PostData.Text; got != `[{"1":"harlog"}]` {
+		t.Errorf("expected request decoded without a schema, got %s", got)
+	}
+
+	if _, err := LoadDescriptorSet(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
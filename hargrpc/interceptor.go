@@ -0,0 +1,387 @@
+package hargrpc
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/m-mizutani/harlog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// UnaryClientInterceptor returns an interceptor recording the unary calls of
+// a client with logger. Messages are written as JSON, resolving Any fields
+// with the descriptors configured by opts.
+func UnaryClientInterceptor(logger *harlog.Logger, opts ...Option) grpc.UnaryClientInterceptor {
+	d := New(opts...)
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, callOpts ...grpc.CallOption) error {
+		md, _ := metadata.FromOutgoingContext(ctx)
+		c := d.newCall(ctx, authority(cc.Target()), method, md)
+		c.request(req)
+
+		var header, trailer metadata.MD
+		callOpts = append(callOpts, grpc.Header(&header), grpc.Trailer(&trailer))
+		err := invoker(ctx, method, req, reply, cc, callOpts...)
+		if err == nil {
+			c.response(reply)
+		}
+		c.finish(logger, header, trailer, err)
+		return err
+	}
+}
+
+// StreamClientInterceptor returns an interceptor recording the streaming
+// calls of a client with logger. A call is saved once RecvMsg reports the
+// end of the stream.
+func StreamClientInterceptor(logger *harlog.Logger, opts ...Option) grpc.StreamClientInterceptor {
+	d := New(opts...)
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, callOpts ...grpc.CallOption) (grpc.ClientStream, error) {
+		md, _ := metadata.FromOutgoingContext(ctx)
+		c := d.newCall(ctx, authority(cc.Target()), method, md)
+		stream, err := streamer(ctx, desc, cc, method, callOpts...)
+		if err != nil {
+			c.finish(logger, nil, nil, err)
+			return nil, err
+		}
+		return &clientStream{ClientStream: stream, desc: desc, call: c, logger: logger}, nil
+	}
+}
+
+// clientStream records the messages of a client stream
+type clientStream struct {
+	grpc.ClientStream
+	desc   *grpc.StreamDesc
+	call   *call
+	logger *harlog.Logger
+}
+
+func (s *clientStream) SendMsg(m any) error {
+	err := s.ClientStream.SendMsg(m)
+	if err == nil {
+		s.call.request(m)
+	}
+	return err
+}
+
+func (s *clientStream) RecvMsg(m any) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err == nil {
+		s.call.response(m)
+		// Streams with a single response end with it
+		if s.desc.ServerStreams {
+			return nil
+		}
+	}
+	end := err
+	if errors.Is(end, io.EOF) {
+		end = nil
+	}
+	header, _ := s.ClientStream.Header()
+	s.call.finish(s.logger, header, s.ClientStream.Trailer(), end)
+	return err
+}
+
+// UnaryServerInterceptor returns an interceptor recording the unary calls
+// served by a server with logger
+func UnaryServerInterceptor(logger *harlog.Logger, opts ...Option) grpc.UnaryServerInterceptor {
+	d := New(opts...)
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		c := d.newCall(ctx, serverAuthority(md), info.FullMethod, md)
+		c.request(req)
+
+		// Record the metadata sent by the handler
+		ts := &transportStream{ServerTransportStream: grpc.ServerTransportStreamFromContext(ctx)}
+		if ts.ServerTransportStream != nil {
+			ctx = grpc.NewContextWithServerTransportStream(ctx, ts)
+		}
+		resp, err := handler(ctx, req)
+		if err == nil {
+			c.response(resp)
+		}
+		header, trailer := ts.metadata()
+		c.finish(logger, header, trailer, err)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor returns an interceptor recording the streaming
+// calls served by a server with logger
+func StreamServerInterceptor(logger *harlog.Logger, opts ...Option) grpc.StreamServerInterceptor {
+	d := New(opts...)
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx := ss.Context()
+		md, _ := metadata.FromIncomingContext(ctx)
+		s := &serverStream{ServerStream: ss, call: d.newCall(ctx, serverAuthority(md), info.FullMethod, md)}
+		err := handler(srv, s)
+		header, trailer := s.metadata()
+		s.call.finish(logger, header, trailer, err)
+		return err
+	}
+}
+
+// serverStream records the messages and metadata of a server stream
+type serverStream struct {
+	grpc.ServerStream
+	call *call
+	metadataRecorder
+}
+
+func (s *serverStream) SendMsg(m any) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		s.call.response(m)
+	}
+	return err
+}
+
+func (s *serverStream) RecvMsg(m any) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.call.request(m)
+	}
+	return err
+}
+
+func (s *serverStream) SetHeader(md metadata.MD) error {
+	s.setHeader(md)
+	return s.ServerStream.SetHeader(md)
+}
+
+func (s *serverStream) SendHeader(md metadata.MD) error {
+	s.setHeader(md)
+	return s.ServerStream.SendHeader(md)
+}
+
+func (s *serverStream) SetTrailer(md metadata.MD) {
+	s.setTrailer(md)
+	s.ServerStream.SetTrailer(md)
+}
+
+// transportStream records the metadata set by a unary handler
+type transportStream struct {
+	grpc.ServerTransportStream
+	metadataRecorder
+}
+
+func (s *transportStream) SetHeader(md metadata.MD) error {
+	s.setHeader(md)
+	return s.ServerTransportStream.SetHeader(md)
+}
+
+func (s *transportStream) SendHeader(md metadata.MD) error {
+	s.setHeader(md)
+	return s.ServerTransportStream.SendHeader(md)
+}
+
+func (s *transportStream) SetTrailer(md metadata.MD) error {
+	s.setTrailer(md)
+	return s.ServerTransportStream.SetTrailer(md)
+}
+
+// metadataRecorder collects the header and trailer metadata of a call
+type metadataRecorder struct {
+	mu      sync.Mutex
+	header  metadata.MD
+	trailer metadata.MD
+}
+
+func (r *metadataRecorder) setHeader(md metadata.MD) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.header = metadata.Join(r.header, md)
+}
+
+func (r *metadataRecorder) setTrailer(md metadata.MD) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.trailer = metadata.Join(r.trailer, md)
+}
+
+func (r *metadataRecorder) metadata() (header, trailer metadata.MD) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.header, r.trailer
+}
+
+// call collects the messages of a gRPC call for its entry
+type call struct {
+	d         *Decoder
+	ctx       context.Context
+	start     time.Time
+	authority string
+	method    string
+	md        metadata.MD
+
+	mu        sync.Mutex
+	requests  []json.RawMessage
+	responses []json.RawMessage
+	once      sync.Once
+}
+
+func (d *Decoder) newCall(ctx context.Context, authority, method string, md metadata.MD) *call {
+	return &call{
+		d:         d,
+		ctx:       ctx,
+		start:     time.Now(),
+		authority: authority,
+		method:    method,
+		md:        md,
+	}
+}
+
+func (c *call) request(m any) {
+	msg := c.d.marshal(m)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.requests = append(c.requests, msg)
+}
+
+func (c *call) response(m any) {
+	msg := c.d.marshal(m)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.responses = append(c.responses, msg)
+}
+
+// finish saves the entry of the call, which ended with err, once
+func (c *call) finish(logger *harlog.Logger, header, trailer metadata.MD, err error) {
+	c.once.Do(func() {
+		req, reqErr := http.NewRequestWithContext(c.ctx, http.MethodPost, "grpc://"+c.authority+c.method, nil)
+		if reqErr != nil {
+			return
+		}
+		_ = logger.WriteEntry(req, c.entry(req, header, trailer, err))
+	})
+}
+
+// entry builds the entry of the call as if it had been recorded from HTTP/2
+// traffic, with the messages in JSON
+func (c *call) entry(req *http.Request, header, trailer metadata.MD, err error) *harlog.HAREntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	st := status.Convert(err)
+	code := int(st.Code())
+	trailers := []harlog.HARHeader{{Name: "grpc-status", Value: strconv.Itoa(code)}}
+	if st.Message() != "" {
+		trailers = append(trailers, harlog.HARHeader{Name: "grpc-message", Value: st.Message()})
+	}
+	service, method := splitMethod(c.method)
+	requests, _ := json.Marshal(append([]json.RawMessage{}, c.requests...))
+	responses, _ := json.Marshal(append([]json.RawMessage{}, c.responses...))
+
+	return &harlog.HAREntry{
+		StartedDateTime: c.start.Format(time.RFC3339),
+		Time:            float64(time.Since(c.start).Milliseconds()),
+		Request: harlog.HARRequest{
+			Method:      http.MethodPost,
+			URL:         req.URL.String(),
+			HTTPVersion: "HTTP/2.0",
+			Headers:     metadataHeaders(c.md),
+			QueryString: []harlog.HARQuery{},
+			PostData: &harlog.HARPostData{
+				MimeType: contentType,
+				Text:     string(requests),
+			},
+			HeadersSize: -1, // Not implemented
+			BodySize:    -1, // Not implemented
+		},
+		Response: harlog.HARResponse{
+			Status:      http.StatusOK,
+			StatusText:  http.StatusText(http.StatusOK),
+			HTTPVersion: "HTTP/2.0",
+			Headers:     metadataHeaders(header),
+			Content: harlog.HARContent{
+				Size:     -1,
+				MimeType: contentType,
+				Text:     string(responses),
+			},
+			HeadersSize: -1, // Not implemented
+			BodySize:    -1, // Not implemented
+			Trailers:    append(trailers, metadataFields(trailer)...),
+		},
+		GRPC: &harlog.HARGRPC{
+			Service:  service,
+			Method:   method,
+			Protocol: ProtocolGRPC,
+			Code:     code,
+			Status:   codeName(code),
+			Message:  st.Message(),
+		},
+	}
+}
+
+// contentType is the content type of entries recorded by interceptors
+const contentType = "application/grpc"
+
+// metadataHeaders converts md to headers following a content-type header
+func metadataHeaders(md metadata.MD) []harlog.HARHeader {
+	return append([]harlog.HARHeader{{Name: "content-type", Value: contentType}}, metadataFields(md)...)
+}
+
+// metadataFields converts md to header fields. Values of binary keys, ending
+// in -bin, are base64 encoded as on the wire.
+func metadataFields(md metadata.MD) []harlog.HARHeader {
+	var headers []harlog.HARHeader
+	for _, key := range sortedKeys(md) {
+		if strings.HasPrefix(key, ":") || key == "content-type" {
+			continue
+		}
+		for _, value := range md[key] {
+			if strings.HasSuffix(key, "-bin") {
+				value = base64.RawStdEncoding.EncodeToString([]byte(value))
+			}
+			headers = append(headers, harlog.HARHeader{Name: key, Value: value})
+		}
+	}
+	return headers
+}
+
+// marshal converts a message to JSON
+func (d *Decoder) marshal(m any) json.RawMessage {
+	if msg, ok := m.(proto.Message); ok {
+		if out, err := (protojson.MarshalOptions{Resolver: d.types}).Marshal(msg); err == nil {
+			return compact(out)
+		}
+	}
+	if out, err := json.Marshal(m); err == nil {
+		return out
+	}
+	out, _ := json.Marshal(fmt.Sprint(m))
+	return out
+}
+
+// authority returns the host a client with target connects to, e.g.
+// localhost:50051 for dns:///localhost:50051
+func authority(target string) string {
+	_, rest, ok := strings.Cut(target, "://")
+	if !ok {
+		return target
+	}
+	host, endpoint, ok := strings.Cut(rest, "/")
+	if ok && endpoint != "" {
+		return endpoint
+	}
+	return host
+}
+
+// serverAuthority returns the authority requested by the client
+func serverAuthority(md metadata.MD) string {
+	if values := md.Get(":authority"); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
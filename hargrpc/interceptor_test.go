@@ -0,0 +1,220 @@
+package hargrpc
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/m-mizutani/harlog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// newHealthServer returns a gRPC server reporting harlog as serving
+func newHealthServer(opts ...grpc.ServerOption) *grpc.Server {
+	server := grpc.NewServer(opts...)
+	hs := health.NewServer()
+	hs.SetServingStatus("harlog", grpc_health_v1.HealthCheckResponse_SERVING)
+	grpc_health_v1.RegisterHealthServer(server, hs)
+	return server
+}
+
+func waitCall(t *testing.T, rec *harlog.Recorder, method, status string) harlog.HAREntry {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	entry, err := rec.WaitFor(ctx, func(entry *harlog.HAREntry) bool {
+		return entry.GRPC != nil && entry.GRPC.Method == method && entry.GRPC.Status == status
+	})
+	if err != nil {
+		t.Fatalf("expected %s call with status %s, got %+v", method, status, rec.Entries())
+	}
+	return entry
+}
+
+func TestInterceptors(t *testing.T) {
+	t.Parallel()
+
+	serverRec, clientRec := harlog.NewRecorder(), harlog.NewRecorder()
+	serverLogger := harlog.New(harlog.WithFileOutput(false), harlog.WithSink(serverRec))
+	clientLogger := harlog.New(harlog.WithFileOutput(false), harlog.WithSink(clientRec))
+
+	server := newHealthServer(
+		grpc.UnaryInterceptor(UnaryServerInterceptor(serverLogger)),
+		grpc.StreamInterceptor(StreamServerInterceptor(serverLogger)),
+	)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = server.Serve(lis) }()
+	defer server.Stop()
+
+	conn, err := grpc.NewClient(lis.Addr().String(),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(UnaryClientInterceptor(clientLogger)),
+		grpc.WithStreamInterceptor(StreamClientInterceptor(clientLogger)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := grpc_health_v1.NewHealthClient(conn)
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-request-id", "42")
+	if _, err := client.Check(ctx, &grpc_health_v1.HealthCheckRequest{Service: "harlog"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Check(ctx, &grpc_health_v1.HealthCheckRequest{Service: "missing"}); status.Code(err) != codes.NotFound {
+		t.Fatalf("expected NotFound, got %v", err)
+	}
+	if _, err := client.Check(harlog.Skip(ctx), &grpc_health_v1.HealthCheckRequest{Service: "harlog"}); err != nil {
+		t.Fatal(err)
+	}
+
+	watchCtx, cancel := context.WithCancel(ctx)
+	stream, err := client.Watch(watchCtx, &grpc_health_v1.HealthCheckRequest{Service: "harlog"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if _, err := stream.Recv(); status.Code(err) != codes.Canceled {
+		t.Fatalf("expected Canceled, got %v", err)
+	}
+
+	for side, rec := range map[string]*harlog.Recorder{"client": clientRec, "server": serverRec} {
+		entry := waitCall(t, rec, "Check", "OK")
+		if got := entry.Request.PostData.Text; got != `[{"service":"harlog"}]` {
+			t.Errorf("%s: expected request messages, got %s", side, got)
+		}
+		if got := entry.Response.Content.Text; got != `[{"status":"SERVING"}]` {
+			t.Errorf("%s: expected response messages, got %s", side, got)
+		}
+		if !strings.HasPrefix(entry.Request.URL, "grpc://127.0.0.1:") || !strings.HasSuffix(entry.Request.URL, "/grpc.health.v1.Health/Check") {
+			t.Errorf("%s: unexpected URL %s", side, entry.Request.URL)
+		}
+		found := false
+		for _, h := range entry.Request.Headers {
+			found = found || (h.Name == "x-request-id" && h.Value == "42")
+		}
+		if !found {
+			t.Errorf("%s: expected metadata in headers, got %+v", side, entry.Request.Headers)
+		}
+
+		entry = waitCall(t, rec, "Check", "NOT_FOUND")
+		if entry.GRPC.Code != int(codes.NotFound) || entry.Response.Content.Text != "[]" {
+			t.Errorf("%s: unexpected failed call: %+v", side, entry)
+		}
+
+		entry = waitCall(t, rec, "Watch", "CANCELLED")
+		if !strings.HasPrefix(entry.Response.Content.Text, `[{"status":"SERVING"}`) {
+			t.Errorf("%s: expected streamed messages, got %s", side, entry.Response.Content.Text)
+		}
+	}
+	if got := len(clientRec.Find("POST", "/grpc.health.v1.Health/Check")); got != 2 {
+		t.Errorf("expected skipped call not to be recorded, got %d calls", got)
+	}
+}
+
+func TestWithDecoding_Middleware(t *testing.T) {
+	t.Parallel()
+
+	rec := harlog.NewRecorder()
+	logger := harlog.New(harlog.WithFileOutput(false), harlog.WithSink(rec), WithDecoding())
+
+	// grpc.Server serves HTTP/2 requests as an http.Handler
+	server := httptest.NewUnstartedServer(logger.Middleware(newHealthServer()))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+	conn, err := grpc.NewClient(strings.TrimPrefix(server.URL, "https://"),
+		grpc.WithTransportCredentials(credentials.NewClientTLSFromCert(pool, "")),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := grpc_health_v1.NewHealthClient(conn)
+
+	if _, err := client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{Service: "harlog"}); err != nil {
+		t.Fatal(err)
+	}
+	entry := waitCall(t, rec, "Check", "OK")
+	if got := entry.Request.PostData.Text; got != `[{"service":"harlog"}]` {
+		t.Errorf("expected decoded request, got %s", got)
+	}
+	if got := entry.Response.Content.Text; got != `[{"status":"SERVING"}]` {
+		t.Errorf("expected decoded response, got %s", got)
+	}
+	for _, h := range entry.Response.Headers {
+		if strings.EqualFold(h.Name, "Grpc-Status") {
+			t.Errorf("expected grpc-status in trailers only, got header %+v", h)
+		}
+	}
+
+	if _, err := client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{Service: "missing"}); status.Code(err) != codes.NotFound {
+		t.Fatalf("expected NotFound, got %v", err)
+	}
+	entry = waitCall(t, rec, "Check", "NOT_FOUND")
+	if entry.GRPC.Message != "unknown service" {
+		t.Errorf("expected status message, got %q", entry.GRPC.Message)
+	}
+}
+
+func TestWithDecoding_Transport(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewUnstartedServer(newHealthServer())
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	rec := harlog.NewRecorder()
+	client := &http.Client{Transport: harlog.New(
+		harlog.WithFileOutput(false),
+		harlog.WithSink(rec),
+		harlog.WithTransport(server.Client().Transport),
+		WithDecoding(),
+	)}
+
+	body := encodeFrame(0, mustMarshal(t, &grpc_health_v1.HealthCheckRequest{Service: "harlog"}))
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/grpc.health.v1.Health/Check", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("TE", "trailers")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	entry := waitCall(t, rec, "Check", "OK")
+	if got := entry.Response.Content.Text; got != `[{"status":"SERVING"}]` {
+		t.Errorf("expected decoded response, got %s", got)
+	}
+	if len(entry.Response.Trailers) == 0 {
+		t.Error("expected trailers")
+	}
+}
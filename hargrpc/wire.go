@@ -0,0 +1,119 @@
+package hargrpc
+
+import (
+	"encoding/base64"
+	"sort"
+	"strconv"
+	"unicode"
+	"unicode/utf8"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// maxWireDepth limits the nesting of messages decoded without a schema
+const maxWireDepth = 32
+
+// decodeWire decodes a protobuf message without a schema into a map from
+// field numbers to values, which are lists for repeated fields. Varints and
+// fixed-size numbers are decoded as unsigned integers. Length-delimited
+// fields are decoded as strings if they are printable text, as nested
+// messages if they parse as such, and as base64 strings otherwise. It
+// reports false if data is not a valid message.
+func decodeWire(data []byte, depth int) (map[string]any, bool) {
+	if depth > maxWireDepth {
+		return nil, false
+	}
+	fields := map[string]any{}
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return nil, false
+		}
+		data = data[n:]
+
+		var value any
+		switch typ {
+		case protowire.VarintType:
+			v, n := protowire.ConsumeVarint(data)
+			if n < 0 {
+				return nil, false
+			}
+			value, data = v, data[n:]
+		case protowire.Fixed32Type:
+			v, n := protowire.ConsumeFixed32(data)
+			if n < 0 {
+				return nil, false
+			}
+			value, data = v, data[n:]
+		case protowire.Fixed64Type:
+			v, n := protowire.ConsumeFixed64(data)
+			if n < 0 {
+				return nil, false
+			}
+			value, data = v, data[n:]
+		case protowire.BytesType:
+			v, n := protowire.ConsumeBytes(data)
+			if n < 0 {
+				return nil, false
+			}
+			value, data = decodeBytes(v, depth), data[n:]
+		case protowire.StartGroupType:
+			v, n := protowire.ConsumeGroup(num, data)
+			if n < 0 {
+				return nil, false
+			}
+			group, ok := decodeWire(v, depth+1)
+			if !ok {
+				return nil, false
+			}
+			value, data = group, data[n:]
+		default:
+			return nil, false
+		}
+
+		key := strconv.Itoa(int(num))
+		switch prev := fields[key].(type) {
+		case nil:
+			fields[key] = value
+		case []any:
+			fields[key] = append(prev, value)
+		default:
+			fields[key] = []any{prev, value}
+		}
+	}
+	return fields, true
+}
+
+// decodeBytes decodes the value of a length-delimited field
+func decodeBytes(data []byte, depth int) any {
+	if isText(data) {
+		return string(data)
+	}
+	if msg, ok := decodeWire(data, depth+1); ok {
+		return msg
+	}
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+// isText reports whether data is printable UTF-8 text
+func isText(data []byte) bool {
+	if !utf8.Valid(data) {
+		return false
+	}
+	for _, r := range string(data) {
+		if !unicode.IsPrint(r) && r != '\t' && r != '\n' && r != '\r' {
+			return false
+		}
+	}
+	return true
+}
+
+// sortedKeys returns the keys of m in order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,184 @@
+package harimport
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// traceEvent matches the event lines of curl --trace output, optionally
+// prefixed with the time written by --trace-time. Data written by curl to
+// the same stream may precede the event on the line.
+var traceEvent = regexp.MustCompile(`(\d{2}:\d{2}:\d{2}\.\d+ )?(== Info|=> Send header|=> Send data|<= Recv header|<= Recv data|=> Send SSL data|<= Recv SSL data)(.*)$`)
+
+// traceData matches the offset of a hex dump line
+var traceData = regexp.MustCompile(`^[0-9a-f]{4}: `)
+
+// traceExchange collects the bytes of one request and response
+type traceExchange struct {
+	scheme   string
+	request  bytes.Buffer
+	response bytes.Buffer
+	start    time.Time
+	end      time.Time
+}
+
+// CurlTrace imports the output of curl --trace (optionally with
+// --trace-time). Each request sent by curl, together with the response
+// received for it, becomes an entry. HTTP/2 exchanges are imported as
+// decoded by curl. The output of --trace-ascii lacks the exact bytes and is
+// reported as skipped.
+func CurlTrace(r io.Reader, opts ...Option) (*Result, error) {
+	cfg := newConfig(opts)
+	result := newResult()
+
+	var exchanges []*traceExchange
+	var current *traceExchange
+	var target *bytes.Buffer
+	tls := false
+	ascii := false
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if traceData.MatchString(line) {
+			if target == nil {
+				continue
+			}
+			data, ok := decodeTraceLine(line)
+			if !ok {
+				ascii = true
+				continue
+			}
+			target.Write(data)
+			continue
+		}
+
+		m := traceEvent.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		var at time.Time
+		if m[1] != "" {
+			at, _ = time.Parse("15:04:05.999999 ", m[1])
+		}
+
+		target = nil
+		switch m[2] {
+		case "== Info":
+			info := strings.TrimSpace(strings.TrimPrefix(m[3], ":"))
+			switch {
+			case strings.HasPrefix(info, "Connected to"):
+				tls = false
+			case strings.Contains(info, "SSL connection"), strings.Contains(info, "TLS"):
+				tls = true
+			}
+
+		case "=> Send SSL data", "<= Recv SSL data":
+			tls = true
+
+		case "=> Send header":
+			if current == nil || current.response.Len() > 0 {
+				current = &traceExchange{scheme: cfg.scheme, start: at}
+				if tls {
+					current.scheme = "https"
+				}
+				exchanges = append(exchanges, current)
+			}
+			target = &current.request
+
+		case "=> Send data":
+			if current != nil {
+				target = &current.request
+			}
+
+		case "<= Recv header", "<= Recv data":
+			if current != nil {
+				target = &current.response
+			}
+		}
+		if current != nil && !at.IsZero() {
+			current.end = at
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read curl trace: %w", err)
+	}
+
+	for i, ex := range exchanges {
+		item := fmt.Sprintf("exchange %d", i+1)
+		if ascii {
+			result.skip(item, "output of --trace-ascii is not supported, use --trace")
+			continue
+		}
+
+		entry, _, err := readDumpRequest(normalizeVersion(ex.request.Bytes()), &config{scheme: ex.scheme})
+		if err != nil {
+			result.skip(item, "invalid request: %v", err)
+			continue
+		}
+		if ex.response.Len() > 0 {
+			resp, _, err := readDumpResponse(normalizeVersion(ex.response.Bytes()), entry.Request.Method)
+			if err != nil {
+				result.skip(item, "invalid response: %v", err)
+				continue
+			}
+			entry.Response = resp
+		}
+		if !ex.start.IsZero() && ex.end.After(ex.start) {
+			entry.Time = float64(ex.end.Sub(ex.start).Microseconds()) / 1000
+		}
+		result.add(*entry)
+	}
+
+	return result, nil
+}
+
+// decodeTraceLine decodes the hex bytes of a --trace data line, which hold
+// up to 16 bytes in a fixed width column
+func decodeTraceLine(line string) ([]byte, bool) {
+	column := line[6:]
+	if len(column) > 48 {
+		column = column[:48]
+	}
+
+	var data []byte
+	for i := 0; i+2 <= len(column); i += 3 {
+		field := column[i : i+2]
+		if field == "  " {
+			break
+		}
+		b, err := hex.DecodeString(field)
+		if err != nil || i+2 < len(column) && column[i+2] != ' ' {
+			return nil, false
+		}
+		data = append(data, b...)
+	}
+	return data, true
+}
+
+// Versions without minor version such as "HTTP/2" are written by curl in
+// status and request lines
+var (
+	majorOnlyStatus  = regexp.MustCompile(`^HTTP/(\d) `)
+	majorOnlyRequest = regexp.MustCompile(` HTTP/(\d)$`)
+)
+
+// normalizeVersion rewrites a version without minor version in the first
+// line of data to the "HTTP/2.0" form which net/http can parse
+func normalizeVersion(data []byte) []byte {
+	line, rest, ok := bytes.Cut(data, []byte("\r\n"))
+	if !ok {
+		return data
+	}
+	line = majorOnlyStatus.ReplaceAll(line, []byte("HTTP/${1}.0 "))
+	line = majorOnlyRequest.ReplaceAll(line, []byte(" HTTP/${1}.0"))
+	return bytes.Join([][]byte{line, rest}, []byte("\r\n"))
+}
@@ -0,0 +1,213 @@
+package harimport
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/m-mizutani/harlog"
+)
+
+// Option represents a configuration option for the importers
+type Option func(*config)
+
+// WithScheme sets the URL scheme of requests whose request line holds only
+// a path, which is the case for raw dumps and curl traces (default: "http";
+// curl traces of TLS connections use "https")
+func WithScheme(scheme string) Option {
+	return func(c *config) {
+		c.scheme = scheme
+	}
+}
+
+type config struct {
+	scheme string
+}
+
+func newConfig(opts []Option) *config {
+	c := &config{}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+var (
+	requestLine = regexp.MustCompile(`^[A-Z]+ \S+ HTTP/\d(\.\d)?\r?$`)
+	statusLine  = regexp.MustCompile(`^HTTP/\d(\.\d)? \d{3}`)
+)
+
+// HTTPDump imports a sequence of raw HTTP/1.x messages, such as the output
+// of httputil.DumpRequest followed by that of httputil.DumpResponse for each
+// exchange. Each request is paired with the response following it; requests
+// without a response are imported with an empty response. Responses without
+// Content-Length and chunked encoding extend to the end of the input, as in
+// HTTP/1.x.
+func HTTPDump(r io.Reader, opts ...Option) (*Result, error) {
+	cfg := newConfig(opts)
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read HTTP dump: %w", err)
+	}
+
+	result := newResult()
+	var pending *harlog.HAREntry
+	flush := func() {
+		if pending != nil {
+			result.add(*pending)
+			pending = nil
+		}
+	}
+
+	for n, pos := 1, skipBlankLines(data, 0); pos < len(data); n, pos = n+1, skipBlankLines(data, pos) {
+		item := fmt.Sprintf("message %d", n)
+		line := firstLine(data[pos:])
+
+		switch {
+		case requestLine.MatchString(line):
+			entry, size, err := readDumpRequest(data[pos:], cfg)
+			if err != nil {
+				result.skip(item, "invalid request: %v", err)
+				pos = nextStartLine(data, pos)
+				continue
+			}
+			flush()
+			pending = entry
+			pos += size
+
+		case statusLine.MatchString(line):
+			var method string
+			if pending != nil {
+				method = pending.Request.Method
+			}
+			resp, size, err := readDumpResponse(data[pos:], method)
+			if err != nil {
+				result.skip(item, "invalid response: %v", err)
+				pos = nextStartLine(data, pos)
+				continue
+			}
+			pos += size
+			if pending == nil {
+				result.skip(item, "response without request: %s", strings.TrimSpace(line))
+				continue
+			}
+			pending.Response = resp
+			flush()
+
+		default:
+			result.skip(item, "not an HTTP message: %q", line)
+			pos = nextStartLine(data, pos)
+		}
+	}
+	flush()
+
+	return result, nil
+}
+
+// readDumpRequest parses the request at the start of data and returns the
+// entry and the number of bytes consumed
+func readDumpRequest(data []byte, cfg *config) (*harlog.HAREntry, int, error) {
+	rd := bytes.NewReader(data)
+	br := bufio.NewReader(rd)
+	req, err := http.ReadRequest(br)
+	if err != nil {
+		return nil, 0, err
+	}
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read body: %w", err)
+	}
+
+	u := *req.URL
+	if u.Host == "" {
+		u.Host = req.Host
+	}
+	if u.Scheme == "" && u.Host != "" {
+		u.Scheme = cfg.scheme
+		if u.Scheme == "" {
+			u.Scheme = "http"
+		}
+	}
+
+	headers := convertHeaders(req.Header)
+	if req.Host != "" {
+		headers = append([]harlog.HARHeader{{Name: "Host", Value: req.Host}}, headers...)
+	}
+
+	entry := &harlog.HAREntry{
+		Request:  newRequest(req.Method, u.String(), req.Proto, headers, body),
+		Response: newResponse(0, "", "", nil, nil),
+	}
+	return entry, consumed(rd, br), nil
+}
+
+// readDumpResponse parses the response at the start of data and returns it
+// and the number of bytes consumed. Interim 1xx responses preceding it are
+// skipped. method is the method of the request, if known, which tells
+// whether the response has a body.
+func readDumpResponse(data []byte, method string) (harlog.HARResponse, int, error) {
+	var req *http.Request
+	if method != "" {
+		req = &http.Request{Method: method}
+	}
+
+	rd := bytes.NewReader(data)
+	br := bufio.NewReader(rd)
+	resp, err := http.ReadResponse(br, req)
+	for err == nil && interim(resp.StatusCode) {
+		resp, err = http.ReadResponse(br, req)
+	}
+	if err != nil {
+		return harlog.HARResponse{}, 0, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return harlog.HARResponse{}, 0, fmt.Errorf("failed to read body: %w", err)
+	}
+
+	reason := strings.TrimSpace(strings.TrimPrefix(resp.Status, fmt.Sprint(resp.StatusCode)))
+	return newResponse(resp.StatusCode, reason, resp.Proto, convertHeaders(resp.Header), body), consumed(rd, br), nil
+}
+
+// consumed returns the number of bytes of data parsed through br
+func consumed(rd *bytes.Reader, br *bufio.Reader) int {
+	return int(rd.Size()) - rd.Len() - br.Buffered()
+}
+
+func firstLine(data []byte) string {
+	line, _, _ := bytes.Cut(data, []byte("\n"))
+	return strings.TrimSuffix(string(line), "\r")
+}
+
+func skipBlankLines(data []byte, pos int) int {
+	for pos < len(data) && (data[pos] == '\r' || data[pos] == '\n') {
+		pos++
+	}
+	return pos
+}
+
+// nextStartLine returns the position of the next line after pos that starts
+// an HTTP message, or len(data)
+func nextStartLine(data []byte, pos int) int {
+	for {
+		i := bytes.IndexByte(data[pos:], '\n')
+		if i < 0 {
+			return len(data)
+		}
+		pos += i + 1
+		line := firstLine(data[pos:])
+		if requestLine.MatchString(line) || statusLine.MatchString(line) {
+			return pos
+		}
+	}
+}
+
+// interim reports whether status is an informational status other than
+// 101 Switching Protocols, which is followed by the final response
+func interim(status int) bool {
+	return status >= 100 && status < 200 && status != 101
+}
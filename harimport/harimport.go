@@ -0,0 +1,148 @@
+// Package harimport converts traffic recorded in other formats into HAR logs,
+// so that it can be used with the rest of harlog: raw HTTP/1.x dumps as
+// written by httputil.DumpRequest and httputil.DumpResponse, Postman
+// collections, curl --trace output and mitmproxy flow files.
+//
+// Importers do not fail on items they cannot convert. Such items are
+// reported in Result.Skipped with the reason, and the remaining items are
+// imported.
+package harimport
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/m-mizutani/harlog"
+)
+
+// Result represents the outcome of an import
+type Result struct {
+	HAR     *harlog.HAR
+	Skipped []SkippedItem
+}
+
+// SkippedItem describes an item of the source that could not be converted
+type SkippedItem struct {
+	// Item identifies the item in the source, e.g. "message 3" or the path of
+	// a Postman request
+	Item   string `json:"item"`
+	Reason string `json:"reason"`
+}
+
+func (s SkippedItem) String() string {
+	return s.Item + ": " + s.Reason
+}
+
+func newResult() *Result {
+	return &Result{
+		HAR: &harlog.HAR{
+			Log: harlog.HARLog{
+				Version: "1.2",
+				Creator: harlog.HARCreator{Name: "harlog", Version: "1.0"},
+				Entries: []harlog.HAREntry{},
+			},
+		},
+	}
+}
+
+func (r *Result) add(entry harlog.HAREntry) {
+	r.HAR.Log.Entries = append(r.HAR.Log.Entries, entry)
+}
+
+func (r *Result) skip(item, format string, args ...any) {
+	r.Skipped = append(r.Skipped, SkippedItem{Item: item, Reason: fmt.Sprintf(format, args...)})
+}
+
+// newRequest builds a HAR request. The query string is taken from rawURL.
+func newRequest(method, rawURL, proto string, headers []harlog.HARHeader, body []byte) harlog.HARRequest {
+	req := harlog.HARRequest{
+		Method:      method,
+		URL:         rawURL,
+		HTTPVersion: proto,
+		Headers:     headers,
+		QueryString: []harlog.HARQuery{},
+		HeadersSize: -1,
+		BodySize:    len(body),
+	}
+	if req.Headers == nil {
+		req.Headers = []harlog.HARHeader{}
+	}
+
+	if u, err := url.Parse(rawURL); err == nil {
+		for _, pair := range strings.Split(u.RawQuery, "&") {
+			if pair == "" {
+				continue
+			}
+			name, value, _ := strings.Cut(pair, "=")
+			if n, err := url.QueryUnescape(name); err == nil {
+				name = n
+			}
+			if v, err := url.QueryUnescape(value); err == nil {
+				value = v
+			}
+			req.QueryString = append(req.QueryString, harlog.HARQuery{Name: name, Value: value})
+		}
+	}
+
+	if len(body) > 0 {
+		req.PostData = &harlog.HARPostData{
+			MimeType: headerValue(headers, "Content-Type"),
+			Text:     string(body),
+		}
+	}
+	return req
+}
+
+// newResponse builds a HAR response
+func newResponse(status int, statusText, proto string, headers []harlog.HARHeader, body []byte) harlog.HARResponse {
+	if statusText == "" {
+		statusText = http.StatusText(status)
+	}
+	if headers == nil {
+		headers = []harlog.HARHeader{}
+	}
+	return harlog.HARResponse{
+		Status:      status,
+		StatusText:  statusText,
+		HTTPVersion: proto,
+		Headers:     headers,
+		Content: harlog.HARContent{
+			Size:     len(body),
+			MimeType: headerValue(headers, "Content-Type"),
+			Text:     string(body),
+		},
+		RedirectURL: headerValue(headers, "Location"),
+		HeadersSize: -1,
+		BodySize:    len(body),
+	}
+}
+
+// convertHeaders converts http.Header to HAR headers sorted by name
+func convertHeaders(h http.Header) []harlog.HARHeader {
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	headers := make([]harlog.HARHeader, 0, len(h))
+	for _, name := range names {
+		for _, value := range h[name] {
+			headers = append(headers, harlog.HARHeader{Name: name, Value: value})
+		}
+	}
+	return headers
+}
+
+// headerValue returns the value of the first header with the name
+func headerValue(headers []harlog.HARHeader, name string) string {
+	for _, h := range headers {
+		if strings.EqualFold(h.Name, name) {
+			return h.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,404 @@
+package harimport
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"os/exec"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/m-mizutani/harlog"
+	"github.com/m-mizutani/harlog/harexport"
+)
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "text/plain")
+		if r.URL.Path == "/chunked" {
+			fmt.Fprint(w, "hello ")
+			w.(http.Flusher).Flush()
+			fmt.Fprint(w, "world")
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, "%s %s", r.Method, body)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestHTTPDump(t *testing.T) {
+	server := newTestServer(t)
+
+	var dump bytes.Buffer
+	for _, tc := range []struct{ method, path, body string }{
+		{"POST", "/users?name=a%20b", `{"name": "alice"}`},
+		{"GET", "/chunked", ""},
+	} {
+		req, err := http.NewRequest(tc.method, server.URL+tc.path, strings.NewReader(tc.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := httputil.DumpRequestOut(req, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dump.Write(data)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err = httputil.DumpResponse(resp, true)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		dump.WriteString("\n")
+		dump.Write(data)
+	}
+	dump.WriteString("\ngarbage\nHTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n")
+
+	result, err := HTTPDump(&dump)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries := result.HAR.Log.Entries
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d: %+v", len(entries), result.Skipped)
+	}
+
+	post := entries[0]
+	if post.Request.Method != "POST" || post.Request.URL != server.URL+"/users?name=a%20b" {
+		t.Errorf("unexpected request: %+v", post.Request)
+	}
+	if post.Request.PostData == nil || post.Request.PostData.Text != `{"name": "alice"}` {
+		t.Errorf("unexpected body: %+v", post.Request.PostData)
+	}
+	if len(post.Request.QueryString) != 1 || post.Request.QueryString[0].Value != "a b" {
+		t.Errorf("unexpected query: %+v", post.Request.QueryString)
+	}
+	if post.Response.Status != 201 || post.Response.StatusText != "Created" || post.Response.Content.Text != `POST {"name": "alice"}` {
+		t.Errorf("unexpected response: %+v", post.Response)
+	}
+
+	if text := entries[1].Response.Content.Text; text != "hello world" {
+		t.Errorf("chunked body was not decoded: %q", text)
+	}
+
+	if len(result.Skipped) != 2 ||
+		!strings.Contains(result.Skipped[0].Reason, "not an HTTP message") ||
+		!strings.Contains(result.Skipped[1].Reason, "response without request") {
+		t.Errorf("unexpected skipped items: %+v", result.Skipped)
+	}
+}
+
+func TestPostman_RoundTrip(t *testing.T) {
+	har := &harlog.HAR{
+		Log: harlog.HARLog{
+			Entries: []harlog.HAREntry{{
+				Request: harlog.HARRequest{
+					Method: "POST",
+					URL:    "https://api.example.com/v1/users?dry_run=true",
+					Headers: []harlog.HARHeader{
+						{Name: "Authorization", Value: "Bearer secret"},
+						{Name: "Content-Type", Value: "application/json"},
+					},
+					PostData: &harlog.HARPostData{MimeType: "application/json", Text: `{"name": "alice"}`},
+				},
+				Response: harlog.HARResponse{
+					Status:     201,
+					StatusText: "Created",
+					Headers:    []harlog.HARHeader{{Name: "Content-Type", Value: "application/json"}},
+					Content:    harlog.HARContent{Text: `{"id": 1}`},
+				},
+			}},
+		},
+	}
+
+	var buf bytes.Buffer
+	err := harexport.WritePostman(&buf, har,
+		harexport.WithGroupBy(harexport.GroupByHost()),
+		harexport.WithBaseURLVariables(),
+		harexport.WithAuthVariables(),
+		harexport.WithExamples(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := Postman(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Skipped) != 0 || len(result.HAR.Log.Entries) != 1 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+
+	entry := result.HAR.Log.Entries[0]
+	want := har.Log.Entries[0]
+	if entry.Request.Method != want.Request.Method || entry.Request.URL != want.Request.URL {
+		t.Errorf("unexpected request: %+v", entry.Request)
+	}
+	if headerValue(entry.Request.Headers, "Authorization") != "Bearer secret" {
+		t.Errorf("variable was not substituted: %+v", entry.Request.Headers)
+	}
+	if entry.Request.PostData == nil || entry.Request.PostData.Text != want.Request.PostData.Text {
+		t.Errorf("unexpected body: %+v", entry.Request.PostData)
+	}
+	if entry.Response.Status != 201 || entry.Response.Content.Text != `{"id": 1}` || entry.Response.Content.MimeType != "application/json" {
+		t.Errorf("unexpected response: %+v", entry.Response)
+	}
+	if entry.Comment != "api.example.com/POST /v1/users/201 POST /v1/users" {
+		t.Errorf("unexpected comment: %s", entry.Comment)
+	}
+}
+
+func TestPostman(t *testing.T) {
+	collection := `{
+		"info": {"name": "test", "schema": "https://schema.getpostman.com/json/collection/v2.0.0/collection.json"},
+		"variable": [{"key": "host", "value": "example.com"}, {"key": "port", "value": 8080}],
+		"item": [
+			{
+				"name": "admin",
+				"auth": {"type": "basic", "basic": [{"key": "username", "value": "root"}, {"key": "password", "value": "pw"}]},
+				"item": [
+					{"name": "login", "request": {
+						"method": "post",
+						"url": "http://{{host}}:{{port}}/login",
+						"body": {"mode": "urlencoded", "urlencoded": [{"key": "user", "value": "a b"}, {"key": "debug", "value": "1", "disabled": true}]}
+					}},
+					{"name": "upload", "request": {"method": "POST", "url": "http://{{host}}/upload", "body": {"mode": "formdata", "formdata": []}}}
+				]
+			},
+			{"name": "plain", "request": "{{host}}/plain"},
+			{"name": "unresolved", "request": {"method": "GET", "url": {"raw": "{{baseUrl}}/users"}}}
+		]
+	}`
+
+	result, err := Postman(strings.NewReader(collection))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries := result.HAR.Log.Entries
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %+v", entries)
+	}
+
+	login := entries[0].Request
+	if login.Method != "POST" || login.URL != "http://example.com:8080/login" {
+		t.Errorf("unexpected request: %+v", login)
+	}
+	if headerValue(login.Headers, "Authorization") != "Basic cm9vdDpwdw==" {
+		t.Errorf("folder auth was not applied: %+v", login.Headers)
+	}
+	if login.PostData == nil || login.PostData.Text != "user=a+b" || login.PostData.MimeType != "application/x-www-form-urlencoded" {
+		t.Errorf("unexpected body: %+v", login.PostData)
+	}
+	if entries[0].Response.Status != 0 {
+		t.Errorf("expected empty response: %+v", entries[0].Response)
+	}
+
+	if plain := entries[1].Request; plain.Method != "GET" || plain.URL != "http://example.com/plain" {
+		t.Errorf("unexpected request: %+v", plain)
+	}
+
+	sort.Slice(result.Skipped, func(i, j int) bool { return result.Skipped[i].Item < result.Skipped[j].Item })
+	if len(result.Skipped) != 2 ||
+		result.Skipped[0].String() != "admin/upload: unsupported body mode: formdata" ||
+		result.Skipped[1].String() != `unresolved: URL "{{baseUrl}}/users" cannot be resolved` {
+		t.Errorf("unexpected skipped items: %+v", result.Skipped)
+	}
+}
+
+func TestCurlTrace(t *testing.T) {
+	if _, err := exec.LookPath("curl"); err != nil {
+		t.Skip("curl is not installed")
+	}
+	server := newTestServer(t)
+
+	out, err := exec.Command("curl", "--silent", "--trace-time", "--trace", "-",
+		"-o", "/dev/null", "-o", "/dev/null", "-d", "a=1",
+		server.URL+"/form?x=1", server.URL+"/chunked",
+	).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := CurlTrace(bytes.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := result.HAR.Log.Entries
+	if len(result.Skipped) != 0 || len(entries) != 2 {
+		t.Fatalf("unexpected result: %+v\n%s", result, out)
+	}
+
+	form := entries[0]
+	if form.Request.Method != "POST" || form.Request.URL != server.URL+"/form?x=1" {
+		t.Errorf("unexpected request: %+v", form.Request)
+	}
+	if form.Request.PostData == nil || form.Request.PostData.Text != "a=1" {
+		t.Errorf("unexpected body: %+v", form.Request.PostData)
+	}
+	if form.Response.Status != 201 || form.Response.Content.Text != "POST a=1" {
+		t.Errorf("unexpected response: %+v", form.Response)
+	}
+	if form.Time <= 0 {
+		t.Errorf("expected time from --trace-time: %v", form.Time)
+	}
+
+	if text := entries[1].Response.Content.Text; text != "hello world" {
+		t.Errorf("chunked body was not decoded: %q", text)
+	}
+}
+
+func TestCurlTrace_ASCII(t *testing.T) {
+	trace := "=> Send header, 16 bytes (0x10)\n0000: GET / HTTP/1.1\n0010: \n"
+
+	result, err := CurlTrace(strings.NewReader(trace))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.HAR.Log.Entries) != 0 || len(result.Skipped) != 1 || !strings.Contains(result.Skipped[0].Reason, "--trace-ascii") {
+		t.Errorf("unexpected result: %+v", result)
+	}
+}
+
+func TestNormalizeVersion(t *testing.T) {
+	for in, want := range map[string]string{
+		"HTTP/2 200\r\nA: b\r\n":       "HTTP/2.0 200\r\nA: b\r\n",
+		"GET / HTTP/2\r\nA: b\r\n":     "GET / HTTP/2.0\r\nA: b\r\n",
+		"HTTP/1.1 200\r\nA: b\r\n":     "HTTP/1.1 200\r\nA: b\r\n",
+		"GET /HTTP/2 HTTP/1.1\r\n\r\n": "GET /HTTP/2 HTTP/1.1\r\n\r\n",
+	} {
+		if got := string(normalizeVersion([]byte(in))); got != want {
+			t.Errorf("normalizeVersion(%q): expected %q, got %q", in, want, got)
+		}
+	}
+}
+
+// tnet encodes v as a tnetstring
+func tnet(v any) string {
+	var payload, typ string
+	switch v := v.(type) {
+	case nil:
+		typ = "~"
+	case []byte:
+		payload, typ = string(v), ","
+	case string:
+		payload, typ = v, ";"
+	case int:
+		payload, typ = fmt.Sprint(v), "#"
+	case float64:
+		payload, typ = fmt.Sprint(v), "^"
+	case []any:
+		for _, item := range v {
+			payload += tnet(item)
+		}
+		typ = "]"
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			payload += tnet(key) + tnet(v[key])
+		}
+		typ = "}"
+	}
+	return fmt.Sprintf("%d:%s%s", len(payload), payload, typ)
+}
+
+func TestMitmproxy(t *testing.T) {
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	_, _ = zw.Write([]byte(`{"id": 1}`))
+	_ = zw.Close()
+
+	header := func(name, value string) any { return []any{[]byte(name), []byte(value)} }
+	flows := tnet(map[string]any{
+		"type":    "http",
+		"version": 18,
+		"request": map[string]any{
+			"method":          []byte("POST"),
+			"scheme":          []byte("https"),
+			"host":            "api.example.com",
+			"port":            443,
+			"authority":       []byte(""),
+			"path":            []byte("/users?x=1"),
+			"http_version":    []byte("HTTP/1.1"),
+			"headers":         []any{header("Content-Type", "application/json")},
+			"content":         []byte(`{"name": "alice"}`),
+			"timestamp_start": 1741520954.25,
+		},
+		"response": map[string]any{
+			"http_version":  []byte("HTTP/1.1"),
+			"status_code":   201,
+			"reason":        []byte("Created"),
+			"headers":       []any{header("Content-Encoding", "gzip"), header("Content-Type", "application/json")},
+			"content":       gz.Bytes(),
+			"timestamp_end": 1741520954.5,
+		},
+	}) + tnet(map[string]any{
+		"type":     "http",
+		"request":  map[string]any{"method": []byte("GET"), "scheme": []byte("http"), "host": "localhost", "port": 8080, "path": []byte("/")},
+		"response": nil,
+		"error":    map[string]any{"msg": "connection refused"},
+	}) + tnet(map[string]any{"type": "tcp"})
+
+	result, err := Mitmproxy(strings.NewReader(flows))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries := result.HAR.Log.Entries
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 entry, got %+v", result)
+	}
+	entry := entries[0]
+	if entry.Request.URL != "https://api.example.com/users?x=1" || entry.Request.PostData.Text != `{"name": "alice"}` {
+		t.Errorf("unexpected request: %+v", entry.Request)
+	}
+	if entry.Response.Status != 201 || entry.Response.Content.Text != `{"id": 1}` {
+		t.Errorf("unexpected response: %+v", entry.Response)
+	}
+	if entry.StartedDateTime != "2025-03-09T11:49:14.25Z" || entry.Time != 250 {
+		t.Errorf("unexpected timing: %s %v", entry.StartedDateTime, entry.Time)
+	}
+
+	if len(result.Skipped) != 2 ||
+		result.Skipped[0].String() != "flow 2: flow failed: connection refused" ||
+		result.Skipped[1].String() != "flow 3: unsupported flow type: tcp" {
+		t.Errorf("unexpected skipped items: %+v", result.Skipped)
+	}
+
+	if _, err := Mitmproxy(strings.NewReader("12:abc")); err == nil {
+		t.Error("expected error for truncated flow")
+	}
+}
+
+func TestResult_JSON(t *testing.T) {
+	result := newResult()
+	result.skip("item", "reason %d", 1)
+	data, err := json.Marshal(result.Skipped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `[{"item":"item","reason":"reason 1"}]` {
+		t.Errorf("unexpected JSON: %s", data)
+	}
+}
@@ -0,0 +1,251 @@
+package harimport
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/m-mizutani/harlog"
+)
+
+// Mitmproxy imports a mitmproxy flow file as written by "mitmdump -w". HTTP
+// flows with a response become entries; gzip and deflate encoded bodies are
+// decoded. Flows of other protocols and flows that failed without a
+// response are skipped.
+func Mitmproxy(r io.Reader) (*Result, error) {
+	result := newResult()
+	br := bufio.NewReader(r)
+
+	for n := 1; ; n++ {
+		if _, err := br.Peek(1); err == io.EOF {
+			break
+		}
+		item := fmt.Sprintf("flow %d", n)
+
+		v, err := readTNetString(br)
+		if err != nil {
+			// The rest of the stream cannot be located after a parse error
+			return nil, fmt.Errorf("failed to parse mitmproxy flow %d: %w", n, err)
+		}
+		flow, ok := v.(map[string]any)
+		if !ok {
+			result.skip(item, "not a flow")
+			continue
+		}
+
+		entry, err := convertFlow(flow)
+		if err != nil {
+			result.skip(item, "%v", err)
+			continue
+		}
+		result.add(entry)
+	}
+
+	return result, nil
+}
+
+func convertFlow(flow map[string]any) (harlog.HAREntry, error) {
+	if typ := asString(flow["type"]); typ != "http" {
+		return harlog.HAREntry{}, fmt.Errorf("unsupported flow type: %s", typ)
+	}
+	req, ok := flow["request"].(map[string]any)
+	if !ok {
+		return harlog.HAREntry{}, errors.New("flow has no request")
+	}
+	resp, ok := flow["response"].(map[string]any)
+	if !ok {
+		if e, ok := flow["error"].(map[string]any); ok {
+			return harlog.HAREntry{}, fmt.Errorf("flow failed: %s", asString(e["msg"]))
+		}
+		return harlog.HAREntry{}, errors.New("flow has no response")
+	}
+
+	host := asString(req["host"])
+	if authority := asString(req["authority"]); authority != "" {
+		host = authority
+	} else if port := asInt(req["port"]); port != 0 && !defaultPort(asString(req["scheme"]), port) {
+		host += ":" + strconv.Itoa(port)
+	}
+	rawURL := asString(req["scheme"]) + "://" + host + asString(req["path"])
+
+	reqHeaders := flowHeaders(req["headers"])
+	respHeaders := flowHeaders(resp["headers"])
+
+	entry := harlog.HAREntry{
+		Request: newRequest(asString(req["method"]), rawURL, asString(req["http_version"]),
+			reqHeaders, decodeContent(req["content"], reqHeaders)),
+		Response: newResponse(asInt(resp["status_code"]), asString(resp["reason"]), asString(resp["http_version"]),
+			respHeaders, decodeContent(resp["content"], respHeaders)),
+	}
+
+	if start := asFloat(req["timestamp_start"]); start > 0 {
+		sec, frac := math.Modf(start)
+		entry.StartedDateTime = time.Unix(int64(sec), int64(frac*1e9)).UTC().Format(time.RFC3339Nano)
+		if end := asFloat(resp["timestamp_end"]); end > start {
+			entry.Time = math.Round((end-start)*1e6) / 1e3
+		}
+	}
+	return entry, nil
+}
+
+func defaultPort(scheme string, port int) bool {
+	return scheme == "http" && port == 80 || scheme == "https" && port == 443
+}
+
+// flowHeaders converts a list of [name, value] pairs
+func flowHeaders(v any) []harlog.HARHeader {
+	headers := []harlog.HARHeader{}
+	list, _ := v.([]any)
+	for _, item := range list {
+		pair, ok := item.([]any)
+		if !ok || len(pair) != 2 {
+			continue
+		}
+		headers = append(headers, harlog.HARHeader{Name: asString(pair[0]), Value: asString(pair[1])})
+	}
+	return headers
+}
+
+// decodeContent returns the content with gzip and deflate encoding removed.
+// Other encodings are returned as they are.
+func decodeContent(v any, headers []harlog.HARHeader) []byte {
+	content, _ := v.([]byte)
+	if len(content) == 0 {
+		return content
+	}
+
+	var rd io.Reader
+	switch strings.ToLower(headerValue(headers, "Content-Encoding")) {
+	case "gzip", "x-gzip":
+		zr, err := gzip.NewReader(bytes.NewReader(content))
+		if err != nil {
+			return content
+		}
+		rd = zr
+	case "deflate":
+		rd = flate.NewReader(bytes.NewReader(content))
+	default:
+		return content
+	}
+
+	decoded, err := io.ReadAll(rd)
+	if err != nil {
+		return content
+	}
+	return decoded
+}
+
+func asString(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	default:
+		return ""
+	}
+}
+
+func asInt(v any) int {
+	switch v := v.(type) {
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
+	default:
+		return 0
+	}
+}
+
+func asFloat(v any) float64 {
+	switch v := v.(type) {
+	case int64:
+		return float64(v)
+	case float64:
+		return v
+	default:
+		return 0
+	}
+}
+
+// maxTNetStringSize bounds the size of a single value
+const maxTNetStringSize = 1 << 30
+
+// readTNetString reads a value encoded as a tnetstring, the serialization
+// used by mitmproxy flow files: "<length>:<payload><type>". Byte strings are
+// returned as []byte, strings as string, integers as int64, lists as []any
+// and dictionaries as map[string]any.
+func readTNetString(br *bufio.Reader) (any, error) {
+	prefix, err := br.ReadString(':')
+	if err != nil {
+		return nil, err
+	}
+	size, err := strconv.Atoi(prefix[:len(prefix)-1])
+	if err != nil || size < 0 || size > maxTNetStringSize {
+		return nil, fmt.Errorf("invalid length %q", prefix)
+	}
+
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(br, payload); err != nil {
+		return nil, err
+	}
+	typ, err := br.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	switch typ {
+	case ',':
+		return payload, nil
+	case ';':
+		return string(payload), nil
+	case '#':
+		return strconv.ParseInt(string(payload), 10, 64)
+	case '^':
+		return strconv.ParseFloat(string(payload), 64)
+	case '!':
+		return string(payload) == "true", nil
+	case '~':
+		return nil, nil
+	case ']':
+		list := []any{}
+		pr := bufio.NewReader(bytes.NewReader(payload))
+		for {
+			if _, err := pr.Peek(1); err == io.EOF {
+				return list, nil
+			}
+			v, err := readTNetString(pr)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+	case '}':
+		dict := map[string]any{}
+		pr := bufio.NewReader(bytes.NewReader(payload))
+		for {
+			if _, err := pr.Peek(1); err == io.EOF {
+				return dict, nil
+			}
+			key, err := readTNetString(pr)
+			if err != nil {
+				return nil, err
+			}
+			value, err := readTNetString(pr)
+			if err != nil {
+				return nil, err
+			}
+			dict[asString(key)] = value
+		}
+	default:
+		return nil, fmt.Errorf("unknown type %q", typ)
+	}
+}
@@ -0,0 +1,364 @@
+package harimport
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/m-mizutani/harlog"
+)
+
+// postmanCollection is the subset of Postman Collection v2.0 and v2.1 used
+// for import
+type postmanCollection struct {
+	Info struct {
+		Name   string `json:"name"`
+		Schema string `json:"schema"`
+	} `json:"info"`
+	Item     []postmanItem     `json:"item"`
+	Auth     *postmanAuth      `json:"auth"`
+	Variable []postmanKeyValue `json:"variable"`
+}
+
+type postmanItem struct {
+	Name     string            `json:"name"`
+	Item     []postmanItem     `json:"item"`
+	Auth     *postmanAuth      `json:"auth"`
+	Request  *postmanRequest   `json:"request"`
+	Response []postmanResponse `json:"response"`
+}
+
+type postmanRequest struct {
+	Method string            `json:"method"`
+	Header []postmanKeyValue `json:"header"`
+	URL    postmanURL        `json:"url"`
+	Body   *postmanBody      `json:"body"`
+	Auth   *postmanAuth      `json:"auth"`
+}
+
+// UnmarshalJSON accepts requests given as a plain URL string
+func (r *postmanRequest) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*r = postmanRequest{Method: "GET", URL: postmanURL{Raw: s}}
+		return nil
+	}
+
+	type plain postmanRequest
+	var p plain
+	if err := json.Unmarshal(data, &p); err != nil {
+		return err
+	}
+	*r = postmanRequest(p)
+	return nil
+}
+
+type postmanURL struct {
+	Raw string `json:"raw"`
+}
+
+// UnmarshalJSON accepts URLs given as a string or as an object with a raw
+// field
+func (u *postmanURL) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		u.Raw = s
+		return nil
+	}
+
+	var obj struct {
+		Raw string `json:"raw"`
+	}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return err
+	}
+	u.Raw = obj.Raw
+	return nil
+}
+
+type postmanKeyValue struct {
+	Key      string `json:"key"`
+	Value    any    `json:"value"`
+	Disabled bool   `json:"disabled"`
+}
+
+// value returns the value as a string; collections may hold numbers and
+// booleans in variables
+func (kv postmanKeyValue) value() string {
+	switch v := kv.Value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+type postmanBody struct {
+	Mode       string            `json:"mode"`
+	Raw        string            `json:"raw"`
+	URLEncoded []postmanKeyValue `json:"urlencoded"`
+	GraphQL    *struct {
+		Query     string `json:"query"`
+		Variables string `json:"variables"`
+	} `json:"graphql"`
+	Options struct {
+		Raw struct {
+			Language string `json:"language"`
+		} `json:"raw"`
+	} `json:"options"`
+}
+
+type postmanAuth struct {
+	Type   string            `json:"type"`
+	Bearer []postmanKeyValue `json:"bearer"`
+	Basic  []postmanKeyValue `json:"basic"`
+	APIKey []postmanKeyValue `json:"apikey"`
+}
+
+type postmanResponse struct {
+	Name            string            `json:"name"`
+	OriginalRequest *postmanRequest   `json:"originalRequest"`
+	Code            int               `json:"code"`
+	Status          string            `json:"status"`
+	Header          []postmanKeyValue `json:"header"`
+	Body            string            `json:"body"`
+}
+
+var postmanVariable = regexp.MustCompile(`\{\{\s*([^{}]+?)\s*\}\}`)
+
+// postmanImporter holds the state of a Postman import
+type postmanImporter struct {
+	variables map[string]string
+	result    *Result
+}
+
+// Postman imports a Postman Collection v2.0 or v2.1. Each saved example
+// response becomes an entry with its original request; requests without
+// examples become entries with an empty response. Collection variables are
+// substituted, and bearer, basic and API key header authentication,
+// including auth inherited from folders, is converted to headers. Requests
+// with form-data or file bodies, or with URLs that remain relative after
+// substitution, are skipped.
+func Postman(r io.Reader) (*Result, error) {
+	var collection postmanCollection
+	if err := json.NewDecoder(r).Decode(&collection); err != nil {
+		return nil, fmt.Errorf("failed to parse Postman collection: %w", err)
+	}
+	if collection.Info.Schema != "" && !strings.Contains(collection.Info.Schema, "v2.") {
+		return nil, fmt.Errorf("unsupported Postman collection schema: %s", collection.Info.Schema)
+	}
+
+	p := &postmanImporter{
+		variables: make(map[string]string),
+		result:    newResult(),
+	}
+	for _, v := range collection.Variable {
+		if !v.Disabled {
+			p.variables[v.Key] = v.value()
+		}
+	}
+	p.importItems(collection.Item, "", collection.Auth)
+
+	return p.result, nil
+}
+
+func (p *postmanImporter) importItems(items []postmanItem, parent string, auth *postmanAuth) {
+	for _, item := range items {
+		name := item.Name
+		if parent != "" {
+			name = parent + "/" + item.Name
+		}
+		itemAuth := auth
+		if item.Auth != nil {
+			itemAuth = item.Auth
+		}
+
+		if item.Request == nil {
+			p.importItems(item.Item, name, itemAuth)
+			continue
+		}
+		if item.Request.Auth != nil {
+			itemAuth = item.Request.Auth
+		}
+
+		if len(item.Response) == 0 {
+			req, err := p.convertRequest(item.Request, itemAuth)
+			if err != nil {
+				p.result.skip(name, "%v", err)
+				continue
+			}
+			p.result.add(harlog.HAREntry{
+				Request:  req,
+				Response: newResponse(0, "", "", nil, nil),
+				Comment:  name,
+			})
+			continue
+		}
+
+		for _, example := range item.Response {
+			exampleName := name + "/" + example.Name
+			original := example.OriginalRequest
+			if original == nil {
+				original = item.Request
+			}
+			req, err := p.convertRequest(original, itemAuth)
+			if err != nil {
+				p.result.skip(exampleName, "%v", err)
+				continue
+			}
+
+			headers := p.convertHeaders(example.Header)
+			p.result.add(harlog.HAREntry{
+				Request:  req,
+				Response: newResponse(example.Code, example.Status, "", headers, []byte(example.Body)),
+				Comment:  exampleName,
+			})
+		}
+	}
+}
+
+func (p *postmanImporter) convertRequest(pr *postmanRequest, auth *postmanAuth) (harlog.HARRequest, error) {
+	rawURL := p.substitute(pr.URL.Raw)
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "http://" + rawURL
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" || postmanVariable.MatchString(u.Host) {
+		return harlog.HARRequest{}, fmt.Errorf("URL %q cannot be resolved", pr.URL.Raw)
+	}
+
+	headers := p.convertHeaders(pr.Header)
+	if auth != nil {
+		h, err := p.authHeader(auth)
+		if err != nil {
+			return harlog.HARRequest{}, err
+		}
+		if h != nil && headerValue(headers, h.Name) == "" {
+			headers = append(headers, *h)
+		}
+	}
+
+	body, contentType, err := p.convertBody(pr.Body)
+	if err != nil {
+		return harlog.HARRequest{}, err
+	}
+	if contentType != "" && headerValue(headers, "Content-Type") == "" {
+		headers = append(headers, harlog.HARHeader{Name: "Content-Type", Value: contentType})
+	}
+
+	method := pr.Method
+	if method == "" {
+		method = "GET"
+	}
+	return newRequest(strings.ToUpper(method), u.String(), "HTTP/1.1", headers, body), nil
+}
+
+func (p *postmanImporter) convertHeaders(kvs []postmanKeyValue) []harlog.HARHeader {
+	headers := []harlog.HARHeader{}
+	for _, kv := range kvs {
+		if !kv.Disabled {
+			headers = append(headers, harlog.HARHeader{Name: kv.Key, Value: p.substitute(kv.value())})
+		}
+	}
+	return headers
+}
+
+// convertBody returns the body and its default content type
+func (p *postmanImporter) convertBody(body *postmanBody) ([]byte, string, error) {
+	if body == nil {
+		return nil, "", nil
+	}
+
+	switch body.Mode {
+	case "", "none":
+		return nil, "", nil
+
+	case "raw":
+		var contentType string
+		switch body.Options.Raw.Language {
+		case "json":
+			contentType = "application/json"
+		case "xml":
+			contentType = "application/xml"
+		case "html":
+			contentType = "text/html"
+		case "text":
+			contentType = "text/plain"
+		}
+		return []byte(p.substitute(body.Raw)), contentType, nil
+
+	case "urlencoded":
+		form := make([]string, 0, len(body.URLEncoded))
+		for _, kv := range body.URLEncoded {
+			if !kv.Disabled {
+				form = append(form, url.QueryEscape(p.substitute(kv.Key))+"="+url.QueryEscape(p.substitute(kv.value())))
+			}
+		}
+		return []byte(strings.Join(form, "&")), "application/x-www-form-urlencoded", nil
+
+	case "graphql":
+		if body.GraphQL == nil {
+			return nil, "", nil
+		}
+		payload := map[string]any{"query": body.GraphQL.Query}
+		if vars := strings.TrimSpace(p.substitute(body.GraphQL.Variables)); vars != "" {
+			payload["variables"] = json.RawMessage(vars)
+		}
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid GraphQL variables: %w", err)
+		}
+		return data, "application/json", nil
+
+	default:
+		return nil, "", fmt.Errorf("unsupported body mode: %s", body.Mode)
+	}
+}
+
+// authHeader converts auth to a request header. It returns nil for auth
+// that does not set a header, such as noauth or API keys in the query.
+func (p *postmanImporter) authHeader(auth *postmanAuth) (*harlog.HARHeader, error) {
+	param := func(kvs []postmanKeyValue, key string) string {
+		for _, kv := range kvs {
+			if kv.Key == key {
+				return p.substitute(kv.value())
+			}
+		}
+		return ""
+	}
+
+	switch auth.Type {
+	case "", "noauth":
+		return nil, nil
+	case "bearer":
+		return &harlog.HARHeader{Name: "Authorization", Value: "Bearer " + param(auth.Bearer, "token")}, nil
+	case "basic":
+		credentials := param(auth.Basic, "username") + ":" + param(auth.Basic, "password")
+		return &harlog.HARHeader{Name: "Authorization", Value: "Basic " + base64.StdEncoding.EncodeToString([]byte(credentials))}, nil
+	case "apikey":
+		if in := param(auth.APIKey, "in"); in != "" && in != "header" {
+			return nil, nil
+		}
+		return &harlog.HARHeader{Name: param(auth.APIKey, "key"), Value: param(auth.APIKey, "value")}, nil
+	default:
+		return nil, fmt.Errorf("unsupported auth type: %s", auth.Type)
+	}
+}
+
+// substitute replaces {{name}} with the value of collection variables.
+// Unknown variables are left as they are.
+func (p *postmanImporter) substitute(s string) string {
+	return postmanVariable.ReplaceAllStringFunc(s, func(m string) string {
+		name := postmanVariable.FindStringSubmatch(m)[1]
+		if v, ok := p.variables[name]; ok {
+			return v
+		}
+		return m
+	})
+}
@@ -0,0 +1,85 @@
+package hartest
+
+import (
+	"strings"
+)
+
+// maxDiffCells bounds the size of the LCS table; larger inputs are shown in
+// full instead
+const maxDiffCells = 4_000_000
+
+// lineDiff returns a unified style line diff from want to got, prefixing
+// removed lines with "-" and added lines with "+"
+func lineDiff(want, got string) string {
+	a, b := splitLines(want), splitLines(got)
+	if len(a)*len(b) > maxDiffCells {
+		return "--- golden\n" + want + "\n+++ captured\n" + got + "\n"
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var lines []string
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, "  "+a[i])
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] >= lcs[i+1][j]):
+			lines = append(lines, "+ "+b[j])
+			j++
+		default:
+			lines = append(lines, "- "+a[i])
+			i++
+		}
+	}
+
+	// Print changed lines with a few lines of context around them
+	var sb strings.Builder
+	sb.WriteString("--- golden\n+++ captured\n")
+	last := -1
+	for k, line := range lines {
+		if !nearChange(lines, k) {
+			continue
+		}
+		if last >= 0 && k > last+1 {
+			sb.WriteString("  ...\n")
+		}
+		sb.WriteString(line + "\n")
+		last = k
+	}
+	return sb.String()
+}
+
+// diffContext is the number of unchanged lines shown around changes
+const diffContext = 3
+
+func nearChange(lines []string, k int) bool {
+	for i := max(0, k-diffContext); i <= min(len(lines)-1, k+diffContext); i++ {
+		if !strings.HasPrefix(lines[i], "  ") {
+			return true
+		}
+	}
+	return false
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
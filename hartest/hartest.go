@@ -0,0 +1,211 @@
+// Package hartest provides golden file assertions for HTTP traffic captured
+// with harlog.
+//
+// A Capture records the traffic of a test through a harlog.Logger, and
+// AssertGolden compares the captured entries with a golden HAR file after
+// normalizing values that change between runs, such as timings and dates.
+// Run the tests with HARTEST_UPDATE=1, or with -update if the test package
+// defines an -update flag, to rewrite golden files.
+package hartest
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/m-mizutani/harlog"
+)
+
+// updateGolden reports whether golden files should be rewritten. The -update
+// flag is looked up when the assertion runs, as it belongs to the test package.
+func updateGolden() bool {
+	if v, err := strconv.ParseBool(os.Getenv("HARTEST_UPDATE")); err == nil && v {
+		return true
+	}
+	if f := flag.Lookup("update"); f != nil {
+		v, _ := strconv.ParseBool(f.Value.String())
+		return v
+	}
+	return false
+}
+
+// Capture records HTTP traffic of a test
+type Capture struct {
+	t        testing.TB
+	Recorder *harlog.Recorder
+	Logger   *harlog.Logger
+}
+
+// NewCapture creates a Capture whose Logger keeps entries in memory instead
+// of writing HAR files. Options are passed to harlog.New.
+func NewCapture(t testing.TB, opts ...harlog.Option) *Capture {
+	t.Helper()
+
+	c := &Capture{t: t, Recorder: harlog.NewRecorder()}
+	opts = append(opts,
+		harlog.WithFileOutput(false),
+		harlog.WithSink(c.Recorder),
+	)
+	c.Logger = harlog.New(opts...)
+	return c
+}
+
+// Client returns an http.Client that records requests through the Logger
+func (c *Capture) Client() *http.Client {
+	return &http.Client{Transport: c.Logger}
+}
+
+// Middleware wraps next so that requests served by it are recorded
+func (c *Capture) Middleware(next http.Handler) http.Handler {
+	return c.Logger.Middleware(next)
+}
+
+// HAR returns the entries recorded so far in the order they completed
+func (c *Capture) HAR() *harlog.HAR {
+	return newHAR(c.Recorder.Entries())
+}
+
+// AssertGolden compares the captured traffic with the golden file
+func (c *Capture) AssertGolden(golden string, normalizers ...Normalizer) {
+	c.t.Helper()
+	AssertGolden(c.t, golden, c.HAR(), normalizers...)
+}
+
+// AssertGolden compares the entries of har with those of the golden HAR file
+// after applying DefaultNormalizers and the given normalizers to both. When
+// updating, the normalized entries are written to the golden file instead.
+func AssertGolden(t testing.TB, golden string, har *harlog.HAR, normalizers ...Normalizer) {
+	t.Helper()
+
+	normalizers = append(DefaultNormalizers(), normalizers...)
+	actual := Normalize(har, normalizers...)
+
+	if updateGolden() {
+		if err := writeGolden(golden, actual); err != nil {
+			t.Fatalf("hartest: failed to update golden file: %v", err)
+		}
+		t.Logf("hartest: updated golden file %s", golden)
+		return
+	}
+
+	expected, err := harlog.ReadHARFile(golden)
+	if err != nil {
+		t.Fatalf("hartest: %v (run tests with -update to create it)", err)
+	}
+	expected = Normalize(expected, normalizers...)
+
+	if msg := compareEntries(expected.Log.Entries, actual.Log.Entries); msg != "" {
+		t.Errorf("hartest: captured traffic does not match golden file %s\n%s", golden, msg)
+	}
+}
+
+// Normalize returns a copy of har with the normalizers applied to each entry
+func Normalize(har *harlog.HAR, normalizers ...Normalizer) *harlog.HAR {
+	normalized := newHAR(nil)
+	for _, entry := range har.Log.Entries {
+		entry := copyEntry(entry)
+		for _, n := range normalizers {
+			n(&entry)
+		}
+		normalized.Log.Entries = append(normalized.Log.Entries, entry)
+	}
+	return normalized
+}
+
+func newHAR(entries []harlog.HAREntry) *harlog.HAR {
+	if entries == nil {
+		entries = []harlog.HAREntry{}
+	}
+	return &harlog.HAR{
+		Log: harlog.HARLog{
+			Version: "1.2",
+			Creator: harlog.HARCreator{Name: "harlog", Version: "1.0"},
+			Entries: entries,
+		},
+	}
+}
+
+// copyEntry returns a deep copy of entry
+func copyEntry(entry harlog.HAREntry) harlog.HAREntry {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return entry
+	}
+	var copied harlog.HAREntry
+	if err := json.Unmarshal(data, &copied); err != nil {
+		return entry
+	}
+	return copied
+}
+
+func writeGolden(golden string, har *harlog.HAR) error {
+	data, err := marshalIndent(har)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(golden), 0750); err != nil {
+		return err
+	}
+	return os.WriteFile(golden, data, 0600)
+}
+
+// marshalIndent encodes v as indented JSON without escaping HTML characters,
+// which keeps URLs in golden files readable
+func marshalIndent(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// compareEntries returns a description of the first mismatching entry, or an
+// empty string if the entries are equal
+func compareEntries(expected, actual []harlog.HAREntry) string {
+	n := max(len(expected), len(actual))
+	for i := 0; i < n; i++ {
+		var want, got string
+		if i < len(expected) {
+			want = marshalEntry(expected[i])
+		}
+		if i < len(actual) {
+			got = marshalEntry(actual[i])
+		}
+		if want == got {
+			continue
+		}
+
+		msg := fmt.Sprintf("entry %d differs (golden has %d entries, captured %d):\n", i, len(expected), len(actual))
+		switch {
+		case i >= len(expected):
+			msg += fmt.Sprintf("unexpected entry %s\n", describeEntry(actual[i]))
+		case i >= len(actual):
+			msg += fmt.Sprintf("missing entry %s\n", describeEntry(expected[i]))
+		default:
+			msg += fmt.Sprintf("%s\n", describeEntry(expected[i]))
+		}
+		return msg + lineDiff(want, got)
+	}
+	return ""
+}
+
+func marshalEntry(entry harlog.HAREntry) string {
+	data, err := marshalIndent(entry)
+	if err != nil {
+		return fmt.Sprintf("%+v", entry)
+	}
+	return string(data)
+}
+
+func describeEntry(entry harlog.HAREntry) string {
+	return entry.Request.Method + " " + entry.Request.URL
+}
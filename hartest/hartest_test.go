@@ -0,0 +1,171 @@
+package hartest
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/m-mizutani/harlog"
+)
+
+// update is defined as a test package would, which hartest must not collide
+// with
+var update = flag.Bool("update", false, "update golden files")
+
+// recordingT records failures instead of failing the test
+type recordingT struct {
+	testing.TB
+	failed bool
+	msg    string
+}
+
+func (r *recordingT) Helper() {}
+
+func (r *recordingT) Errorf(format string, args ...any) {
+	r.failed = true
+	r.msg = fmt.Sprintf(format, args...)
+}
+
+func (r *recordingT) Fatalf(format string, args ...any) {
+	r.Errorf(format, args...)
+}
+
+func (r *recordingT) Logf(format string, args ...any) {}
+
+func newTestServer(t *testing.T, greeting string) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Request-Id", uuid.NewString())
+		fmt.Fprintf(w, `{"message": %q, "id": %q}`, greeting, uuid.NewString())
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func doRequests(t *testing.T, client *http.Client, server *httptest.Server) {
+	t.Helper()
+
+	for _, path := range []string{"/hello?lang=en&b=2", "/users/1"} {
+		resp, err := client.Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+}
+
+func TestCapture_AssertGolden(t *testing.T) {
+	t.Parallel()
+
+	server := newTestServer(t, "hello")
+	capture := NewCapture(t)
+	doRequests(t, capture.Client(), server)
+
+	if n := len(capture.HAR().Log.Entries); n != 2 {
+		t.Fatalf("expected 2 entries, got %d", n)
+	}
+	capture.AssertGolden("testdata/hello.golden.har", StripIDs())
+}
+
+func TestAssertGolden_Mismatch(t *testing.T) {
+	t.Parallel()
+
+	server := newTestServer(t, "goodbye")
+	capture := NewCapture(t)
+	doRequests(t, capture.Client(), server)
+
+	rt := &recordingT{TB: t}
+	AssertGolden(rt, "testdata/hello.golden.har", capture.HAR(), StripIDs())
+	if !rt.failed {
+		t.Fatal("expected mismatch")
+	}
+
+	for _, want := range []string{
+		"entry 0 differs",
+		"GET http://127.0.0.1:0/hello?lang=en&b=2",
+		`-       "text": "{\"message\": \"hello\"`,
+		`+       "text": "{\"message\": \"goodbye\"`,
+	} {
+		if !strings.Contains(rt.msg, want) {
+			t.Errorf("message does not contain %q:\n%s", want, rt.msg)
+		}
+	}
+	if strings.Contains(rt.msg, `"httpVersion"`) {
+		t.Errorf("unchanged lines far from the change should be omitted:\n%s", rt.msg)
+	}
+}
+
+func TestAssertGolden_Update(t *testing.T) {
+	t.Setenv("HARTEST_UPDATE", "1")
+
+	golden := filepath.Join(t.TempDir(), "golden", "new.har")
+	har := &harlog.HAR{
+		Log: harlog.HARLog{
+			Entries: []harlog.HAREntry{{
+				StartedDateTime: "2025-03-09T11:49:14Z",
+				Time:            12,
+				Request:         harlog.HARRequest{Method: "GET", URL: "http://localhost:8080/"},
+				Response: harlog.HARResponse{
+					Status: 200,
+					Headers: []harlog.HARHeader{
+						{Name: "X-B", Value: "2"},
+						{Name: "Date", Value: "Sun, 09 Mar 2025 02:49:14 GMT"},
+					},
+				},
+			}},
+		},
+	}
+	AssertGolden(t, golden, har)
+
+	written, err := harlog.ReadHARFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := written.Log.Entries[0]
+	if entry.Time != 0 || entry.StartedDateTime != PlaceholderDate {
+		t.Errorf("entry was not normalized: %+v", entry)
+	}
+	if entry.Request.URL != "http://localhost:0/" {
+		t.Errorf("port was not stripped: %s", entry.Request.URL)
+	}
+	if entry.Response.Headers[0].Name != "Date" || entry.Response.Headers[0].Value != PlaceholderDate {
+		t.Errorf("headers were not normalized: %+v", entry.Response.Headers)
+	}
+	if har.Log.Entries[0].Time != 12 {
+		t.Error("source HAR must not be modified")
+	}
+}
+
+func TestAssertGolden_UpdateFlag(t *testing.T) {
+	if err := flag.Set("update", "true"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { *update = false })
+
+	golden := filepath.Join(t.TempDir(), "flag.har")
+	AssertGolden(t, golden, &harlog.HAR{})
+	if _, err := harlog.ReadHARFile(golden); err != nil {
+		t.Errorf("expected golden file written with -update: %v", err)
+	}
+}
+
+func TestStripHeaders(t *testing.T) {
+	entry := harlog.HAREntry{
+		Request: harlog.HARRequest{
+			Headers: []harlog.HARHeader{{Name: "Authorization", Value: "secret"}, {Name: "Accept", Value: "*/*"}},
+		},
+	}
+	StripHeaders("authorization")(&entry)
+	if len(entry.Request.Headers) != 1 || entry.Request.Headers[0].Name != "Accept" {
+		t.Errorf("unexpected headers: %+v", entry.Request.Headers)
+	}
+}
@@ -0,0 +1,167 @@
+package hartest
+
+import (
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/m-mizutani/harlog"
+)
+
+// Normalizer rewrites an entry before comparison so that values changing
+// between test runs do not cause failures
+type Normalizer func(entry *harlog.HAREntry)
+
+// DefaultNormalizers are applied by AssertGolden before any user provided
+// normalizer
+func DefaultNormalizers() []Normalizer {
+	return []Normalizer{
+		StripTimings(),
+		StripDates(),
+		StripLoopbackPorts(),
+		SortHeaders(),
+	}
+}
+
+// Placeholders written by the normalizers
+const (
+	PlaceholderDate = "0001-01-01T00:00:00Z"
+	PlaceholderUUID = "00000000-0000-0000-0000-000000000000"
+)
+
+// StripTimings resets the total time and timings of the entry
+func StripTimings() Normalizer {
+	return func(entry *harlog.HAREntry) {
+		entry.Time = 0
+		entry.Timings = harlog.HARTimings{}
+	}
+}
+
+// dateHeaders are headers holding timestamps
+var dateHeaders = map[string]bool{
+	"Date":          true,
+	"Expires":       true,
+	"Last-Modified": true,
+}
+
+// StripDates replaces startedDateTime and the values of date headers such as
+// Date and Last-Modified with PlaceholderDate
+func StripDates() Normalizer {
+	return func(entry *harlog.HAREntry) {
+		entry.StartedDateTime = PlaceholderDate
+		for _, headers := range [][]harlog.HARHeader{entry.Request.Headers, entry.Response.Headers} {
+			for i := range headers {
+				if dateHeaders[http.CanonicalHeaderKey(headers[i].Name)] {
+					headers[i].Value = PlaceholderDate
+				}
+			}
+		}
+	}
+}
+
+var loopbackAddr = regexp.MustCompile(`\b(127\.0\.0\.1|localhost|\[::1\]):[0-9]+`)
+
+// StripLoopbackPorts replaces the random ports of httptest servers in URLs and
+// header values with 0, e.g. "127.0.0.1:41234" becomes "127.0.0.1:0"
+func StripLoopbackPorts() Normalizer {
+	return ReplaceAll(loopbackAddr, "${1}:0")
+}
+
+var uuidPattern = regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`)
+
+// StripIDs replaces UUIDs in URLs, headers and bodies with PlaceholderUUID
+func StripIDs() Normalizer {
+	return ReplaceAll(uuidPattern, PlaceholderUUID)
+}
+
+// StripHeaders removes request and response headers with the given names
+func StripHeaders(names ...string) Normalizer {
+	strip := make(map[string]bool, len(names))
+	for _, name := range names {
+		strip[http.CanonicalHeaderKey(name)] = true
+	}
+
+	filter := func(headers []harlog.HARHeader) []harlog.HARHeader {
+		kept := headers[:0]
+		for _, h := range headers {
+			if !strip[http.CanonicalHeaderKey(h.Name)] {
+				kept = append(kept, h)
+			}
+		}
+		return kept
+	}
+
+	return func(entry *harlog.HAREntry) {
+		entry.Request.Headers = filter(entry.Request.Headers)
+		entry.Response.Headers = filter(entry.Response.Headers)
+	}
+}
+
+// SortHeaders orders headers and query parameters by name and value, since
+// harlog records them in map iteration order
+func SortHeaders() Normalizer {
+	sortPairs := func(n int, name, value func(i int) string, swap func(i, j int)) {
+		sort.Sort(pairs{n: n, name: name, value: value, swap: swap})
+	}
+
+	return func(entry *harlog.HAREntry) {
+		for _, headers := range [][]harlog.HARHeader{entry.Request.Headers, entry.Response.Headers} {
+			sortPairs(len(headers),
+				func(i int) string { return headers[i].Name },
+				func(i int) string { return headers[i].Value },
+				func(i, j int) { headers[i], headers[j] = headers[j], headers[i] },
+			)
+		}
+
+		query := entry.Request.QueryString
+		sortPairs(len(query),
+			func(i int) string { return query[i].Name },
+			func(i int) string { return query[i].Value },
+			func(i, j int) { query[i], query[j] = query[j], query[i] },
+		)
+	}
+}
+
+// pairs implements sort.Interface for name/value lists
+type pairs struct {
+	n     int
+	name  func(i int) string
+	value func(i int) string
+	swap  func(i, j int)
+}
+
+func (p pairs) Len() int      { return p.n }
+func (p pairs) Swap(i, j int) { p.swap(i, j) }
+func (p pairs) Less(i, j int) bool {
+	if c := strings.Compare(strings.ToLower(p.name(i)), strings.ToLower(p.name(j))); c != 0 {
+		return c < 0
+	}
+	return p.value(i) < p.value(j)
+}
+
+// ReplaceAll replaces matches of re in the URL, header values, query values
+// and bodies of the entry with repl, which may refer to submatches as in
+// regexp.Regexp.ReplaceAllString
+func ReplaceAll(re *regexp.Regexp, repl string) Normalizer {
+	replace := func(s string) string {
+		return re.ReplaceAllString(s, repl)
+	}
+
+	return func(entry *harlog.HAREntry) {
+		entry.Request.URL = replace(entry.Request.URL)
+		for i := range entry.Request.Headers {
+			entry.Request.Headers[i].Value = replace(entry.Request.Headers[i].Value)
+		}
+		for i := range entry.Request.QueryString {
+			entry.Request.QueryString[i].Value = replace(entry.Request.QueryString[i].Value)
+		}
+		if entry.Request.PostData != nil {
+			entry.Request.PostData.Text = replace(entry.Request.PostData.Text)
+		}
+		for i := range entry.Response.Headers {
+			entry.Response.Headers[i].Value = replace(entry.Response.Headers[i].Value)
+		}
+		entry.Response.Content.Text = replace(entry.Response.Content.Text)
+	}
+}
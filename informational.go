@@ -0,0 +1,84 @@
+package harlog
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptrace"
+	"net/textproto"
+	"strings"
+)
+
+// informationalRecorder collects the informational (1xx) responses, such as
+// 100 Continue and 103 Early Hints, received before the final response
+type informationalRecorder struct {
+	responses []HARInformational
+}
+
+type informationalCtxKey struct{}
+
+// traceInformational returns req with a trace recording its informational
+// responses. Traces already set in the context of req are still called.
+func traceInformational(req *http.Request) *http.Request {
+	rec := &informationalRecorder{}
+	trace := &httptrace.ClientTrace{
+		Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+			rec.responses = append(rec.responses, newInformational(code, http.Header(header)))
+			return nil
+		},
+	}
+	ctx := context.WithValue(httptrace.WithClientTrace(req.Context(), trace), informationalCtxKey{}, rec)
+	return req.WithContext(ctx)
+}
+
+// informationalFor returns the informational responses received before resp,
+// if its request was traced by traceInformational
+func informationalFor(resp *http.Response) []HARInformational {
+	if resp.Request == nil {
+		return nil
+	}
+	rec, _ := resp.Request.Context().Value(informationalCtxKey{}).(*informationalRecorder)
+	if rec == nil {
+		return nil
+	}
+	return rec.responses
+}
+
+// newInformational records an informational response with the headers h
+func newInformational(code int, h http.Header) HARInformational {
+	return HARInformational{
+		Status:     code,
+		StatusText: http.StatusText(code),
+		Headers:    convertHeaders(h),
+	}
+}
+
+// expectsContinue reports whether r waits for 100 Continue, which the server
+// sends once the body is read, before sending its body
+func expectsContinue(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Expect"), "100-continue") && r.ProtoAtLeast(1, 1)
+}
+
+// splitTrailers separates the trailers set by a handler in h, either declared
+// in the Trailer header or prefixed with http.TrailerPrefix, from the headers
+func splitTrailers(h http.Header) (headers, trailers http.Header) {
+	declared := map[string]bool{}
+	for _, value := range h.Values("Trailer") {
+		for _, name := range strings.Split(value, ",") {
+			declared[http.CanonicalHeaderKey(strings.TrimSpace(name))] = true
+		}
+	}
+
+	headers, trailers = http.Header{}, http.Header{}
+	for name, values := range h {
+		switch {
+		case strings.HasPrefix(name, http.TrailerPrefix):
+			name = http.CanonicalHeaderKey(strings.TrimPrefix(name, http.TrailerPrefix))
+			trailers[name] = append(trailers[name], values...)
+		case declared[name]:
+			trailers[name] = append(trailers[name], values...)
+		default:
+			headers[name] = values
+		}
+	}
+	return headers, trailers
+}
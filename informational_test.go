@@ -0,0 +1,151 @@
+package harlog
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func statuses(responses []HARInformational) []int {
+	var codes []int
+	for _, r := range responses {
+		codes = append(codes, r.Status)
+	}
+	return codes
+}
+
+func headerValue(headers []HARHeader, name string) string {
+	for _, h := range headers {
+		if h.Name == name {
+			return h.Value
+		}
+	}
+	return ""
+}
+
+func TestInformationalAndTrailers(t *testing.T) {
+	t.Parallel()
+
+	serverRec, clientRec, proxyRec := NewRecorder(), NewRecorder(), NewRecorder()
+	serverLogger := New(WithFileOutput(false), WithSink(serverRec))
+	server := httptest.NewServer(serverLogger.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Link", "</style.css>; rel=preload; as=style")
+		w.WriteHeader(http.StatusEarlyHints)
+		w.Header().Del("Link")
+		_, _ = io.Copy(io.Discard, r.Body)
+		w.Header().Set("Trailer", "X-Checksum")
+		_, _ = io.WriteString(w, "hello")
+		w.Header().Set("X-Checksum", "abc")
+		w.Header().Set(http.TrailerPrefix+"X-Undeclared", "def")
+	})))
+	defer server.Close()
+
+	client := &http.Client{Transport: New(WithFileOutput(false), WithSink(clientRec))}
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/upload", strings.NewReader("data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Expect", "100-continue")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected final response, got %d", resp.StatusCode)
+	}
+
+	for name, entry := range map[string]HAREntry{
+		"client": waitEntry(t, clientRec, http.MethodPost, "/upload"),
+		"server": waitEntry(t, serverRec, http.MethodPost, "/upload"),
+	} {
+		got := statuses(entry.Response.Informational)
+		if len(got) != 2 || got[0] != http.StatusEarlyHints || got[1] != http.StatusContinue {
+			t.Errorf("%s: expected 103 and 100, got %v", name, got)
+			continue
+		}
+		if entry.Request.PostData == nil || entry.Request.PostData.Text != "data" {
+			t.Errorf("%s: expected request body, got %+v", name, entry.Request.PostData)
+		}
+		if link := headerValue(entry.Response.Informational[0].Headers, "Link"); !strings.Contains(link, "preload") {
+			t.Errorf("%s: expected early hints, got %+v", name, entry.Response.Informational[0])
+		}
+		if headerValue(entry.Response.Trailers, "X-Checksum") != "abc" || headerValue(entry.Response.Trailers, "X-Undeclared") != "def" {
+			t.Errorf("%s: expected trailers, got %+v", name, entry.Response.Trailers)
+		}
+		if headerValue(entry.Response.Headers, "X-Checksum") != "" || headerValue(entry.Response.Headers, http.TrailerPrefix+"X-Undeclared") != "" {
+			t.Errorf("%s: expected trailers not in headers, got %+v", name, entry.Response.Headers)
+		}
+	}
+
+	// Responses streamed through the reverse proxy keep the trailers
+	target, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxy := httptest.NewServer(NewReverseProxy(target, WithFileOutput(false), WithSink(proxyRec)))
+	defer proxy.Close()
+	resp, err = http.Get(proxy.URL + "/proxied")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	entry := waitEntry(t, proxyRec, http.MethodGet, "/proxied")
+	if headerValue(entry.Response.Trailers, "X-Checksum") != "abc" {
+		t.Errorf("expected proxied trailers, got %+v", entry.Response.Trailers)
+	}
+	if got := statuses(entry.Response.Informational); len(got) != 1 || got[0] != http.StatusEarlyHints {
+		t.Errorf("expected proxied early hints, got %v", got)
+	}
+
+	// The parser restores the trailers
+	msg, err := ConvertEntry(&entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Response.Trailer.Get("X-Checksum") != "abc" || msg.Response.Trailer.Get("X-Undeclared") != "def" {
+		t.Errorf("expected trailers, got %v", msg.Response.Trailer)
+	}
+}
+
+func TestInformational_ContinueUnread(t *testing.T) {
+	t.Parallel()
+
+	rec := NewRecorder()
+	logger := New(WithFileOutput(false), WithSink(rec))
+	server := httptest.NewServer(logger.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		if r.URL.Path == "/late" {
+			// Reading after the final response does not send 100 Continue
+			_, _ = io.Copy(io.Discard, r.Body)
+		}
+	})))
+	defer server.Close()
+
+	for _, path := range []string{"/reject", "/late"} {
+		req, err := http.NewRequest(http.MethodPost, server.URL+path, strings.NewReader("too large"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Expect", "100-continue")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+
+		entry := waitEntry(t, rec, http.MethodPost, path)
+		if entry.Response.Status != http.StatusRequestEntityTooLarge {
+			t.Errorf("%s: expected 413, got %d", path, entry.Response.Status)
+		}
+		if got := statuses(entry.Response.Informational); len(got) != 0 {
+			t.Errorf("%s: expected no informational responses, got %v", path, got)
+		}
+	}
+}
@@ -21,6 +21,35 @@ type Logger struct {
 	fileNameFn func(req *http.Request) string
 	logger     *slog.Logger
 	mu         sync.Mutex
+
+	// customFileName is set when fileNameFn is not defaultFileNameFn
+	customFileName bool
+
+	captureByDefault     bool
+	captureBodyByDefault bool
+	fileOutput           bool
+	sinks                []Sink
+	processors           []Processor
+	slogOutput           bool
+	slogOptions          []SlogOption
+	compression          Compression
+	compressionLevel     int
+	wsMessageSize        int
+	wsMaxMessages        int
+	wsExtensions         bool
+
+	eventStreamCheckpoint time.Duration
+	eventStreamEventSize  int
+	eventStreamMaxEvents  int
+	groupRedirects        bool
+
+	retention *RetentionPolicy
+	// written holds the absolute paths of files written while retention is
+	// enabled
+	written   map[string]bool
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
 }
 
 // Option represents a configuration option for Logger
@@ -33,10 +62,12 @@ func WithOutputDir(dir string) Option {
 	}
 }
 
-// WithFileNameFn sets the custom filename generator function
+// WithFileNameFn sets the custom filename generator function. The GraphQL
+// operation of the request is available from GraphQLFrom(req.Context()).
 func WithFileNameFn(fn func(req *http.Request) string) Option {
 	return func(l *Logger) {
 		l.fileNameFn = fn
+		l.customFileName = true
 	}
 }
 
@@ -61,6 +92,64 @@ func WithLogger(logger *slog.Logger) Option {
 	}
 }
 
+// WithDefaultCapture sets whether requests are logged unless marked otherwise
+// with Skip or Capture (default: true)
+func WithDefaultCapture(enabled bool) Option {
+	return func(l *Logger) {
+		l.captureByDefault = enabled
+	}
+}
+
+// WithDefaultBodyCapture sets whether request and response bodies are recorded
+// unless overridden with WithBodyCapture (default: true)
+func WithDefaultBodyCapture(enabled bool) Option {
+	return func(l *Logger) {
+		l.captureBodyByDefault = enabled
+	}
+}
+
+// WithFileOutput sets whether each entry is saved as a HAR file in the output
+// directory (default: true). Disable it to deliver entries only to sinks.
+func WithFileOutput(enabled bool) Option {
+	return func(l *Logger) {
+		l.fileOutput = enabled
+	}
+}
+
+// WithCompression sets the compression of HAR files (default:
+// CompressionNone). The extension of the algorithm is appended to file names
+// unless they already end with ".gz" or ".zst", which select the compression
+// regardless of this option.
+func WithCompression(c Compression) Option {
+	return func(l *Logger) {
+		l.compression = c
+	}
+}
+
+// WithCompressionLevel sets the compression level, from gzip.BestSpeed (1)
+// to gzip.BestCompression (9) for gzip and from 1 to 22 for Zstandard
+// (default: 0, the default level of the algorithm)
+func WithCompressionLevel(level int) Option {
+	return func(l *Logger) {
+		l.compressionLevel = level
+	}
+}
+
+// WithSink adds a sink that receives every recorded HAR entry
+func WithSink(sink Sink) Option {
+	return func(l *Logger) {
+		l.sinks = append(l.sinks, sink)
+	}
+}
+
+// WithProcessor adds a processor that modifies every entry before it is
+// saved and passed to sinks. Processors run in the order they are added.
+func WithProcessor(p Processor) Option {
+	return func(l *Logger) {
+		l.processors = append(l.processors, p)
+	}
+}
+
 // defaultFileNameFn generates a unique filename for the HAR file
 func (l *Logger) defaultFileNameFn(req *http.Request) string {
 	now := time.Now().UTC()
@@ -72,6 +161,16 @@ func (l *Logger) defaultFileNameFn(req *http.Request) string {
 		host = "unknown"
 	}
 
+	// GraphQL requests share a path, so the operation is added to it
+	path := req.URL.Path
+	if op := GraphQLFrom(req.Context()); op != nil {
+		name := op.OperationName
+		if name == "" {
+			name = op.OperationType
+		}
+		path += "-" + name
+	}
+
 	// Format: {timestamp}-{uuid}-{method}-{host}-{path}.har
 	// Example: 20240315-123456.789-a1b2c3d4-GET-example.com-api-users.har
 	return filepath.Join(l.outputDir,
@@ -80,7 +179,7 @@ func (l *Logger) defaultFileNameFn(req *http.Request) string {
 			uuid.New().String()[:8],
 			req.Method,
 			sanitizeFilename(host),
-			sanitizeFilename(req.URL.Path),
+			sanitizeFilename(path),
 		),
 	)
 }
@@ -118,6 +217,16 @@ func New(opts ...Option) *Logger {
 		transport: http.DefaultTransport,
 		outputDir: ".",
 		logger:    slog.New(slog.NewTextHandler(os.Stderr, nil)),
+
+		captureByDefault:     true,
+		captureBodyByDefault: true,
+		fileOutput:           true,
+		wsMessageSize:        defaultWebSocketMessageSize,
+		wsMaxMessages:        defaultWebSocketMessages,
+
+		eventStreamEventSize: defaultEventStreamEventSize,
+		eventStreamMaxEvents: defaultEventStreamEvents,
+		done:                 make(chan struct{}),
 	}
 	l.fileNameFn = l.defaultFileNameFn
 
@@ -125,6 +234,13 @@ func New(opts ...Option) *Logger {
 	for _, opt := range opts {
 		opt(l)
 	}
+	if l.slogOutput {
+		l.sinks = append(l.sinks, NewSlogSink(l.logger, l.slogOptions...))
+	}
+	if l.retention != nil {
+		l.written = make(map[string]bool)
+		l.startRetention()
+	}
 
 	return l
 }
@@ -140,3 +256,13 @@ func (l *Logger) WrapTransport(transport http.RoundTripper) *Logger {
 	l.transport = transport
 	return l
 }
+
+// Close stops background work of the Logger such as the retention policy and
+// waits for it to finish. The Logger keeps recording after Close.
+func (l *Logger) Close() error {
+	l.closeOnce.Do(func() {
+		close(l.done)
+	})
+	l.wg.Wait()
+	return nil
+}
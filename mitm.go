@@ -0,0 +1,148 @@
+package harlog
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// WithInterception makes Proxy terminate TLS of CONNECT tunnels with
+// certificates issued by ca and record the decrypted requests. Clients must
+// trust the CA certificate. Use WithInterceptHosts and
+// WithoutInterceptHosts to limit interception; other tunnels are relayed as
+// they are.
+func WithInterception(ca *CA) ProxyOption {
+	return func(p *Proxy) {
+		p.ca = ca
+	}
+}
+
+// WithInterceptHosts limits interception to hosts matching one of patterns,
+// in path.Match syntax such as "*.example.com" (default: all hosts)
+func WithInterceptHosts(patterns ...string) ProxyOption {
+	return func(p *Proxy) {
+		p.interceptHosts = append(p.interceptHosts, patterns...)
+	}
+}
+
+// WithoutInterceptHosts excludes hosts matching one of patterns from
+// interception, e.g. for services that pin certificates. It takes
+// precedence over WithInterceptHosts.
+func WithoutInterceptHosts(patterns ...string) ProxyOption {
+	return func(p *Proxy) {
+		p.skipHosts = append(p.skipHosts, patterns...)
+	}
+}
+
+// shouldIntercept reports whether the tunnel to target (host:port) is
+// intercepted
+func (p *Proxy) shouldIntercept(target string) bool {
+	if p.ca == nil {
+		return false
+	}
+	host := target
+	if h, _, err := net.SplitHostPort(target); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+
+	if matchHost(p.skipHosts, host) {
+		return false
+	}
+	return len(p.interceptHosts) == 0 || matchHost(p.interceptHosts, host)
+}
+
+func matchHost(patterns []string, host string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(strings.ToLower(pattern), host); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// serveIntercepted terminates TLS of a CONNECT tunnel and forwards the
+// HTTP/1.1 requests sent through it
+func (p *Proxy) serveIntercepted(w http.ResponseWriter, r *http.Request) {
+	target := r.Host
+	host := target
+	if h, _, err := net.SplitHostPort(target); err == nil {
+		host = h
+	}
+
+	client, err := p.hijack(w, target)
+	if err != nil {
+		return
+	}
+	defer client.Close()
+	p.track(client, true)
+	defer p.track(client, false)
+
+	conn := tls.Server(client, &tls.Config{
+		// Certificates are only issued for the host of the CONNECT request,
+		// which interception was decided for
+		GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			if hello.ServerName != "" && !strings.EqualFold(strings.TrimSuffix(hello.ServerName, "."), strings.TrimSuffix(host, ".")) {
+				return nil, fmt.Errorf("server name %q does not match the tunnel to %q", hello.ServerName, host)
+			}
+			return p.ca.issue(host)
+		},
+		NextProtos: []string{"http/1.1"},
+	})
+	if err := conn.HandshakeContext(r.Context()); err != nil {
+		p.logger.logger.Error("failed TLS handshake with proxy client", "error", err, "target", target)
+		return
+	}
+
+	// Omit the default port from recorded URLs
+	urlHost := strings.TrimSuffix(target, ":443")
+	br := bufio.NewReader(conn)
+	for {
+		req, err := http.ReadRequest(br)
+		if err != nil {
+			return
+		}
+		req.URL.Scheme = "https"
+		req.URL.Host = urlHost
+		req.RemoteAddr = r.RemoteAddr
+
+		resp, err := p.forward(req)
+		if err != nil {
+			resp = &http.Response{
+				StatusCode: http.StatusBadGateway,
+				Header:     http.Header{"Content-Type": {"text/plain; charset=utf-8"}},
+				Body:       http.NoBody,
+				Request:    req,
+			}
+		}
+
+		// The response may have been received over HTTP/2, but is sent to the
+		// client over HTTP/1.1
+		removeHopByHopHeaders(resp.Header)
+		resp.Proto, resp.ProtoMajor, resp.ProtoMinor = "HTTP/1.1", 1, 1
+		resp.Close = req.Close || err != nil
+		if resp.ContentLength < 0 && bodyAllowed(req.Method, resp.StatusCode) {
+			resp.TransferEncoding = []string{"chunked"}
+		} else {
+			resp.TransferEncoding = nil
+		}
+
+		writeErr := resp.Write(conn)
+		resp.Body.Close()
+		if writeErr != nil || resp.Close {
+			return
+		}
+	}
+}
+
+// bodyAllowed reports whether a response to method with status has a body
+func bodyAllowed(method string, status int) bool {
+	if method == http.MethodHead {
+		return false
+	}
+	return status >= 200 && status != http.StatusNoContent && status != http.StatusNotModified
+}
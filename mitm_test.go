@@ -0,0 +1,259 @@
+package harlog
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProxy_Interception(t *testing.T) {
+	t.Parallel()
+
+	backend := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprintf(w, "%s %s %s", r.Method, r.URL.RequestURI(), body)
+	}))
+	defer backend.Close()
+
+	ca, err := NewCA()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := NewRecorder()
+	proxyURL := startProxy(t, NewProxy(New(WithFileOutput(false), WithSink(rec)),
+		WithInterception(ca),
+		// The proxy trusts the test server
+		WithProxyTransport(backend.Client().Transport.(*http.Transport).Clone()),
+	))
+
+	var issuers []string
+	client := &http.Client{Transport: &http.Transport{
+		Proxy: http.ProxyURL(proxyURL),
+		TLSClientConfig: &tls.Config{
+			RootCAs: ca.CertPool(),
+			VerifyConnection: func(cs tls.ConnectionState) error {
+				issuers = append(issuers, cs.PeerCertificates[0].Issuer.CommonName)
+				return nil
+			},
+		},
+	}}
+
+	resp, err := client.Get(backend.URL + "/users?id=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "GET /users?id=1 " {
+		t.Errorf("unexpected response: %q", body)
+	}
+
+	resp, err = client.Post(backend.URL+"/users", "application/json", strings.NewReader(`{"name": "alice"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != `POST /users {"name": "alice"}` {
+		t.Errorf("unexpected response: %q", body)
+	}
+
+	if len(issuers) != 1 || issuers[0] != "harlog CA" {
+		t.Errorf("expected one connection with a certificate issued by harlog CA, got %v", issuers)
+	}
+
+	get := waitEntry(t, rec, http.MethodGet, "/users?id=1")
+	if get.Request.URL != backend.URL+"/users?id=1" || get.Response.Content.Text != "GET /users?id=1 " {
+		t.Errorf("unexpected entry: %+v", get)
+	}
+	post := waitEntry(t, rec, http.MethodPost, "/users")
+	if post.Request.PostData == nil || post.Request.PostData.Text != `{"name": "alice"}` {
+		t.Errorf("unexpected request: %+v", post.Request)
+	}
+
+	client.CloseIdleConnections()
+	time.Sleep(50 * time.Millisecond)
+	for _, entry := range rec.Entries() {
+		if entry.Tunnel != nil {
+			t.Errorf("intercepted tunnel must not be recorded as a tunnel: %+v", entry)
+		}
+	}
+}
+
+func TestProxy_InterceptionSkipped(t *testing.T) {
+	t.Parallel()
+
+	backend := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer backend.Close()
+
+	ca, err := NewCA()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := NewRecorder()
+	proxyURL := startProxy(t, NewProxy(New(WithFileOutput(false), WithSink(rec)),
+		WithInterception(ca),
+		WithoutInterceptHosts("127.0.0.*"),
+	))
+
+	// The client sees the certificate of the server itself
+	client := proxyClient(proxyURL, backend.Client().Transport)
+	resp, err := client.Get(backend.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	client.CloseIdleConnections()
+
+	entry := waitEntry(t, rec, http.MethodConnect, strings.TrimPrefix(backend.URL, "https://"))
+	if entry.Tunnel == nil {
+		t.Errorf("expected tunnel entry: %+v", entry)
+	}
+}
+
+func TestProxy_InterceptionServerName(t *testing.T) {
+	t.Parallel()
+
+	ca, err := NewCA()
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxyURL := startProxy(t, NewProxy(New(WithFileOutput(false)), WithInterception(ca)))
+
+	handshake := func(serverName string) (*tls.Conn, error) {
+		conn, err := net.Dial("tcp", proxyURL.Host)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		fmt.Fprint(conn, "CONNECT api.example.test:443 HTTP/1.1\r\nHost: api.example.test:443\r\n\r\n")
+		resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("unexpected status: %s", resp.Status)
+		}
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: serverName, RootCAs: ca.CertPool()})
+		return tlsConn, tlsConn.Handshake()
+	}
+
+	conn, err := handshake("API.example.test")
+	if err != nil {
+		t.Fatalf("expected handshake for the tunnel host, got %v", err)
+	}
+	if names := conn.ConnectionState().PeerCertificates[0].DNSNames; len(names) != 1 || names[0] != "api.example.test" {
+		t.Errorf("expected certificate for the tunnel host, got %v", names)
+	}
+
+	// A certificate for another name is not issued through the tunnel
+	if _, err := handshake("bank.example.test"); err == nil {
+		t.Error("expected handshake with a mismatched server name to fail")
+	}
+}
+
+func TestShouldIntercept(t *testing.T) {
+	ca := &CA{}
+	testCases := []struct {
+		opts   []ProxyOption
+		target string
+		want   bool
+	}{
+		{opts: nil, target: "example.com:443", want: false},
+		{opts: []ProxyOption{WithInterception(ca)}, target: "example.com:443", want: true},
+		{opts: []ProxyOption{WithInterception(ca), WithInterceptHosts("*.example.com")}, target: "api.example.com:443", want: true},
+		{opts: []ProxyOption{WithInterception(ca), WithInterceptHosts("*.example.com")}, target: "example.org:443", want: false},
+		{opts: []ProxyOption{WithInterception(ca), WithInterceptHosts("*.example.com"), WithoutInterceptHosts("pinned.example.com")}, target: "PINNED.example.com:443", want: false},
+		{opts: []ProxyOption{WithInterception(ca), WithoutInterceptHosts("*.internal")}, target: "db.internal:8443", want: false},
+	}
+
+	for _, tc := range testCases {
+		p := NewProxy(New(WithFileOutput(false)), tc.opts...)
+		if got := p.shouldIntercept(tc.target); got != tc.want {
+			t.Errorf("shouldIntercept(%s) with %d options: expected %v, got %v", tc.target, len(tc.opts), tc.want, got)
+		}
+	}
+}
+
+func TestCA(t *testing.T) {
+	t.Parallel()
+
+	ca, err := NewCA()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "ca.pem"), filepath.Join(dir, "ca-key.pem")
+	if err := ca.WriteFiles(certFile, keyFile); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadCAFiles(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.Certificate().Equal(ca.Certificate()) {
+		t.Error("loaded CA certificate differs")
+	}
+
+	for _, host := range []string{"example.com", "127.0.0.1"} {
+		cert, err := loaded.issue(host)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = cert.Leaf.Verify(x509.VerifyOptions{DNSName: host, Roots: ca.CertPool()})
+		if err != nil {
+			t.Errorf("%s: certificate does not verify: %v", host, err)
+		}
+		if cached, _ := loaded.issue(host); cached != cert {
+			t.Errorf("%s: certificate was not cached", host)
+		}
+	}
+
+	// Certificates are only issued for valid host names
+	for _, host := range []string{"", ".", "bad host", "a..b", "-a.com", strings.Repeat("a", 64) + ".com"} {
+		if _, err := loaded.issue(host); err == nil {
+			t.Errorf("%q: expected error", host)
+		}
+	}
+
+	// The least recently used certificates are evicted
+	loaded.cacheSize = 2
+	a, _ := loaded.issue("a.example.com")
+	_, _ = loaded.issue("b.example.com")
+	_, _ = loaded.issue("A.example.com.")
+	_, _ = loaded.issue("c.example.com")
+	if len(loaded.cache) != 2 || loaded.lru.Len() != 2 {
+		t.Errorf("expected 2 cached certificates, got %d", len(loaded.cache))
+	}
+	if cached, _ := loaded.issue("a.example.com"); cached != a {
+		t.Error("expected recently used certificate to be kept")
+	}
+	if _, ok := loaded.cache["b.example.com"]; ok {
+		t.Error("expected least recently used certificate to be evicted")
+	}
+
+	other, err := NewCA()
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := other.KeyPEM()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadCA(ca.CertificatePEM(), otherKey); err == nil {
+		t.Error("expected error for mismatched key")
+	}
+}
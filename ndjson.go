@@ -0,0 +1,117 @@
+package harlog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// NDJSONSink is a Sink that writes each entry as one JSON object per line
+// (NDJSON, also known as JSON Lines). Such a stream can be tailed, grepped
+// or shipped by log collectors, and read back with ReadNDJSON or
+// ReadHARData.
+type NDJSONSink struct {
+	mu   sync.Mutex
+	w    io.Writer
+	path string
+}
+
+// NewNDJSONSink creates an NDJSONSink writing to w
+func NewNDJSONSink(w io.Writer) *NDJSONSink {
+	return &NDJSONSink{w: w}
+}
+
+// NewNDJSONFileSink creates an NDJSONSink appending to the file at path. The
+// file and its directory are created if they do not exist. The file is
+// opened for each entry, so it can be rotated by external tools.
+func NewNDJSONFileSink(path string) *NDJSONSink {
+	return &NDJSONSink{path: path}
+}
+
+// WriteEntry implements Sink
+func (s *NDJSONSink) WriteEntry(req *http.Request, entry *HAREntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode HAR entry: %w", err)
+	}
+	// Write the line in one call so that lines of concurrent writers to the
+	// same file are not interleaved
+	data = append(data, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.path == "" {
+		if _, err := s.w.Write(data); err != nil {
+			return fmt.Errorf("failed to write HAR entry: %w", err)
+		}
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0750); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	file, err := os.OpenFile(s.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write HAR entry: %w", err)
+	}
+	return file.Close()
+}
+
+// ReadNDJSON reads a stream of HAR entries, one JSON object per line, into a
+// HAR log. Blank lines are ignored.
+func ReadNDJSON(r io.Reader) (*HAR, error) {
+	har := &HAR{
+		Log: HARLog{
+			Version: "1.2",
+			Creator: HARCreator{
+				Name:    "harlog",
+				Version: "1.0",
+			},
+			Entries: []HAREntry{},
+		},
+	}
+
+	br := bufio.NewReader(r)
+	for n := 1; ; n++ {
+		line, err := br.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("failed to read NDJSON: %w", err)
+		}
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			var entry HAREntry
+			if err := json.Unmarshal(line, &entry); err != nil {
+				return nil, fmt.Errorf("failed to parse NDJSON line %d: %w", n, err)
+			}
+			har.Log.Entries = append(har.Log.Entries, entry)
+		}
+		if errors.Is(err, io.EOF) {
+			return har, nil
+		}
+	}
+}
+
+// isNDJSON reports whether the first line of data is a HAR entry rather than
+// the start of a HAR document
+func isNDJSON(data []byte) bool {
+	line, _, _ := bytes.Cut(bytes.TrimSpace(data), []byte("\n"))
+	var first struct {
+		Log     json.RawMessage `json:"log"`
+		Request json.RawMessage `json:"request"`
+	}
+	if err := json.Unmarshal(line, &first); err != nil {
+		return false
+	}
+	return first.Log == nil && first.Request != nil
+}
@@ -0,0 +1,121 @@
+package harlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestNDJSONFileSink(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "logs", "traffic.ndjson")
+	logger := New(
+		WithOutputDir(tmpDir),
+		WithFileOutput(false),
+		WithSink(NewNDJSONFileSink(path)),
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.URL.Path))
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: logger}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			resp, err := client.Get(fmt.Sprintf("%s/users/%d", server.URL, i))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}(i)
+	}
+	wg.Wait()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 10 {
+		t.Fatalf("expected 10 lines, got %d", len(lines))
+	}
+	for _, line := range lines {
+		if !json.Valid([]byte(line)) {
+			t.Errorf("invalid JSON line: %s", line)
+		}
+	}
+
+	har, err := ReadHARFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(har.Log.Entries) != 10 || har.Log.Version != "1.2" {
+		t.Errorf("unexpected HAR: %d entries, version %q", len(har.Log.Entries), har.Log.Version)
+	}
+
+	messages, err := ParseHARData(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 10 || !strings.HasPrefix(messages[0].Request.URL.Path, "/users/") {
+		t.Errorf("unexpected messages: %d", len(messages))
+	}
+}
+
+func TestNDJSONSink(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewNDJSONSink(&buf)
+	for _, url := range []string{"https://example.com/a", "https://example.com/b"} {
+		entry := &HAREntry{Request: HARRequest{Method: "GET", URL: url}}
+		if err := sink.WriteEntry(httptest.NewRequest("GET", url, nil), entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Blank lines, e.g. from concatenated streams, are ignored
+	buf.WriteString("\n")
+
+	har, err := ReadNDJSON(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(har.Log.Entries) != 2 || har.Log.Entries[1].Request.URL != "https://example.com/b" {
+		t.Errorf("unexpected entries: %+v", har.Log.Entries)
+	}
+
+	_, err = ReadNDJSON(strings.NewReader("{\"request\": {}}\n{broken\n"))
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("expected error for line 2, got %v", err)
+	}
+}
+
+func TestReadHARData_Format(t *testing.T) {
+	har := HAR{Log: HARLog{Version: "1.2", Entries: []HAREntry{{Request: HARRequest{Method: "GET", URL: "https://example.com/"}}}}}
+	compact, _ := json.Marshal(har)
+	indented, _ := json.MarshalIndent(har, "", "  ")
+
+	for name, data := range map[string][]byte{"compact": compact, "indented": indented} {
+		got, err := ReadHARData(data)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(got.Log.Entries) != 1 || got.Log.Entries[0].Request.URL != "https://example.com/" {
+			t.Errorf("%s: unexpected entries: %+v", name, got.Log.Entries)
+		}
+	}
+}
@@ -0,0 +1,434 @@
+// Package openapi generates OpenAPI 3.1 documents from captured HTTP traffic.
+//
+// URLs are clustered into path templates by harlog.PathTemplate, query and
+// header parameters are inferred from the observed values, and JSON schemas
+// of request and response bodies are derived per status code. The generated
+// document is deterministic so that it can be diffed in code review.
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/m-mizutani/harlog"
+)
+
+// Version is the OpenAPI version of generated documents
+const Version = "3.1.0"
+
+// Document represents an OpenAPI document
+type Document struct {
+	OpenAPI string               `json:"openapi"`
+	Info    Info                 `json:"info"`
+	Servers []Server             `json:"servers,omitempty"`
+	Paths   map[string]*PathItem `json:"paths"`
+}
+
+// Info represents metadata of the API
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// Server represents a server hosting the API
+type Server struct {
+	URL string `json:"url"`
+}
+
+// PathItem maps lower case HTTP methods to operations on a path
+type PathItem map[string]*Operation
+
+// Operation represents a single API operation
+type Operation struct {
+	OperationID string               `json:"operationId"`
+	Parameters  []*Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+// Parameter represents a path, query or header parameter
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required,omitempty"`
+	Schema   *Schema `json:"schema"`
+}
+
+// RequestBody represents the body of requests to an operation
+type RequestBody struct {
+	Required bool                  `json:"required,omitempty"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+// Response represents a response of an operation for a status code
+type Response struct {
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+// MediaType represents the schema of a body for a content type
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Option represents a configuration option for Generate
+type Option func(*generator)
+
+// WithTitle sets the title of the API (default: "Generated API")
+func WithTitle(title string) Option {
+	return func(g *generator) {
+		g.title = title
+	}
+}
+
+// WithAPIVersion sets the version of the API (default: "0.0.0")
+func WithAPIVersion(version string) Option {
+	return func(g *generator) {
+		g.version = version
+	}
+}
+
+// WithIgnoredHeaders adds request headers that are not documented as
+// parameters, in addition to standard headers such as Accept and User-Agent
+func WithIgnoredHeaders(names ...string) Option {
+	return func(g *generator) {
+		for _, name := range names {
+			g.ignoredHeaders[http.CanonicalHeaderKey(name)] = true
+		}
+	}
+}
+
+// defaultIgnoredHeaders are request headers set by clients and proxies rather
+// than defined by the API
+var defaultIgnoredHeaders = []string{
+	"Accept", "Accept-Charset", "Accept-Encoding", "Accept-Language",
+	"Authorization", "Cache-Control", "Connection", "Content-Length",
+	"Content-Type", "Cookie", "Dnt", "Host", "If-Modified-Since",
+	"If-None-Match", "Origin", "Pragma", "Priority", "Referer", "Sec-Fetch-Dest",
+	"Sec-Fetch-Mode", "Sec-Fetch-Site", "Sec-Fetch-User", "Sec-Gpc", "Te",
+	"Upgrade-Insecure-Requests", "User-Agent", "X-Forwarded-For",
+	"X-Forwarded-Host", "X-Forwarded-Proto",
+}
+
+type generator struct {
+	title          string
+	version        string
+	ignoredHeaders map[string]bool
+}
+
+// sample is a request/response pair with bodies read into memory
+type sample struct {
+	req      *http.Request
+	resp     *http.Response
+	reqBody  []byte
+	respBody []byte
+}
+
+// operationKey identifies an operation by method and path template
+type operationKey struct {
+	method   string
+	template string
+}
+
+// GenerateFromHAR generates an OpenAPI document from the entries of a HAR log
+func GenerateFromHAR(har *harlog.HAR, opts ...Option) (*Document, error) {
+	messages, err := harlog.ConvertHAR(har)
+	if err != nil {
+		return nil, err
+	}
+	return Generate(messages, opts...)
+}
+
+// Generate generates an OpenAPI document from HTTP messages. Request and
+// response bodies are read and restored.
+func Generate(messages harlog.HTTPMessages, opts ...Option) (*Document, error) {
+	g := &generator{
+		title:          "Generated API",
+		version:        "0.0.0",
+		ignoredHeaders: make(map[string]bool),
+	}
+	for _, name := range defaultIgnoredHeaders {
+		g.ignoredHeaders[name] = true
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+
+	servers := make(map[string]bool)
+	groups := make(map[operationKey][]*sample)
+	for _, msg := range messages {
+		if msg.Request == nil || msg.Request.URL == nil {
+			continue
+		}
+
+		s, err := newSample(msg)
+		if err != nil {
+			return nil, err
+		}
+
+		u := msg.Request.URL
+		if u.Scheme != "" && u.Host != "" {
+			servers[u.Scheme+"://"+u.Host] = true
+		}
+
+		key := operationKey{
+			method:   strings.ToLower(msg.Request.Method),
+			template: harlog.PathTemplate(pathOf(msg.Request)),
+		}
+		groups[key] = append(groups[key], s)
+	}
+
+	doc := &Document{
+		OpenAPI: Version,
+		Info: Info{
+			Title:   g.title,
+			Version: g.version,
+		},
+		Paths: make(map[string]*PathItem),
+	}
+
+	for server := range servers {
+		doc.Servers = append(doc.Servers, Server{URL: server})
+	}
+	sort.Slice(doc.Servers, func(i, j int) bool {
+		return doc.Servers[i].URL < doc.Servers[j].URL
+	})
+
+	for key, samples := range groups {
+		item, ok := doc.Paths[key.template]
+		if !ok {
+			item = &PathItem{}
+			doc.Paths[key.template] = item
+		}
+		(*item)[key.method] = g.buildOperation(key, samples)
+	}
+
+	return doc, nil
+}
+
+func newSample(msg harlog.HTTPMessage) (*sample, error) {
+	s := &sample{req: msg.Request, resp: msg.Response}
+
+	var err error
+	if s.reqBody, err = readBody(&msg.Request.Body); err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+	if msg.Response != nil {
+		if s.respBody, err = readBody(&msg.Response.Body); err != nil {
+			return nil, fmt.Errorf("failed to read response body: %w", err)
+		}
+	}
+	return s, nil
+}
+
+// readBody reads the body and replaces it with a reader over the same data
+func readBody(body *io.ReadCloser) ([]byte, error) {
+	if *body == nil || *body == http.NoBody {
+		return nil, nil
+	}
+	data, err := io.ReadAll(*body)
+	if err != nil {
+		return nil, err
+	}
+	*body = io.NopCloser(bytes.NewReader(data))
+	return data, nil
+}
+
+func pathOf(req *http.Request) string {
+	if req.URL.Path == "" {
+		return "/"
+	}
+	return req.URL.Path
+}
+
+func (g *generator) buildOperation(key operationKey, samples []*sample) *Operation {
+	op := &Operation{
+		OperationID: operationID(key),
+		Responses:   make(map[string]*Response),
+	}
+
+	op.Parameters = append(op.Parameters, pathParameters(key.template, samples)...)
+	op.Parameters = append(op.Parameters, collectParameters("query", samples, func(s *sample) map[string][]string {
+		return s.req.URL.Query()
+	})...)
+	op.Parameters = append(op.Parameters, collectParameters("header", samples, func(s *sample) map[string][]string {
+		headers := make(map[string][]string)
+		for name, values := range s.req.Header {
+			name = http.CanonicalHeaderKey(name)
+			if !g.ignoredHeaders[name] && !strings.HasPrefix(name, ":") {
+				headers[name] = values
+			}
+		}
+		return headers
+	})...)
+
+	withBody := 0
+	for _, s := range samples {
+		if len(s.reqBody) == 0 {
+			continue
+		}
+		withBody++
+		if op.RequestBody == nil {
+			op.RequestBody = &RequestBody{Content: make(map[string]*MediaType)}
+		}
+		addContent(op.RequestBody.Content, s.req.Header.Get("Content-Type"), s.reqBody)
+	}
+	if op.RequestBody != nil {
+		op.RequestBody.Required = withBody == len(samples)
+	}
+
+	for _, s := range samples {
+		if s.resp == nil {
+			continue
+		}
+
+		code := strconv.Itoa(s.resp.StatusCode)
+		resp, ok := op.Responses[code]
+		if !ok {
+			resp = &Response{Description: http.StatusText(s.resp.StatusCode)}
+			if resp.Description == "" {
+				resp.Description = "Response"
+			}
+			op.Responses[code] = resp
+		}
+
+		if len(s.respBody) > 0 {
+			if resp.Content == nil {
+				resp.Content = make(map[string]*MediaType)
+			}
+			addContent(resp.Content, s.resp.Header.Get("Content-Type"), s.respBody)
+		}
+	}
+	if len(op.Responses) == 0 {
+		op.Responses["default"] = &Response{Description: "Response"}
+	}
+
+	return op
+}
+
+// pathParameters infers schemas of template parameters from the actual paths
+func pathParameters(template string, samples []*sample) []*Parameter {
+	var params []*Parameter
+	for i, segment := range strings.Split(template, "/") {
+		if !strings.HasPrefix(segment, "{") {
+			continue
+		}
+
+		param := &Parameter{
+			Name:     strings.Trim(segment, "{}"),
+			In:       "path",
+			Required: true,
+		}
+		for _, s := range samples {
+			if values := strings.Split(pathOf(s.req), "/"); i < len(values) {
+				param.Schema = mergeSchema(param.Schema, inferStringSchema(values[i]))
+			}
+		}
+		params = append(params, param)
+	}
+	return params
+}
+
+// collectParameters infers parameters located in "in" from the values
+// returned by fn. Parameters present in every sample are required.
+func collectParameters(in string, samples []*sample, fn func(s *sample) map[string][]string) []*Parameter {
+	found := make(map[string]*Parameter)
+	counts := make(map[string]int)
+
+	for _, s := range samples {
+		for name, values := range fn(s) {
+			param, ok := found[name]
+			if !ok {
+				param = &Parameter{Name: name, In: in}
+				found[name] = param
+			}
+			counts[name]++
+
+			schema := (*Schema)(nil)
+			for _, v := range values {
+				schema = mergeSchema(schema, inferStringSchema(v))
+			}
+			if len(values) > 1 {
+				schema = &Schema{Type: Types{"array"}, Items: schema}
+			}
+			param.Schema = mergeSchema(param.Schema, schema)
+		}
+	}
+
+	params := make([]*Parameter, 0, len(found))
+	for name, param := range found {
+		param.Required = counts[name] == len(samples)
+		params = append(params, param)
+	}
+	sort.Slice(params, func(i, j int) bool {
+		return params[i].Name < params[j].Name
+	})
+	return params
+}
+
+// addContent merges the schema of body into content under its media type
+func addContent(content map[string]*MediaType, contentType string, body []byte) {
+	mediaType := "application/octet-stream"
+	if contentType != "" {
+		if mt, _, err := mime.ParseMediaType(contentType); err == nil {
+			mediaType = mt
+		}
+	}
+
+	schema := bodySchema(mediaType, body)
+	if existing, ok := content[mediaType]; ok {
+		existing.Schema = mergeSchema(existing.Schema, schema)
+		return
+	}
+	content[mediaType] = &MediaType{Schema: schema}
+}
+
+func bodySchema(mediaType string, body []byte) *Schema {
+	if mediaType == "application/json" || strings.HasSuffix(mediaType, "+json") {
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		decoder.UseNumber()
+		var v any
+		if err := decoder.Decode(&v); err == nil {
+			return inferSchema(v)
+		}
+	}
+
+	if strings.HasPrefix(mediaType, "text/") || mediaType == "application/x-www-form-urlencoded" {
+		return &Schema{Type: Types{"string"}}
+	}
+	return &Schema{Type: Types{"string"}, Format: "binary"}
+}
+
+// operationID builds an identifier such as "getUsersByUserId" from the method
+// and path template
+func operationID(key operationKey) string {
+	id := key.method
+	for _, segment := range strings.Split(key.template, "/") {
+		if segment == "" {
+			continue
+		}
+		if strings.HasPrefix(segment, "{") {
+			id += "By" + camelCase(strings.Trim(segment, "{}"))
+			continue
+		}
+		id += camelCase(segment)
+	}
+	return id
+}
+
+func camelCase(s string) string {
+	var b strings.Builder
+	for _, w := range strings.FieldsFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9')
+	}) {
+		b.WriteString(strings.ToUpper(w[:1]) + w[1:])
+	}
+	return b.String()
+}
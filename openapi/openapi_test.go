@@ -0,0 +1,154 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/m-mizutani/harlog"
+)
+
+func newEntry(method, rawURL string, reqHeaders []harlog.HARHeader, reqBody string, status int, respBody string) harlog.HAREntry {
+	entry := harlog.HAREntry{
+		Request: harlog.HARRequest{
+			Method:      method,
+			URL:         rawURL,
+			HTTPVersion: "HTTP/1.1",
+			Headers:     reqHeaders,
+		},
+		Response: harlog.HARResponse{
+			Status:      status,
+			HTTPVersion: "HTTP/1.1",
+			Headers:     []harlog.HARHeader{{Name: "Content-Type", Value: "application/json; charset=utf-8"}},
+			Content:     harlog.HARContent{Text: respBody},
+		},
+	}
+	if reqBody != "" {
+		entry.Request.Headers = append(entry.Request.Headers, harlog.HARHeader{Name: "Content-Type", Value: "application/json"})
+		entry.Request.PostData = &harlog.HARPostData{MimeType: "application/json", Text: reqBody}
+	}
+	return entry
+}
+
+func testHAR() *harlog.HAR {
+	tenant := []harlog.HARHeader{{Name: "X-Tenant-Id", Value: "42"}, {Name: "User-Agent", Value: "test"}}
+	return &harlog.HAR{
+		Log: harlog.HARLog{
+			Entries: []harlog.HAREntry{
+				newEntry("GET", "https://api.example.com/users/1?verbose=true", tenant, "", 200,
+					`{"id": 1, "name": "alice", "email": "alice@example.com"}`),
+				newEntry("GET", "https://api.example.com/users/2", tenant, "", 200,
+					`{"id": 2, "name": "bob", "score": 1.5}`),
+				newEntry("POST", "https://api.example.com/users", nil, `{"name": "carol", "tags": ["a", "b"]}`, 201,
+					`{"id": 3, "created": "2025-03-09T11:49:14Z"}`),
+				newEntry("GET", "https://api.example.com/orders/0b5a5d5e-2d0f-4b6e-9f0c-1c2d3e4f5a6b", nil, "", 404,
+					`{"error": "not found"}`),
+			},
+		},
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	doc, err := GenerateFromHAR(testHAR(), WithTitle("Test API"), WithAPIVersion("1.0.0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if doc.OpenAPI != "3.1.0" || doc.Info.Title != "Test API" || doc.Info.Version != "1.0.0" {
+		t.Errorf("unexpected header: %+v %+v", doc.OpenAPI, doc.Info)
+	}
+	if len(doc.Servers) != 1 || doc.Servers[0].URL != "https://api.example.com" {
+		t.Errorf("unexpected servers: %+v", doc.Servers)
+	}
+
+	for _, path := range []string{"/users", "/users/{userId}", "/orders/{orderId}"} {
+		if doc.Paths[path] == nil {
+			t.Errorf("path %s not found in %v", path, doc.Paths)
+		}
+	}
+
+	get := (*doc.Paths["/users/{userId}"])["get"]
+	if get == nil {
+		t.Fatal("GET /users/{userId} not found")
+	}
+	if get.OperationID != "getUsersByUserId" {
+		t.Errorf("unexpected operation ID: %s", get.OperationID)
+	}
+
+	params := make(map[string]*Parameter)
+	for _, p := range get.Parameters {
+		params[p.In+":"+p.Name] = p
+	}
+	if p := params["path:userId"]; p == nil || !p.Required || p.Schema.Type[0] != "integer" {
+		t.Errorf("unexpected path parameter: %+v", p)
+	}
+	if p := params["query:verbose"]; p == nil || p.Required || p.Schema.Type[0] != "boolean" {
+		t.Errorf("unexpected query parameter: %+v", p)
+	}
+	if p := params["header:X-Tenant-Id"]; p == nil || !p.Required {
+		t.Errorf("unexpected header parameter: %+v", p)
+	}
+	if p := params["header:User-Agent"]; p != nil {
+		t.Errorf("standard header should be ignored: %+v", p)
+	}
+
+	schema := get.Responses["200"].Content["application/json"].Schema
+	if len(schema.Required) != 2 || schema.Required[0] != "id" || schema.Required[1] != "name" {
+		t.Errorf("unexpected required properties: %v", schema.Required)
+	}
+	if schema.Properties["score"].Type[0] != "number" || schema.Properties["email"] == nil {
+		t.Errorf("unexpected properties: %+v", schema.Properties)
+	}
+
+	post := (*doc.Paths["/users"])["post"]
+	if post.RequestBody == nil || !post.RequestBody.Required {
+		t.Fatalf("unexpected request body: %+v", post.RequestBody)
+	}
+	reqSchema := post.RequestBody.Content["application/json"].Schema
+	if reqSchema.Properties["tags"].Items.Type[0] != "string" {
+		t.Errorf("unexpected array items: %+v", reqSchema.Properties["tags"])
+	}
+	if f := post.Responses["201"].Content["application/json"].Schema.Properties["created"].Format; f != "date-time" {
+		t.Errorf("unexpected format: %s", f)
+	}
+
+	order := (*doc.Paths["/orders/{orderId}"])["get"]
+	if order.Parameters[0].Schema.Format != "uuid" {
+		t.Errorf("unexpected path parameter schema: %+v", order.Parameters[0].Schema)
+	}
+	if order.Responses["404"] == nil || order.Responses["404"].Description != "Not Found" {
+		t.Errorf("unexpected responses: %+v", order.Responses)
+	}
+}
+
+func TestGenerate_Deterministic(t *testing.T) {
+	har := testHAR()
+	doc, err := GenerateFromHAR(har)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Reverse the order of entries
+	entries := har.Log.Entries
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+
+	for i := 0; i < 10; i++ {
+		doc, err := GenerateFromHAR(har)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := json.MarshalIndent(doc, "", "  ")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(want, got) {
+			t.Fatalf("output is not deterministic\nwant: %s\ngot: %s", want, got)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package openapi
+
+import (
+	"encoding/json"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Types is a set of JSON schema types. A single type is encoded as a string
+// and multiple types as an array, as allowed by OpenAPI 3.1.
+type Types []string
+
+// MarshalJSON implements json.Marshaler
+func (t Types) MarshalJSON() ([]byte, error) {
+	if len(t) == 1 {
+		return json.Marshal(t[0])
+	}
+	return json.Marshal([]string(t))
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (t *Types) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*t = Types{single}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(t))
+}
+
+// Schema represents a JSON schema inferred from sample values
+type Schema struct {
+	Type       Types              `json:"type,omitempty"`
+	Format     string             `json:"format,omitempty"`
+	Properties map[string]*Schema `json:"properties,omitempty"`
+	Required   []string           `json:"required,omitempty"`
+	Items      *Schema            `json:"items,omitempty"`
+}
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// inferSchema returns the schema of a value decoded from JSON
+func inferSchema(v any) *Schema {
+	switch v := v.(type) {
+	case nil:
+		return &Schema{Type: Types{"null"}}
+
+	case bool:
+		return &Schema{Type: Types{"boolean"}}
+
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return &Schema{Type: Types{"integer"}}
+		}
+		if f, err := v.Float64(); err == nil && f == math.Trunc(f) && !math.IsInf(f, 0) {
+			return &Schema{Type: Types{"integer"}}
+		}
+		return &Schema{Type: Types{"number"}}
+
+	case string:
+		return &Schema{Type: Types{"string"}, Format: stringFormat(v)}
+
+	case []any:
+		s := &Schema{Type: Types{"array"}}
+		for _, item := range v {
+			s.Items = mergeSchema(s.Items, inferSchema(item))
+		}
+		return s
+
+	case map[string]any:
+		s := &Schema{
+			Type:       Types{"object"},
+			Properties: make(map[string]*Schema, len(v)),
+		}
+		for key, value := range v {
+			s.Properties[key] = inferSchema(value)
+			s.Required = append(s.Required, key)
+		}
+		sort.Strings(s.Required)
+		return s
+
+	default:
+		return &Schema{}
+	}
+}
+
+// inferStringSchema returns the schema of a parameter value, which is always
+// a string on the wire
+func inferStringSchema(v string) *Schema {
+	if _, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return &Schema{Type: Types{"integer"}}
+	}
+	if _, err := strconv.ParseFloat(v, 64); err == nil {
+		return &Schema{Type: Types{"number"}}
+	}
+	if v == "true" || v == "false" {
+		return &Schema{Type: Types{"boolean"}}
+	}
+	return &Schema{Type: Types{"string"}, Format: stringFormat(v)}
+}
+
+func stringFormat(v string) string {
+	if uuidPattern.MatchString(v) {
+		return "uuid"
+	}
+	if _, err := time.Parse(time.RFC3339Nano, v); err == nil {
+		return "date-time"
+	}
+	return ""
+}
+
+// mergeSchema combines two schemas inferred from different samples. Properties
+// are required only if present in both, and differing types are unioned.
+func mergeSchema(a, b *Schema) *Schema {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+
+	merged := &Schema{Type: mergeTypes(a.Type, b.Type)}
+	if a.Format == b.Format {
+		merged.Format = a.Format
+	}
+
+	if a.Properties != nil || b.Properties != nil {
+		merged.Properties = make(map[string]*Schema)
+		for key, s := range a.Properties {
+			merged.Properties[key] = s
+		}
+		for key, s := range b.Properties {
+			merged.Properties[key] = mergeSchema(merged.Properties[key], s)
+		}
+		switch {
+		case a.Properties == nil:
+			merged.Required = b.Required
+		case b.Properties == nil:
+			merged.Required = a.Required
+		default:
+			merged.Required = intersect(a.Required, b.Required)
+		}
+	}
+
+	if a.Items != nil || b.Items != nil {
+		merged.Items = mergeSchema(a.Items, b.Items)
+	}
+
+	return merged
+}
+
+func mergeTypes(a, b Types) Types {
+	set := make(map[string]bool)
+	for _, t := range append(append(Types{}, a...), b...) {
+		set[t] = true
+	}
+	// An integer is also a number
+	if set["integer"] && set["number"] {
+		delete(set, "integer")
+	}
+
+	types := make(Types, 0, len(set))
+	for t := range set {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+func intersect(a, b []string) []string {
+	set := make(map[string]bool, len(a))
+	for _, v := range a {
+		set[v] = true
+	}
+
+	var result []string
+	for _, v := range b {
+		if set[v] {
+			result = append(result, v)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
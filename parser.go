@@ -14,6 +14,25 @@ import (
 
 // ParseHARFile reads a HAR file and converts it to HTTP messages
 func ParseHARFile(filename string) (HTTPMessages, error) {
+	har, err := ReadHARFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return ConvertHAR(har)
+}
+
+// ParseHARData parses HAR data from bytes and converts it to HTTP messages.
+// NDJSON streams of entries are accepted as well.
+func ParseHARData(data []byte) (HTTPMessages, error) {
+	har, err := ReadHARData(data)
+	if err != nil {
+		return nil, err
+	}
+	return ConvertHAR(har)
+}
+
+// ReadHARFile reads a HAR file without converting its entries
+func ReadHARFile(filename string) (*HAR, error) {
 	// Validate and clean the file path
 	cleanPath := filepath.Clean(filename)
 	if !filepath.IsAbs(cleanPath) {
@@ -24,37 +43,70 @@ func ParseHARFile(filename string) (HTTPMessages, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to read HAR file: %w", err)
 	}
-	return ParseHARData(data)
+	return ReadHARData(data)
 }
 
-// ParseHARData parses HAR data from bytes and converts it to HTTP messages
-func ParseHARData(data []byte) (HTTPMessages, error) {
+// ReadHARData parses HAR data from bytes without converting its entries.
+// NDJSON streams of entries, as written by NDJSONSink, are read with
+// ReadNDJSON. gzip and Zstandard compressed data is decompressed first.
+func ReadHARData(data []byte) (*HAR, error) {
+	data, err := decompress(data)
+	if err != nil {
+		return nil, err
+	}
+	if isNDJSON(data) {
+		return ReadNDJSON(bytes.NewReader(data))
+	}
+
 	var har HAR
 	if err := json.Unmarshal(data, &har); err != nil {
 		return nil, fmt.Errorf("failed to parse HAR data: %w", err)
 	}
+	return &har, nil
+}
 
+// ConvertHAR converts the entries of a HAR log to HTTP messages
+func ConvertHAR(har *HAR) (HTTPMessages, error) {
 	messages := make(HTTPMessages, 0, len(har.Log.Entries))
 	for i := range har.Log.Entries {
-		// Create a copy of the entry to avoid memory aliasing
-		entry := har.Log.Entries[i]
-		req, err := convertHARRequestToHTTP(&entry.Request)
+		msg, err := ConvertEntry(&har.Log.Entries[i])
 		if err != nil {
-			return nil, fmt.Errorf("failed to convert HAR request: %w", err)
+			return nil, err
 		}
+		messages = append(messages, msg)
+	}
 
-		resp, err := convertHARResponseToHTTP(&entry.Response)
-		if err != nil {
-			return nil, fmt.Errorf("failed to convert HAR response: %w", err)
-		}
+	return messages, nil
+}
+
+// ConvertEntry converts a single HAR entry to an HTTP message
+func ConvertEntry(entry *HAREntry) (HTTPMessage, error) {
+	req, err := convertHARRequestToHTTP(&entry.Request)
+	if err != nil {
+		return HTTPMessage{}, fmt.Errorf("failed to convert HAR request: %w", err)
+	}
 
-		messages = append(messages, HTTPMessage{
-			Request:  req,
-			Response: resp,
-		})
+	resp, err := convertHARResponseToHTTP(&entry.Response)
+	if err != nil {
+		return HTTPMessage{}, fmt.Errorf("failed to convert HAR response: %w", err)
 	}
 
-	return messages, nil
+	messages, err := decodeWebSocketMessages(entry.WebSocketMessages)
+	if err != nil {
+		return HTTPMessage{}, err
+	}
+
+	if entry.TLS != nil {
+		req.TLS = entry.TLS.ConnectionState()
+		resp.TLS = entry.TLS.ConnectionState()
+	}
+
+	return HTTPMessage{
+		Request:           req,
+		Response:          resp,
+		WebSocketMessages: messages,
+		TLS:               entry.TLS,
+	}, nil
 }
 
 func convertHARRequestToHTTP(harReq *HARRequest) (*http.Request, error) {
@@ -144,6 +196,14 @@ func convertHARResponseToHTTP(harResp *HARResponse) (*http.Response, error) {
 		resp.Header.Add(header.Name, header.Value)
 	}
 
+	// Set trailers
+	if len(harResp.Trailers) > 0 {
+		resp.Trailer = make(http.Header)
+		for _, trailer := range harResp.Trailers {
+			resp.Trailer.Add(trailer.Name, trailer.Value)
+		}
+	}
+
 	// Set body
 	resp.Body = io.NopCloser(bytes.NewReader([]byte(harResp.Content.Text)))
 	resp.ContentLength = int64(len(harResp.Content.Text))
@@ -0,0 +1,452 @@
+package harlog
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProxyOption represents a configuration option for Proxy
+type ProxyOption func(*Proxy)
+
+// WithUpstreamProxy chains requests and CONNECT tunnels through the HTTP or
+// HTTPS proxy at proxyURL. Credentials in proxyURL are sent as
+// Proxy-Authorization.
+func WithUpstreamProxy(proxyURL *url.URL) ProxyOption {
+	return func(p *Proxy) {
+		p.upstream = proxyURL
+	}
+}
+
+// WithProxyTransport sets the transport that forwards plain HTTP requests
+// (default: a clone of http.DefaultTransport that does not use proxies from
+// the environment). Its Proxy field is overwritten by WithUpstreamProxy.
+func WithProxyTransport(transport *http.Transport) ProxyOption {
+	return func(p *Proxy) {
+		p.transport = transport
+	}
+}
+
+// Proxy is an HTTP forward proxy that records the traffic passing through it
+// with a Logger. Plain HTTP requests are forwarded through the Logger acting
+// as RoundTripper, so they are recorded like client requests. CONNECT
+// tunnels are relayed without decryption and recorded as entries with a
+// Tunnel, unless they are intercepted (see WithInterception).
+type Proxy struct {
+	logger    *Logger
+	transport *http.Transport
+	upstream  *url.URL
+
+	ca             *CA
+	interceptHosts []string
+	skipHosts      []string
+
+	mu      sync.Mutex
+	server  *http.Server
+	tunnels map[net.Conn]struct{}
+	// wg counts the open tunnels. It is only added to under mu while the
+	// proxy is not closed, so that Shutdown can wait for it.
+	wg     sync.WaitGroup
+	closed bool
+}
+
+// NewProxy creates a Proxy recording with logger
+func NewProxy(logger *Logger, opts ...ProxyOption) *Proxy {
+	p := &Proxy{
+		logger:  logger,
+		tunnels: make(map[net.Conn]struct{}),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	if p.transport == nil {
+		p.transport = http.DefaultTransport.(*http.Transport).Clone()
+		p.transport.Proxy = nil
+	}
+	if p.upstream != nil {
+		p.transport.Proxy = http.ProxyURL(p.upstream)
+	}
+	return p
+}
+
+// ListenAndServe listens on the TCP address addr and serves proxy requests.
+// It returns http.ErrServerClosed after Shutdown.
+func (p *Proxy) ListenAndServe(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return p.Serve(ln)
+}
+
+// Serve serves proxy requests on ln. It returns http.ErrServerClosed after
+// Shutdown.
+func (p *Proxy) Serve(ln net.Listener) error {
+	p.mu.Lock()
+	if p.server == nil {
+		p.server = &http.Server{
+			Handler:           p,
+			ReadHeaderTimeout: 30 * time.Second,
+		}
+	}
+	server := p.server
+	p.mu.Unlock()
+
+	return server.Serve(ln)
+}
+
+// Shutdown stops accepting connections and waits for in-flight requests and
+// open tunnels to finish. Tunnels still open when ctx is done are closed.
+func (p *Proxy) Shutdown(ctx context.Context) error {
+	p.mu.Lock()
+	p.closed = true
+	server := p.server
+	p.mu.Unlock()
+
+	var err error
+	if server != nil {
+		err = server.Shutdown(ctx)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		p.mu.Lock()
+		for conn := range p.tunnels {
+			conn.Close()
+		}
+		p.mu.Unlock()
+		<-done
+		if err == nil {
+			err = ctx.Err()
+		}
+	}
+	return err
+}
+
+// ServeHTTP implements http.Handler
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodConnect {
+		p.serveTunnel(w, r)
+		return
+	}
+	if !r.URL.IsAbs() || r.URL.Host == "" {
+		http.Error(w, "not a proxy request", http.StatusBadRequest)
+		return
+	}
+
+	resp, err := p.forward(r)
+	if err != nil {
+		http.Error(w, "bad gateway", http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	removeHopByHopHeaders(resp.Header)
+	for name, values := range resp.Header {
+		w.Header()[name] = values
+	}
+	w.WriteHeader(resp.StatusCode)
+	_, _ = io.Copy(flushWriter{w}, resp.Body)
+}
+
+// forward sends r, whose URL is absolute, through the Logger and returns the
+// response. Errors are logged.
+func (p *Proxy) forward(r *http.Request) (*http.Response, error) {
+	out := r.Clone(r.Context())
+	out.RequestURI = ""
+	if r.ContentLength == 0 {
+		out.Body = nil
+	}
+	removeHopByHopHeaders(out.Header)
+	decodeByTransport(out.Header)
+
+	resp, err := p.logger.roundTrip(out, p.transport, forwardRoundTrip)
+	if err != nil {
+		p.logger.logger.Error("failed to forward proxy request",
+			"error", err,
+			"method", r.Method,
+			"url", r.URL.String(),
+		)
+		return nil, err
+	}
+	return resp, nil
+}
+
+// serveTunnel relays a CONNECT tunnel between the client and the target, or
+// intercepts it if enabled for the target
+func (p *Proxy) serveTunnel(w http.ResponseWriter, r *http.Request) {
+	if !p.openTunnel() {
+		http.Error(w, "proxy is shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	defer p.wg.Done()
+
+	if p.shouldIntercept(r.Host) {
+		p.serveIntercepted(w, r)
+		return
+	}
+
+	start := time.Now()
+	target := r.Host
+	upstream, err := p.dialTunnel(r.Context(), target)
+	if err != nil {
+		p.logger.logger.Error("failed to open tunnel", "error", err, "target", target)
+		http.Error(w, "bad gateway", http.StatusBadGateway)
+		return
+	}
+	defer upstream.Close()
+
+	client, err := p.hijack(w, target)
+	if err != nil {
+		return
+	}
+	defer client.Close()
+
+	p.track(client, true)
+	p.track(upstream, true)
+	defer p.track(client, false)
+	defer p.track(upstream, false)
+
+	var sent int64
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		sent, _ = io.Copy(upstream, client)
+		_ = closeWrite(upstream)
+	}()
+	received, _ := io.Copy(client, upstream)
+	_ = closeWrite(client)
+	<-done
+
+	ctrl := controlFrom(r.Context())
+	if !p.logger.shouldCapture(ctrl) {
+		return
+	}
+
+	scheme := "https"
+	if _, port, _ := net.SplitHostPort(target); port == "80" {
+		scheme = "http"
+	}
+	entry := &HAREntry{
+		StartedDateTime: start.Format(time.RFC3339),
+		Time:            float64(time.Since(start).Milliseconds()),
+		Request: HARRequest{
+			Method:      r.Method,
+			URL:         scheme + "://" + target,
+			HTTPVersion: r.Proto,
+			Headers:     convertHeaders(r.Header),
+			QueryString: []HARQuery{},
+			HeadersSize: -1,
+			BodySize:    int(sent),
+		},
+		Response: HARResponse{
+			Status:      http.StatusOK,
+			StatusText:  "200 Connection Established",
+			HTTPVersion: "HTTP/1.1",
+			Headers:     []HARHeader{},
+			Content:     HARContent{Size: int(received)},
+			HeadersSize: -1,
+			BodySize:    int(received),
+		},
+		Tunnel: &HARTunnel{
+			Target:        target,
+			BytesSent:     sent,
+			BytesReceived: received,
+		},
+	}
+	p.logger.applyControl(ctrl, entry)
+	p.logger.writeEntry(r, entry)
+}
+
+// hijack takes over the connection of a CONNECT request and confirms the
+// tunnel to the client. Data read ahead by the server is returned by reads
+// from the connection.
+func (p *Proxy) hijack(w http.ResponseWriter, target string) (net.Conn, error) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "tunneling is not supported", http.StatusInternalServerError)
+		return nil, errors.New("tunneling is not supported")
+	}
+	conn, brw, err := hijacker.Hijack()
+	if err != nil {
+		p.logger.logger.Error("failed to hijack connection", "error", err, "target", target)
+		return nil, err
+	}
+	if _, err := io.WriteString(conn, "HTTP/1.1 200 Connection Established\r\n\r\n"); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &bufferedConn{Conn: conn, r: brw.Reader}, nil
+}
+
+// dialTunnel connects to target directly or through the upstream proxy
+func (p *Proxy) dialTunnel(ctx context.Context, target string) (net.Conn, error) {
+	var dialer net.Dialer
+	if p.upstream == nil {
+		return dialer.DialContext(ctx, "tcp", target)
+	}
+
+	addr := p.upstream.Host
+	if p.upstream.Port() == "" {
+		if p.upstream.Scheme == "https" {
+			addr = net.JoinHostPort(p.upstream.Hostname(), "443")
+		} else {
+			addr = net.JoinHostPort(p.upstream.Hostname(), "80")
+		}
+	}
+
+	var conn net.Conn
+	var err error
+	if p.upstream.Scheme == "https" {
+		tlsDialer := tls.Dialer{NetDialer: &dialer, Config: &tls.Config{ServerName: p.upstream.Hostname()}}
+		conn, err = tlsDialer.DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to upstream proxy: %w", err)
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: target},
+		Host:   target,
+		Header: make(http.Header),
+	}
+	if user := p.upstream.User; user != nil {
+		password, _ := user.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(user.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to send CONNECT to upstream proxy: %w", err)
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to read CONNECT response of upstream proxy: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("upstream proxy refused CONNECT: %s", resp.Status)
+	}
+	return &bufferedConn{Conn: conn, r: br}, nil
+}
+
+// openTunnel registers a tunnel to be waited for by Shutdown. It reports
+// false if the proxy is shutting down.
+func (p *Proxy) openTunnel() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return false
+	}
+	p.wg.Add(1)
+	return true
+}
+
+// track adds or removes conn from the tunnels closed by Shutdown
+func (p *Proxy) track(conn net.Conn, add bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if add {
+		p.tunnels[conn] = struct{}{}
+	} else {
+		delete(p.tunnels, conn)
+	}
+}
+
+// bufferedConn is a net.Conn whose reads are served from r first
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
+func (c *bufferedConn) CloseWrite() error {
+	return closeWrite(c.Conn)
+}
+
+// closeWrite shuts down the writing side of conn, or closes it if half close
+// is not supported
+func closeWrite(conn net.Conn) error {
+	if cw, ok := conn.(interface{ CloseWrite() error }); ok {
+		return cw.CloseWrite()
+	}
+	return conn.Close()
+}
+
+// flushWriter flushes after each write so that streamed responses reach the
+// client without delay
+type flushWriter struct {
+	w http.ResponseWriter
+}
+
+func (fw flushWriter) Write(b []byte) (int, error) {
+	n, err := fw.w.Write(b)
+	if f, ok := fw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return n, err
+}
+
+// hopByHopHeaders are the headers that apply to a single connection and are
+// not forwarded by proxies
+var hopByHopHeaders = []string{
+	"Connection",
+	"Proxy-Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// removeHopByHopHeaders removes hop-by-hop headers, including those listed
+// in the Connection header
+func removeHopByHopHeaders(h http.Header) {
+	for _, value := range h.Values("Connection") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				h.Del(name)
+			}
+		}
+	}
+	for _, name := range hopByHopHeaders {
+		h.Del(name)
+	}
+}
+
+// decodeByTransport removes the Accept-Encoding header of a request forwarded
+// by a proxy, so that the transport negotiates and decodes compression and
+// bodies are recorded as plain text
+func decodeByTransport(h http.Header) {
+	h.Del("Accept-Encoding")
+}
@@ -0,0 +1,282 @@
+package harlog
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startProxy serves p on a local port and returns its URL
+func startProxy(t *testing.T, p *Proxy) *url.URL {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := make(chan error, 1)
+	go func() {
+		served <- p.Serve(ln)
+	}()
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_ = p.Shutdown(ctx)
+		if err := <-served; !errors.Is(err, http.ErrServerClosed) {
+			t.Errorf("expected ErrServerClosed, got %v", err)
+		}
+	})
+	return &url.URL{Scheme: "http", Host: ln.Addr().String()}
+}
+
+func proxyClient(proxyURL *url.URL, base http.RoundTripper) *http.Client {
+	transport := base.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyURL(proxyURL)
+	return &http.Client{Transport: transport}
+}
+
+func waitEntry(t *testing.T, rec *Recorder, method, pattern string) HAREntry {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	entry, err := rec.WaitFor(ctx, func(entry *HAREntry) bool {
+		return entry.Request.Method == method && strings.Contains(entry.Request.URL, pattern)
+	})
+	if err != nil {
+		t.Fatalf("no %s entry for %s: %v", method, pattern, err)
+	}
+	return entry
+}
+
+func TestProxy(t *testing.T) {
+	t.Parallel()
+
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprintf(w, "%s %s %s", r.Method, r.URL.Path, body)
+	}))
+	defer backend.Close()
+
+	rec := NewRecorder()
+	logger := New(WithFileOutput(false), WithSink(rec))
+	proxyURL := startProxy(t, NewProxy(logger))
+	client := proxyClient(proxyURL, http.DefaultTransport)
+
+	resp, err := client.Post(backend.URL+"/users", "text/plain", strings.NewReader("alice"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "POST /users alice" {
+		t.Errorf("unexpected response: %s", body)
+	}
+
+	entry := waitEntry(t, rec, http.MethodPost, "/users")
+	if entry.Request.URL != backend.URL+"/users" || entry.Request.PostData == nil || entry.Request.PostData.Text != "alice" {
+		t.Errorf("unexpected request: %+v", entry.Request)
+	}
+	if entry.Response.Status != 200 || entry.Response.Content.Text != "POST /users alice" {
+		t.Errorf("unexpected response: %+v", entry.Response)
+	}
+
+	// Requests that are not proxy requests are rejected
+	resp, err = http.Get(proxyURL.String() + "/users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", resp.StatusCode)
+	}
+}
+
+func TestProxy_Tunnel(t *testing.T) {
+	t.Parallel()
+
+	backend := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("secret"))
+	}))
+	defer backend.Close()
+
+	rec := NewRecorder()
+	logger := New(WithFileOutput(false), WithSink(rec))
+	proxyURL := startProxy(t, NewProxy(logger))
+	client := proxyClient(proxyURL, backend.Client().Transport)
+
+	resp, err := client.Get(backend.URL + "/private")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "secret" {
+		t.Errorf("unexpected response: %s", body)
+	}
+	// The tunnel is recorded when it is closed
+	client.CloseIdleConnections()
+
+	host := strings.TrimPrefix(backend.URL, "https://")
+	entry := waitEntry(t, rec, http.MethodConnect, host)
+	if entry.Tunnel == nil || entry.Tunnel.Target != host {
+		t.Fatalf("expected tunnel entry: %+v", entry)
+	}
+	if entry.Tunnel.BytesSent == 0 || entry.Tunnel.BytesReceived == 0 || entry.Response.Content.Text != "" {
+		t.Errorf("unexpected tunnel: %+v", entry.Tunnel)
+	}
+	if entry.Request.URL != "https://"+host || entry.Response.Status != 200 {
+		t.Errorf("unexpected entry: %+v", entry)
+	}
+}
+
+func TestProxy_Upstream(t *testing.T) {
+	t.Parallel()
+
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer backend.Close()
+	tlsBackend := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer tlsBackend.Close()
+
+	upstreamRec := NewRecorder()
+	var auth string
+	upstreamURL := startProxy(t, NewProxy(New(WithFileOutput(false), WithSink(upstreamRec))))
+	upstreamURL.User = url.UserPassword("user", "pass")
+
+	// Check the credentials sent to the upstream proxy
+	authProxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Proxy-Authorization")
+		http.Error(w, "denied", http.StatusProxyAuthRequired)
+	}))
+	defer authProxy.Close()
+
+	rec := NewRecorder()
+	proxyURL := startProxy(t, NewProxy(New(WithFileOutput(false), WithSink(rec)), WithUpstreamProxy(upstreamURL)))
+
+	client := proxyClient(proxyURL, tlsBackend.Client().Transport)
+	for _, u := range []string{backend.URL + "/plain", tlsBackend.URL + "/tls"} {
+		resp, err := client.Get(u)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("%s: unexpected status %d", u, resp.StatusCode)
+		}
+	}
+	client.CloseIdleConnections()
+
+	waitEntry(t, rec, http.MethodGet, "/plain")
+	waitEntry(t, upstreamRec, http.MethodGet, "/plain")
+	tlsHost := strings.TrimPrefix(tlsBackend.URL, "https://")
+	waitEntry(t, rec, http.MethodConnect, tlsHost)
+	waitEntry(t, upstreamRec, http.MethodConnect, tlsHost)
+
+	authURL, _ := url.Parse(authProxy.URL)
+	authURL.User = url.UserPassword("user", "pass")
+	p := NewProxy(New(WithFileOutput(false)), WithUpstreamProxy(authURL))
+	if _, err := p.dialTunnel(context.Background(), tlsHost); err == nil || !strings.Contains(err.Error(), "407") {
+		t.Errorf("expected refused CONNECT, got %v", err)
+	}
+	if auth != "Basic dXNlcjpwYXNz" {
+		t.Errorf("unexpected Proxy-Authorization: %q", auth)
+	}
+}
+
+func TestProxy_Shutdown(t *testing.T) {
+	t.Parallel()
+
+	// A target that never closes the tunnel
+	target, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer target.Close()
+	go func() {
+		for {
+			conn, err := target.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := NewRecorder()
+	p := NewProxy(New(WithFileOutput(false), WithSink(rec)))
+	served := make(chan error, 1)
+	go func() {
+		served <- p.Serve(ln)
+	}()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	fmt.Fprintf(conn, "CONNECT %s HTTP/1.1\r\nHost: %s\r\n\r\n", target.Addr(), target.Addr())
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected status: %s", resp.Status)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := p.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+	if err := <-served; !errors.Is(err, http.ErrServerClosed) {
+		t.Errorf("expected ErrServerClosed, got %v", err)
+	}
+
+	// The tunnel was closed and recorded
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err == nil {
+		t.Error("expected tunnel to be closed")
+	}
+	if rec.Len() != 1 {
+		t.Errorf("expected 1 tunnel entry, got %d", rec.Len())
+	}
+
+	// Tunnels are refused once the proxy is shutting down
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest(http.MethodConnect, "http://"+target.Addr().String(), nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 after Shutdown, got %d", w.Code)
+	}
+}
+
+func TestRemoveHopByHopHeaders(t *testing.T) {
+	h := http.Header{
+		"Connection":       {"keep-alive, X-Hop"},
+		"X-Hop":            {"1"},
+		"Keep-Alive":       {"timeout=5"},
+		"Content-Type":     {"text/plain"},
+		"Proxy-Connection": {"keep-alive"},
+	}
+	removeHopByHopHeaders(h)
+	if len(h) != 1 || h.Get("Content-Type") != "text/plain" {
+		t.Errorf("unexpected headers: %v", h)
+	}
+}
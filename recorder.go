@@ -0,0 +1,155 @@
+package harlog
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"path"
+	"sync"
+)
+
+// Recorder is a Sink that keeps HAR entries in memory. It is safe for
+// concurrent use and intended for tests:
+//
+//	rec := harlog.NewRecorder()
+//	logger := harlog.New(harlog.WithSink(rec), harlog.WithFileOutput(false))
+type Recorder struct {
+	mu      sync.Mutex
+	entries []HAREntry
+	// changed is closed and replaced whenever an entry is added
+	changed chan struct{}
+	// resets counts the calls to Reset
+	resets int
+}
+
+// NewRecorder creates an empty Recorder
+func NewRecorder() *Recorder {
+	return &Recorder{
+		changed: make(chan struct{}),
+	}
+}
+
+// WriteEntry implements Sink
+func (r *Recorder) WriteEntry(req *http.Request, entry *HAREntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.entries = append(r.entries, *entry)
+	close(r.changed)
+	r.changed = make(chan struct{})
+	return nil
+}
+
+// Entries returns a copy of the recorded entries in the order they completed
+func (r *Recorder) Entries() []HAREntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entries := make([]HAREntry, len(r.entries))
+	copy(entries, r.entries)
+	return entries
+}
+
+// Len returns the number of recorded entries
+func (r *Recorder) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.entries)
+}
+
+// Last returns the most recently recorded entry
+func (r *Recorder) Last() (HAREntry, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.entries) == 0 {
+		return HAREntry{}, false
+	}
+	return r.entries[len(r.entries)-1], true
+}
+
+// Find returns the entries whose method matches method and whose URL path
+// matches pathPattern, a pattern as accepted by path.Match. An empty method
+// or pattern matches any value.
+func (r *Recorder) Find(method, pathPattern string) []HAREntry {
+	var found []HAREntry
+	for _, entry := range r.Entries() {
+		if MatchEntry(&entry, method, pathPattern) {
+			found = append(found, entry)
+		}
+	}
+	return found
+}
+
+// MatchEntry reports whether the request of entry has the given method and a
+// URL path matching pathPattern as in path.Match. An empty method or pattern
+// matches any value.
+func MatchEntry(entry *HAREntry, method, pathPattern string) bool {
+	if method != "" && entry.Request.Method != method {
+		return false
+	}
+	if pathPattern == "" {
+		return true
+	}
+
+	u, err := url.Parse(entry.Request.URL)
+	if err != nil {
+		return false
+	}
+	ok, _ := path.Match(pathPattern, u.Path)
+	return ok
+}
+
+// WaitFor returns the first recorded entry satisfying predicate, waiting for
+// new entries until ctx is done. Entries recorded before the call are
+// considered as well. The predicate is called without holding the lock of
+// the Recorder, so it may call its methods.
+func (r *Recorder) WaitFor(ctx context.Context, predicate func(entry *HAREntry) bool) (HAREntry, error) {
+	next, resets := 0, -1
+	for {
+		r.mu.Lock()
+		if resets != r.resets {
+			// Entries were removed by Reset, so those recorded since are new
+			next, resets = 0, r.resets
+		}
+		entries := make([]HAREntry, len(r.entries)-next)
+		copy(entries, r.entries[next:])
+		next = len(r.entries)
+		changed := r.changed
+		r.mu.Unlock()
+
+		for i := range entries {
+			if predicate(&entries[i]) {
+				return entries[i], nil
+			}
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return HAREntry{}, ctx.Err()
+		}
+	}
+}
+
+// Reset removes all recorded entries
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = nil
+	r.resets++
+}
+
+// HAR returns the recorded entries as a HAR log
+func (r *Recorder) HAR() *HAR {
+	return &HAR{
+		Log: HARLog{
+			Version: "1.2",
+			Creator: HARCreator{
+				Name:    "harlog",
+				Version: "1.0",
+			},
+			Entries: r.Entries(),
+		},
+	}
+}
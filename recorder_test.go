@@ -0,0 +1,229 @@
+package harlog
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRecorder(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := t.TempDir()
+	rec := NewRecorder()
+	logger := New(
+		WithOutputDir(tmpDir),
+		WithFileOutput(false),
+		WithSink(rec),
+	)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(50 * time.Millisecond)
+		}
+		_, _ = w.Write([]byte(r.URL.Path))
+	})
+	server := httptest.NewServer(logger.Middleware(handler))
+	defer server.Close()
+
+	// Wait for an entry that is recorded after WaitFor starts
+	waited := make(chan HAREntry, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		entry, err := rec.WaitFor(ctx, func(entry *HAREntry) bool {
+			return MatchEntry(entry, http.MethodGet, "/slow")
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		waited <- entry
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			resp, err := http.Get(fmt.Sprintf("%s/users/%d", server.URL, i))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}(i)
+	}
+	wg.Wait()
+
+	resp, err := http.Post(server.URL+"/slow", "text/plain", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	resp, err = http.Get(server.URL + "/slow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	entry := <-waited
+	if entry.Request.Method != http.MethodGet || entry.Response.Content.Text != "/slow" {
+		t.Errorf("unexpected entry: %+v", entry)
+	}
+
+	if n := rec.Len(); n != 12 {
+		t.Errorf("expected 12 entries, got %d", n)
+	}
+	if found := rec.Find(http.MethodGet, "/users/*"); len(found) != 10 {
+		t.Errorf("expected 10 entries, got %d", len(found))
+	}
+	if found := rec.Find("", "/slow"); len(found) != 2 {
+		t.Errorf("expected 2 entries, got %d", len(found))
+	}
+	if last, ok := rec.Last(); !ok || last.Request.Method != http.MethodGet || last.Response.Content.Text != "/slow" {
+		t.Errorf("unexpected last entry: %+v", last)
+	}
+	if har := rec.HAR(); len(har.Log.Entries) != 12 || har.Log.Version != "1.2" {
+		t.Errorf("unexpected HAR: %+v", har.Log)
+	}
+	if n := countHARFiles(t, tmpDir); n != 0 {
+		t.Errorf("expected no HAR files, got %d", n)
+	}
+
+	rec.Reset()
+	if _, ok := rec.Last(); ok || rec.Len() != 0 {
+		t.Error("entries remain after Reset")
+	}
+}
+
+func TestRecorder_WaitForTimeout(t *testing.T) {
+	t.Parallel()
+
+	rec := NewRecorder()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := rec.WaitFor(ctx, func(entry *HAREntry) bool { return true })
+	if err != context.DeadlineExceeded {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+}
+
+func TestRecorder_WaitForReset(t *testing.T) {
+	t.Parallel()
+
+	rec := NewRecorder()
+	write := func(path string) {
+		entry := &HAREntry{Request: HARRequest{Method: http.MethodGet, URL: "http://example.com" + path}}
+		if err := rec.WriteEntry(nil, entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("/a")
+	write("/b")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	checked := make(chan struct{}, 2)
+	found := make(chan HAREntry)
+	go func() {
+		// The predicate may use the Recorder
+		entry, err := rec.WaitFor(ctx, func(entry *HAREntry) bool {
+			if rec.Len() > 0 && strings.HasSuffix(entry.Request.URL, "/b") {
+				checked <- struct{}{}
+			}
+			return strings.HasSuffix(entry.Request.URL, "/new")
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		found <- entry
+	}()
+	<-checked
+
+	// Entries recorded after Reset are all considered
+	rec.Reset()
+	write("/new")
+	write("/c")
+	write("/d")
+	if entry := <-found; !strings.HasSuffix(entry.Request.URL, "/new") {
+		t.Errorf("expected entry recorded after Reset, got %s", entry.Request.URL)
+	}
+}
+
+func TestWithProcessor(t *testing.T) {
+	t.Parallel()
+
+	rec := NewRecorder()
+	logger := New(
+		WithFileOutput(false),
+		WithSink(rec),
+		WithProcessor(func(req *http.Request, entry *HAREntry) {
+			entry.Response.Content.Text = strings.ToUpper(entry.Response.Content.Text)
+		}),
+		WithProcessor(func(req *http.Request, entry *HAREntry) {
+			entry.Comment = req.URL.Path + ":" + entry.Response.Content.Text
+		}),
+	)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("hello"))
+	})
+	server := httptest.NewServer(logger.Middleware(handler))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/greeting")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	entry, err := rec.WaitFor(ctx, func(entry *HAREntry) bool { return true })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Comment != "/greeting:HELLO" {
+		t.Errorf("expected processors to run in order, got comment %q", entry.Comment)
+	}
+}
+
+func TestLogger_WriteEntry(t *testing.T) {
+	t.Parallel()
+
+	rec := NewRecorder()
+	logger := New(WithFileOutput(false), WithSink(rec), WithDefaultBodyCapture(false))
+
+	newEntry := func() *HAREntry {
+		return &HAREntry{
+			Request:  HARRequest{Method: http.MethodPost, URL: "grpc://example.com/pkg.Service/Method"},
+			Response: HARResponse{Status: http.StatusOK, Content: HARContent{Text: "secret"}},
+		}
+	}
+	req := httptest.NewRequest(http.MethodPost, "grpc://example.com/pkg.Service/Method", nil)
+	if err := logger.WriteEntry(req.WithContext(WithTag(req.Context(), "kind", "grpc")), newEntry()); err != nil {
+		t.Fatal(err)
+	}
+	if err := logger.WriteEntry(req.WithContext(Skip(req.Context())), newEntry()); err != nil {
+		t.Fatal(err)
+	}
+
+	if rec.Len() != 1 {
+		t.Fatalf("expected 1 entry, got %d", rec.Len())
+	}
+	entry, _ := rec.Last()
+	if entry.Tags["kind"] != "grpc" {
+		t.Errorf("expected tag from context, got %+v", entry.Tags)
+	}
+	if entry.Response.Content.Text != "" {
+		t.Errorf("expected body to be dropped, got %q", entry.Response.Content.Text)
+	}
+}
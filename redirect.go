@@ -0,0 +1,114 @@
+package harlog
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// WithRedirectGrouping saves the hops of a redirect chain followed by an
+// http.Client to a single HAR file named for the first request, with the
+// entries in the order of the hops (default: false). The file is rewritten
+// as each hop is recorded. Sinks receive the entries one by one either way.
+func WithRedirectGrouping(enabled bool) Option {
+	return func(l *Logger) {
+		l.groupRedirects = enabled
+	}
+}
+
+// redirectChain holds the hops of a redirect chain recorded so far
+type redirectChain struct {
+	id string
+
+	mu      sync.Mutex
+	entries []HAREntry
+	path    string
+}
+
+// redirectHop is a request of a redirect chain
+type redirectHop struct {
+	chain *redirectChain
+	index int
+}
+
+type redirectCtxKey struct{}
+
+// redirectHopFrom returns the hop recorded by req, if any
+func redirectHopFrom(ctx context.Context) *redirectHop {
+	hop, _ := ctx.Value(redirectCtxKey{}).(*redirectHop)
+	return hop
+}
+
+// redirectBody marks the body of a redirect response with the hop it ended,
+// so that the request following the redirect, whose Response field is set
+// by http.Client, continues the chain
+type redirectBody struct {
+	io.ReadCloser
+	hop *redirectHop
+}
+
+// isRedirect reports whether resp redirects the client to its Location
+func isRedirect(resp *http.Response) bool {
+	switch resp.StatusCode {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return resp.Header.Get("Location") != ""
+	}
+	return false
+}
+
+// followRedirects links req, answered by resp, to the redirect chain it
+// belongs to. A chain starts with a redirect response and continues with
+// the requests made by http.Client to follow it. It returns req with the hop
+// in its context.
+func followRedirects(req *http.Request, resp *http.Response) *http.Request {
+	var hop *redirectHop
+	if req.Response != nil {
+		if prev, ok := req.Response.Body.(*redirectBody); ok {
+			hop = &redirectHop{chain: prev.hop.chain, index: prev.hop.index + 1}
+		}
+	}
+	if hop == nil {
+		if !isRedirect(resp) {
+			return req
+		}
+		hop = &redirectHop{chain: &redirectChain{id: uuid.New().String()}}
+	}
+
+	return req.WithContext(context.WithValue(req.Context(), redirectCtxKey{}, hop))
+}
+
+// markRedirect marks the body of resp with the hop of req, once the body is
+// no longer replaced, if resp redirects further
+func markRedirect(req *http.Request, resp *http.Response) {
+	if hop := redirectHopFrom(req.Context()); hop != nil && isRedirect(resp) {
+		resp.Body = &redirectBody{ReadCloser: resp.Body, hop: hop}
+	}
+}
+
+// har returns the description of the hop for its entry
+func (h *redirectHop) har() *HARRedirect {
+	if h == nil {
+		return nil
+	}
+	return &HARRedirect{ChainID: h.chain.id, Hop: h.index}
+}
+
+// saveRedirectHop adds entry to the HAR file of the chain of hop and returns
+// its path
+func (l *Logger) saveRedirectHop(req *http.Request, entry *HAREntry, hop *redirectHop) (string, error) {
+	chain := hop.chain
+	chain.mu.Lock()
+	defer chain.mu.Unlock()
+
+	entries := append(chain.entries, *entry)
+	path, err := l.saveHAR(req, entries, chain.path)
+	if err != nil {
+		return "", err
+	}
+	chain.entries, chain.path = entries, path
+	return path, nil
+}
@@ -0,0 +1,163 @@
+package harlog
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newRedirectServer() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/sso", http.StatusFound)
+	})
+	mux.HandleFunc("/sso", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/callback?code=1", http.StatusSeeOther)
+	})
+	mux.HandleFunc("/callback", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "welcome")
+	})
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop", http.StatusTemporaryRedirect)
+	})
+	return httptest.NewServer(mux)
+}
+
+func get(t *testing.T, client *http.Client, url string) error {
+	t.Helper()
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return resp.Body.Close()
+}
+
+func TestRedirectChain(t *testing.T) {
+	t.Parallel()
+
+	server := newRedirectServer()
+	defer server.Close()
+
+	dir := t.TempDir()
+	rec := NewRecorder()
+	client := &http.Client{Transport: New(WithOutputDir(dir), WithSink(rec), WithRedirectGrouping(true))}
+	if err := get(t, client, server.URL+"/login"); err != nil {
+		t.Fatal(err)
+	}
+	if err := get(t, client, server.URL+"/callback"); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := rec.Entries()
+	if len(entries) != 4 {
+		t.Fatalf("expected 4 entries, got %d", len(entries))
+	}
+	redirects := []string{"/sso", "/callback?code=1", ""}
+	for i, entry := range entries[:3] {
+		if entry.Redirect == nil || entry.Redirect.ChainID != entries[0].Redirect.ChainID || entry.Redirect.Hop != i {
+			t.Errorf("hop %d: unexpected redirect %+v", i, entry.Redirect)
+		}
+		if entry.Response.RedirectURL != redirects[i] {
+			t.Errorf("hop %d: expected redirectURL %q, got %q", i, redirects[i], entry.Response.RedirectURL)
+		}
+	}
+	if entries[3].Redirect != nil {
+		t.Errorf("expected request without redirects not to be linked, got %+v", entries[3].Redirect)
+	}
+
+	// The chain is saved to a single file named for the first request
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("expected 2 files, got %d", len(files))
+	}
+	for _, file := range files {
+		if !strings.Contains(file.Name(), "-login") {
+			continue
+		}
+		har, err := ReadHARFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(har.Log.Entries) != 3 {
+			t.Fatalf("expected 3 entries in the chain file, got %d", len(har.Log.Entries))
+		}
+		for i, entry := range har.Log.Entries {
+			if entry.Redirect == nil || entry.Redirect.Hop != i {
+				t.Errorf("expected hop %d in order, got %+v", i, entry.Redirect)
+			}
+		}
+		return
+	}
+	t.Errorf("expected file for the chain, got %v", files)
+}
+
+func TestRedirectChain_Loop(t *testing.T) {
+	t.Parallel()
+
+	server := newRedirectServer()
+	defer server.Close()
+
+	dir := t.TempDir()
+	rec := NewRecorder()
+	client := &http.Client{Transport: New(WithOutputDir(dir), WithSink(rec))}
+	if err := get(t, client, server.URL+"/loop"); err == nil || !strings.Contains(err.Error(), "stopped after 10 redirects") {
+		t.Fatalf("expected redirect loop error, got %v", err)
+	}
+
+	// Without grouping, every hop has its own file
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 10 || rec.Len() != 10 {
+		t.Fatalf("expected 10 files and entries, got %d and %d", len(files), rec.Len())
+	}
+	for i, entry := range rec.Entries() {
+		if entry.Redirect == nil || entry.Redirect.ChainID != rec.Entries()[0].Redirect.ChainID || entry.Redirect.Hop != i {
+			t.Errorf("hop %d: unexpected redirect %+v", i, entry.Redirect)
+		}
+	}
+}
+
+func TestRedirectChain_Proxies(t *testing.T) {
+	t.Parallel()
+
+	server := newRedirectServer()
+	defer server.Close()
+
+	// Redirects followed by proxied clients are not linked
+	forwardRec := NewRecorder()
+	proxyURL := startProxy(t, NewProxy(New(WithFileOutput(false), WithSink(forwardRec))))
+	if err := get(t, proxyClient(proxyURL, http.DefaultTransport), server.URL+"/login"); err != nil {
+		t.Fatal(err)
+	}
+
+	target, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reverseRec := NewRecorder()
+	reverse := httptest.NewServer(NewReverseProxy(target, WithFileOutput(false), WithSink(reverseRec)))
+	defer reverse.Close()
+	if err := get(t, reverse.Client(), reverse.URL+"/login"); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, rec := range map[string]*Recorder{"forward": forwardRec, "reverse": reverseRec} {
+		waitEntry(t, rec, http.MethodGet, "/callback")
+		for _, entry := range rec.Entries() {
+			if entry.Redirect != nil {
+				t.Errorf("%s: expected no redirect chain, got %+v for %s", name, entry.Redirect, entry.Request.URL)
+			}
+		}
+	}
+}
@@ -0,0 +1,306 @@
+// Package replay re-sends captured HAR traffic to another server, such as a
+// staging or local instance, and compares the new responses with the
+// recorded ones.
+//
+// Requests are rebuilt with harlog.ConvertEntry and their URLs are rewritten
+// to the target base URL. They can be sent as fast as the concurrency limit
+// allows, with the original timing of the capture, or at a fixed rate.
+package replay
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/m-mizutani/harlog"
+	"github.com/m-mizutani/harlog/hardiff"
+)
+
+// Option represents a configuration option for Replayer
+type Option func(*Replayer)
+
+// WithClient sets the HTTP client used to send requests
+// (default: a client that does not follow redirects)
+func WithClient(client *http.Client) Option {
+	return func(r *Replayer) {
+		r.client = client
+	}
+}
+
+// WithConcurrency sets the maximum number of requests in flight (default: 1)
+func WithConcurrency(n int) Option {
+	return func(r *Replayer) {
+		r.concurrency = max(n, 1)
+	}
+}
+
+// WithOriginalTiming sends requests at the offsets they were recorded at,
+// divided by speed. A speed of 2 replays the capture twice as fast.
+func WithOriginalTiming(speed float64) Option {
+	return func(r *Replayer) {
+		r.speed = speed
+		r.rate = 0
+	}
+}
+
+// WithRate sends requests at a fixed number of requests per second
+func WithRate(perSecond float64) Option {
+	return func(r *Replayer) {
+		r.rate = perSecond
+		r.speed = 0
+	}
+}
+
+// WithHeader sets a request header on every replayed request, replacing the
+// recorded values, e.g. to use a fresh Authorization token
+func WithHeader(name, value string) Option {
+	return func(r *Replayer) {
+		r.headers.Set(name, value)
+	}
+}
+
+// WithoutHeaders removes recorded request headers before sending
+func WithoutHeaders(names ...string) Option {
+	return func(r *Replayer) {
+		for _, name := range names {
+			r.removedHeaders = append(r.removedHeaders, http.CanonicalHeaderKey(name))
+		}
+	}
+}
+
+// WithBodyValues reports changed JSON values and changed non-JSON bodies in
+// addition to structural differences, as hardiff.WithBodyValues
+func WithBodyValues() Option {
+	return func(r *Replayer) {
+		r.bodyOpts = append(r.bodyOpts, hardiff.WithBodyValues())
+	}
+}
+
+// Replayer sends recorded requests to a target server
+type Replayer struct {
+	target         *url.URL
+	client         *http.Client
+	concurrency    int
+	speed          float64
+	rate           float64
+	headers        http.Header
+	removedHeaders []string
+	bodyOpts       []hardiff.Option
+}
+
+// New creates a Replayer sending requests to the target base URL. The scheme
+// and host of recorded URLs are replaced by those of target, and the path of
+// target, if any, is prepended to recorded paths.
+func New(target string, opts ...Option) (*Replayer, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse target URL: %w", err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("target URL must have a scheme and host: %s", target)
+	}
+
+	r := &Replayer{
+		target: u,
+		client: &http.Client{
+			// Redirects are replayed as recorded, so they must not be followed
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		concurrency: 1,
+		headers:     make(http.Header),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r, nil
+}
+
+// ReplayFile replays the entries of a HAR file
+func (r *Replayer) ReplayFile(ctx context.Context, filename string) (*Summary, error) {
+	har, err := harlog.ReadHARFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return r.Replay(ctx, har)
+}
+
+// Replay sends the requests of all entries of har and returns a summary of
+// the responses. If ctx is canceled, the results of the requests sent so far
+// are returned together with the context error.
+func (r *Replayer) Replay(ctx context.Context, har *harlog.HAR) (*Summary, error) {
+	entries := har.Log.Entries
+	order, offsets := r.schedule(entries)
+
+	results := make([]*Result, len(entries))
+	sem := make(chan struct{}, r.concurrency)
+	var wg sync.WaitGroup
+
+	start := time.Now()
+	err := func() error {
+		for k, i := range order {
+			if err := sleepUntil(ctx, start.Add(offsets[k])); err != nil {
+				return err
+			}
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				results[i] = r.replayEntry(ctx, i, &entries[i])
+			}(i)
+		}
+		return nil
+	}()
+	wg.Wait()
+
+	return newSummary(results), err
+}
+
+// schedule returns the order in which entries are sent and the offset from
+// the start of the replay at which each of them is sent
+func (r *Replayer) schedule(entries []harlog.HAREntry) ([]int, []time.Duration) {
+	order := make([]int, len(entries))
+	for i := range order {
+		order[i] = i
+	}
+	offsets := make([]time.Duration, len(entries))
+
+	switch {
+	case r.speed > 0:
+		started := make([]time.Time, len(entries))
+		for i := range entries {
+			started[i], _ = time.Parse(time.RFC3339Nano, entries[i].StartedDateTime)
+		}
+		sort.SliceStable(order, func(a, b int) bool {
+			return started[order[a]].Before(started[order[b]])
+		})
+
+		var first time.Time
+		for k, i := range order {
+			if started[i].IsZero() {
+				continue
+			}
+			if first.IsZero() {
+				first = started[i]
+			}
+			offsets[k] = time.Duration(float64(started[i].Sub(first)) / r.speed)
+		}
+
+	case r.rate > 0:
+		interval := float64(time.Second) / r.rate
+		for k := range order {
+			offsets[k] = time.Duration(float64(k) * interval)
+		}
+	}
+
+	return order, offsets
+}
+
+func sleepUntil(ctx context.Context, t time.Time) error {
+	d := time.Until(t)
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *Replayer) replayEntry(ctx context.Context, index int, entry *harlog.HAREntry) *Result {
+	result := &Result{
+		Index:            index,
+		Method:           entry.Request.Method,
+		URL:              entry.Request.URL,
+		RecordedStatus:   entry.Response.Status,
+		RecordedDuration: time.Duration(entry.Time * float64(time.Millisecond)),
+	}
+
+	req, err := r.newRequest(ctx, entry)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.URL = req.URL.String()
+
+	start := time.Now()
+	resp, err := r.client.Do(req)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	result.Duration = time.Since(start)
+	if err != nil {
+		result.Error = fmt.Sprintf("failed to read response body: %v", err)
+		return result
+	}
+	result.Status = resp.StatusCode
+
+	// Recorded bodies are empty when body capture was disabled
+	if entry.Response.Content.Text != "" {
+		replayed := harlog.HARContent{Text: string(body)}
+		result.Body = hardiff.CompareBodies(&entry.Response.Content, &replayed, r.bodyOpts...)
+	}
+	return result
+}
+
+// newRequest rebuilds the recorded request for the target server
+func (r *Replayer) newRequest(ctx context.Context, entry *harlog.HAREntry) (*http.Request, error) {
+	msg, err := harlog.ConvertEntry(entry)
+	if err != nil {
+		return nil, err
+	}
+	req := msg.Request.WithContext(ctx)
+
+	req.URL.Scheme = r.target.Scheme
+	req.URL.Host = r.target.Host
+	if prefix := strings.TrimSuffix(r.target.Path, "/"); prefix != "" {
+		req.URL.Path = prefix + req.URL.Path
+		req.URL.RawPath = ""
+	}
+	req.Host = r.target.Host
+	req.Proto, req.ProtoMajor, req.ProtoMinor = "HTTP/1.1", 1, 1
+	req.RequestURI = ""
+
+	for name := range req.Header {
+		// HTTP/2 pseudo headers recorded by browsers are not valid field names
+		if strings.HasPrefix(name, ":") {
+			req.Header.Del(name)
+		}
+	}
+	// Recorded bodies are decoded, so the response is only comparable if
+	// http.Transport handles the encoding
+	req.Header.Del("Accept-Encoding")
+	for _, name := range []string{"Connection", "Content-Length", "Host", "Keep-Alive", "Te", "Transfer-Encoding", "Upgrade"} {
+		req.Header.Del(name)
+	}
+	for _, name := range r.removedHeaders {
+		req.Header.Del(name)
+	}
+	for name, values := range r.headers {
+		req.Header[name] = values
+	}
+
+	return req, nil
+}
@@ -0,0 +1,244 @@
+package replay
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/m-mizutani/harlog"
+)
+
+func newEntry(started, method, rawURL string, status int, body string) harlog.HAREntry {
+	return harlog.HAREntry{
+		StartedDateTime: started,
+		Time:            10,
+		Request: harlog.HARRequest{
+			Method:      method,
+			URL:         rawURL,
+			HTTPVersion: "HTTP/2.0",
+			Headers: []harlog.HARHeader{
+				{Name: ":authority", Value: "api.example.com"},
+				{Name: "Authorization", Value: "Bearer expired"},
+				{Name: "Accept-Encoding", Value: "gzip, br"},
+			},
+		},
+		Response: harlog.HARResponse{
+			Status:  status,
+			Content: harlog.HARContent{Text: body},
+		},
+	}
+}
+
+func newHAR(entries ...harlog.HAREntry) *harlog.HAR {
+	return &harlog.HAR{Log: harlog.HARLog{Version: "1.2", Entries: entries}}
+}
+
+func TestReplay(t *testing.T) {
+	var mu sync.Mutex
+	var paths, auths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.RequestURI())
+		auths = append(auths, r.Header.Get("Authorization"))
+		mu.Unlock()
+
+		switch r.URL.Path {
+		case "/v1/users/1":
+			fmt.Fprint(w, `{"id": 1, "name": "alice"}`)
+		case "/v1/users":
+			body, _ := io.ReadAll(r.Body)
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintf(w, `{"id": 2, "echo": %s}`, body)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	post := newEntry("2025-03-09T11:49:15Z", "POST", "https://api.example.com/users", 201, `{"id": "2"}`)
+	post.Request.PostData = &harlog.HARPostData{MimeType: "application/json", Text: `{"name": "bob"}`}
+	har := newHAR(
+		newEntry("2025-03-09T11:49:14Z", "GET", "https://api.example.com/users/1?fields=name", 200, `{"id": 1, "name": "alice"}`),
+		post,
+		newEntry("2025-03-09T11:49:16Z", "GET", "https://api.example.com/missing", 200, ""),
+	)
+
+	replayer, err := New(server.URL+"/v1/", WithHeader("Authorization", "Bearer fresh"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	summary, err := replayer.Replay(context.Background(), har)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if summary.Sent != 3 || summary.Errors != 0 {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+	if got := strings.Join(paths, ","); got != "/v1/users/1?fields=name,/v1/users,/v1/missing" {
+		t.Errorf("unexpected paths: %s", got)
+	}
+	for _, auth := range auths {
+		if auth != "Bearer fresh" {
+			t.Errorf("header was not overridden: %s", auth)
+		}
+	}
+
+	if summary.StatusMismatches != 1 || !summary.Results[2].StatusMismatch() || summary.Results[2].Status != 404 {
+		t.Errorf("expected status mismatch for missing entry: %+v", summary.Results[2])
+	}
+	if summary.BodyMismatches != 1 || len(summary.Results[1].Body) != 2 {
+		t.Errorf("expected body diff for POST entry: %+v", summary.Results[1])
+	}
+	if len(summary.Results[0].Body) != 0 {
+		t.Errorf("unexpected body diff: %+v", summary.Results[0].Body)
+	}
+	if summary.Latency.Max <= 0 || summary.RecordedLatency.P50 != 10*time.Millisecond {
+		t.Errorf("unexpected latency: %+v %+v", summary.Latency, summary.RecordedLatency)
+	}
+	if !summary.HasMismatches() {
+		t.Error("expected mismatches")
+	}
+
+	var buf bytes.Buffer
+	if err := summary.WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"~ GET " + server.URL + "/v1/missing (#2)\n    status: 200 -> 404\n",
+		"    body $.id: type string -> number\n",
+		"    body $.echo: added (object)\n",
+		"3 sent, 0 errors, 1 status mismatches, 1 body mismatches\n",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("report does not contain %q:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestReplay_Concurrency(t *testing.T) {
+	var inFlight, maxInFlight atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			m := maxInFlight.Load()
+			if n <= m || maxInFlight.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	var entries []harlog.HAREntry
+	for i := 0; i < 12; i++ {
+		entries = append(entries, newEntry("", "GET", fmt.Sprintf("https://api.example.com/%d", i), 200, ""))
+	}
+
+	replayer, err := New(server.URL, WithConcurrency(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	summary, err := replayer.Replay(context.Background(), newHAR(entries...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Sent != 12 || summary.HasMismatches() {
+		t.Errorf("unexpected summary: %+v", summary)
+	}
+	if n := maxInFlight.Load(); n != 3 {
+		t.Errorf("expected 3 requests in flight, got %d", n)
+	}
+}
+
+func TestReplay_Pacing(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	har := newHAR(
+		newEntry("2025-03-09T11:49:14.000Z", "GET", "https://api.example.com/a", 200, ""),
+		newEntry("2025-03-09T11:49:14.400Z", "GET", "https://api.example.com/b", 200, ""),
+		newEntry("2025-03-09T11:49:14.200Z", "GET", "https://api.example.com/c", 200, ""),
+	)
+
+	testCases := map[string]struct {
+		opt     Option
+		minimum time.Duration
+	}{
+		"original timing": {opt: WithOriginalTiming(4), minimum: 100 * time.Millisecond},
+		"fixed rate":      {opt: WithRate(20), minimum: 100 * time.Millisecond},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			replayer, err := New(server.URL, WithConcurrency(3), tc.opt)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			start := time.Now()
+			summary, err := replayer.Replay(context.Background(), har)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if elapsed := time.Since(start); elapsed < tc.minimum {
+				t.Errorf("expected replay to take at least %s, took %s", tc.minimum, elapsed)
+			}
+			if summary.Sent != 3 || summary.HasMismatches() {
+				t.Errorf("unexpected summary: %+v", summary)
+			}
+		})
+	}
+}
+
+func TestReplay_Cancel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	har := newHAR(
+		newEntry("2025-03-09T11:49:14Z", "GET", "https://api.example.com/a", 200, ""),
+		newEntry("2025-03-09T12:49:14Z", "GET", "https://api.example.com/b", 200, ""),
+	)
+
+	replayer, err := New(server.URL, WithOriginalTiming(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	summary, err := replayer.Replay(ctx, har)
+	if err != context.DeadlineExceeded {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+	if summary.Sent != 1 || summary.Results[0].Index != 0 {
+		t.Errorf("unexpected summary: %+v", summary)
+	}
+}
+
+func TestNew_InvalidTarget(t *testing.T) {
+	if _, err := New("localhost:8080"); err == nil {
+		t.Error("expected error for target without scheme")
+	}
+}
+
+func TestNewPercentiles(t *testing.T) {
+	var durations []time.Duration
+	for i := 100; i >= 1; i-- {
+		durations = append(durations, time.Duration(i)*time.Millisecond)
+	}
+
+	p := newPercentiles(durations)
+	if p.P50 != 50*time.Millisecond || p.P90 != 90*time.Millisecond || p.P99 != 99*time.Millisecond || p.Max != 100*time.Millisecond {
+		t.Errorf("unexpected percentiles: %+v", p)
+	}
+}
@@ -0,0 +1,158 @@
+package replay
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/m-mizutani/harlog/hardiff"
+)
+
+// Result represents the outcome of a single replayed request
+type Result struct {
+	// Index is the index of the entry in the replayed HAR log
+	Index            int                  `json:"index"`
+	Method           string               `json:"method"`
+	URL              string               `json:"url"`
+	Status           int                  `json:"status"`
+	RecordedStatus   int                  `json:"recordedStatus"`
+	Duration         time.Duration        `json:"duration"`
+	RecordedDuration time.Duration        `json:"recordedDuration"`
+	Body             []hardiff.BodyChange `json:"body,omitempty"`
+	Error            string               `json:"error,omitempty"`
+}
+
+// StatusMismatch reports whether the status differs from the recorded one
+func (r *Result) StatusMismatch() bool {
+	return r.Error == "" && r.Status != r.RecordedStatus
+}
+
+// Percentiles summarizes a latency distribution
+type Percentiles struct {
+	P50 time.Duration `json:"p50"`
+	P90 time.Duration `json:"p90"`
+	P95 time.Duration `json:"p95"`
+	P99 time.Duration `json:"p99"`
+	Max time.Duration `json:"max"`
+}
+
+// Summary represents the results of a replay
+type Summary struct {
+	// Results holds a result for each entry in the order of the HAR log.
+	// Entries not sent because the replay was canceled are omitted.
+	Results          []Result    `json:"results"`
+	Sent             int         `json:"sent"`
+	Errors           int         `json:"errors"`
+	StatusMismatches int         `json:"statusMismatches"`
+	BodyMismatches   int         `json:"bodyMismatches"`
+	Latency          Percentiles `json:"latency"`
+	RecordedLatency  Percentiles `json:"recordedLatency"`
+}
+
+// HasMismatches reports whether any request failed or returned a status
+// different from the recorded one
+func (s *Summary) HasMismatches() bool {
+	return s.Errors > 0 || s.StatusMismatches > 0
+}
+
+func newSummary(results []*Result) *Summary {
+	s := &Summary{Results: []Result{}}
+	var latencies, recorded []time.Duration
+	for _, result := range results {
+		if result == nil {
+			continue
+		}
+		s.Results = append(s.Results, *result)
+		s.Sent++
+
+		switch {
+		case result.Error != "":
+			s.Errors++
+			continue
+		case result.StatusMismatch():
+			s.StatusMismatches++
+		}
+		if len(result.Body) > 0 {
+			s.BodyMismatches++
+		}
+		latencies = append(latencies, result.Duration)
+		recorded = append(recorded, result.RecordedDuration)
+	}
+
+	s.Latency = newPercentiles(latencies)
+	s.RecordedLatency = newPercentiles(recorded)
+	return s
+}
+
+// newPercentiles computes percentiles with the nearest-rank method
+func newPercentiles(durations []time.Duration) Percentiles {
+	if len(durations) == 0 {
+		return Percentiles{}
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+
+	rank := func(p float64) time.Duration {
+		i := int(math.Ceil(p/100*float64(len(durations)))) - 1
+		return durations[max(i, 0)]
+	}
+	return Percentiles{
+		P50: rank(50),
+		P90: rank(90),
+		P95: rank(95),
+		P99: rank(99),
+		Max: durations[len(durations)-1],
+	}
+}
+
+// WriteText writes a human readable report listing failed and mismatching
+// requests, followed by latency percentiles and a summary line
+func (s *Summary) WriteText(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	for _, r := range s.Results {
+		switch {
+		case r.Error != "":
+			fmt.Fprintf(bw, "! %s %s (#%d): %s\n", r.Method, r.URL, r.Index, r.Error)
+			continue
+		case r.StatusMismatch():
+			fmt.Fprintf(bw, "~ %s %s (#%d)\n    status: %d -> %d\n", r.Method, r.URL, r.Index, r.RecordedStatus, r.Status)
+		case len(r.Body) > 0:
+			fmt.Fprintf(bw, "~ %s %s (#%d)\n", r.Method, r.URL, r.Index)
+		default:
+			continue
+		}
+		for _, b := range r.Body {
+			switch b.Kind {
+			case hardiff.KindAdded:
+				fmt.Fprintf(bw, "    body %s: added (%s)\n", b.Path, b.Target)
+			case hardiff.KindRemoved:
+				fmt.Fprintf(bw, "    body %s: removed (%s)\n", b.Path, b.Base)
+			case hardiff.KindType:
+				fmt.Fprintf(bw, "    body %s: type %s -> %s\n", b.Path, b.Base, b.Target)
+			default:
+				if b.Base == "" && b.Target == "" {
+					fmt.Fprintf(bw, "    body %s: changed\n", b.Path)
+				} else {
+					fmt.Fprintf(bw, "    body %s: %s -> %s\n", b.Path, b.Base, b.Target)
+				}
+			}
+		}
+	}
+
+	writePercentiles(bw, "latency", s.Latency)
+	writePercentiles(bw, "recorded", s.RecordedLatency)
+	fmt.Fprintf(bw, "%d sent, %d errors, %d status mismatches, %d body mismatches\n",
+		s.Sent, s.Errors, s.StatusMismatches, s.BodyMismatches)
+	return bw.Flush()
+}
+
+func writePercentiles(w io.Writer, label string, p Percentiles) {
+	round := func(d time.Duration) time.Duration {
+		return d.Round(100 * time.Microsecond)
+	}
+	fmt.Fprintf(w, "%-9s p50=%s p90=%s p95=%s p99=%s max=%s\n",
+		label+":", round(p.P50), round(p.P90), round(p.P95), round(p.P99), round(p.Max))
+}
@@ -0,0 +1,174 @@
+package harlog
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+)
+
+// RetentionPolicy limits the HAR files kept in the output directory. Zero
+// values disable the corresponding limit.
+type RetentionPolicy struct {
+	// MaxAge is the maximum age of a file by its modification time
+	MaxAge time.Duration
+	// MaxBytes is the maximum total size of the files
+	MaxBytes int64
+	// MaxFiles is the maximum number of files
+	MaxFiles int
+	// Interval is how often the policy is enforced (default: 1 minute)
+	Interval time.Duration
+}
+
+// WithRetention enforces policy on the output directory in the background,
+// deleting the oldest files first, until Close is called. Only files written
+// by the Logger are deleted, as well as files at the top level of the output
+// directory named by the default file name generator, such as those left by
+// previous runs, unless WithFileNameFn is used.
+func WithRetention(policy RetentionPolicy) Option {
+	return func(l *Logger) {
+		l.retention = &policy
+	}
+}
+
+// defaultFileName matches the names generated by defaultFileNameFn,
+// optionally with the extension of a compression
+var defaultFileName = regexp.MustCompile(`^\d{8}-\d{6}\.\d{3}-[0-9a-f]{8}-.*\.har(\.gz|\.zst)?$`)
+
+// startRetention runs the retention policy until l.done is closed
+func (l *Logger) startRetention() {
+	interval := l.retention.Interval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+
+	l.wg.Add(1)
+	go func() {
+		defer l.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			l.enforceRetention(time.Now())
+			select {
+			case <-l.done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+type retainedFile struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// enforceRetention deletes files of the output directory that exceed the
+// retention policy at now, oldest first
+func (l *Logger) enforceRetention(now time.Time) {
+	files, err := l.retainedFiles()
+	if err != nil {
+		l.logger.Error("failed to list HAR files for retention", "error", err, "dir", l.outputDir)
+		return
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modTime.Before(files[j].modTime)
+	})
+
+	var total int64
+	for _, f := range files {
+		total += f.size
+	}
+	count := len(files)
+
+	policy := l.retention
+	for _, f := range files {
+		var reason string
+		switch {
+		case policy.MaxAge > 0 && now.Sub(f.modTime) > policy.MaxAge:
+			reason = "max age"
+		case policy.MaxFiles > 0 && count > policy.MaxFiles:
+			reason = "max files"
+		case policy.MaxBytes > 0 && total > policy.MaxBytes:
+			reason = "max bytes"
+		default:
+			// Files are sorted by age, so the rest are within the policy
+			return
+		}
+
+		if err := os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+			l.logger.Error("failed to delete HAR file", "error", err, "path", f.path)
+			continue
+		}
+		l.mu.Lock()
+		delete(l.written, f.path)
+		l.mu.Unlock()
+
+		count--
+		total -= f.size
+		l.logger.Info("deleted HAR file by retention policy",
+			"path", f.path,
+			"reason", reason,
+			"size", f.size,
+			"modified", f.modTime,
+		)
+	}
+}
+
+// retainedFiles returns the files subject to the retention policy: those
+// written by the Logger and those at the top level of the output directory
+// named as by defaultFileNameFn
+func (l *Logger) retainedFiles() ([]retainedFile, error) {
+	dir, err := filepath.Abs(l.outputDir)
+	if err != nil {
+		return nil, err
+	}
+
+	l.mu.Lock()
+	candidates := make(map[string]bool, len(l.written))
+	for path := range l.written {
+		candidates[path] = true
+	}
+	l.mu.Unlock()
+
+	if !l.customFileName {
+		entries, err := os.ReadDir(dir)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if entry.Type().IsRegular() && defaultFileName.MatchString(entry.Name()) {
+				candidates[filepath.Join(dir, entry.Name())] = true
+			}
+		}
+	}
+
+	var files []retainedFile
+	var gone []string
+	for path := range candidates {
+		info, err := os.Lstat(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				gone = append(gone, path)
+				continue
+			}
+			return nil, err
+		}
+		if !info.Mode().IsRegular() {
+			continue
+		}
+		files = append(files, retainedFile{path: path, size: info.Size(), modTime: info.ModTime()})
+	}
+
+	// Forget files deleted by others
+	l.mu.Lock()
+	for _, path := range gone {
+		delete(l.written, path)
+	}
+	l.mu.Unlock()
+
+	return files, nil
+}
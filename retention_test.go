@@ -0,0 +1,204 @@
+package harlog
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRetention(t *testing.T) {
+	now := time.Now()
+
+	// Files named as by defaultFileNameFn, oldest first
+	names := []string{
+		"20250301-000000.000-0000000a-GET-example.com-a.har",
+		"20250302-000000.000-0000000b-GET-example.com-b.har.gz",
+		"20250303-000000.000-0000000c-GET-example.com-c.har",
+		"20250304-000000.000-0000000d-GET-example.com-d.har.zst",
+	}
+
+	testCases := []struct {
+		name   string
+		policy RetentionPolicy
+		want   []string
+	}{
+		{name: "max age", policy: RetentionPolicy{MaxAge: 150 * time.Minute}, want: names[2:]},
+		{name: "max files", policy: RetentionPolicy{MaxFiles: 1}, want: names[3:]},
+		{name: "max bytes", policy: RetentionPolicy{MaxBytes: 250}, want: names[2:]},
+		{name: "no limit", policy: RetentionPolicy{}, want: names},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			var logs bytes.Buffer
+			logger := New(
+				WithOutputDir(tmpDir),
+				WithLogger(slog.New(slog.NewTextHandler(&logs, nil))),
+				WithRetention(RetentionPolicy{Interval: time.Hour}),
+			)
+			// Stop the background run to enforce the policy under test
+			if err := logger.Close(); err != nil {
+				t.Fatal(err)
+			}
+			logger.retention = &tc.policy
+
+			for i, name := range names {
+				path := filepath.Join(tmpDir, name)
+				if err := os.WriteFile(path, bytes.Repeat([]byte("x"), 100), 0600); err != nil {
+					t.Fatal(err)
+				}
+				modTime := now.Add(-time.Duration(len(names)-i) * time.Hour)
+				if err := os.Chtimes(path, modTime, modTime); err != nil {
+					t.Fatal(err)
+				}
+			}
+			// Files not created by harlog are kept regardless of their age
+			for _, name := range []string{"notes.txt", "custom.har"} {
+				path := filepath.Join(tmpDir, name)
+				if err := os.WriteFile(path, nil, 0600); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.Chtimes(path, time.Unix(0, 0), time.Unix(0, 0)); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			logger.enforceRetention(now)
+
+			want := append([]string{"custom.har", "notes.txt"}, tc.want...)
+			sort.Strings(want)
+			if got := listFiles(t, tmpDir); strings.Join(got, ",") != strings.Join(want, ",") {
+				t.Errorf("expected %v, got %v", want, got)
+			}
+			if deleted := len(names) - len(tc.want); strings.Count(logs.String(), "deleted HAR file") != deleted {
+				t.Errorf("expected %d deletions logged:\n%s", deleted, logs.String())
+			}
+		})
+	}
+}
+
+func TestRetention_OwnFilesOnly(t *testing.T) {
+	name := "20250301-000000.000-0000000a-GET-example.com-a.har"
+	for _, tc := range []struct {
+		name string
+		opts []Option
+		want []string
+	}{
+		{name: "default names", want: []string{"sub"}},
+		{
+			name: "custom names",
+			opts: []Option{WithFileNameFn(func(req *http.Request) string { return "custom.har" })},
+			want: []string{name, "sub"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			logger := New(append(tc.opts,
+				WithOutputDir(tmpDir),
+				WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))),
+				WithRetention(RetentionPolicy{Interval: time.Hour}),
+			)...)
+			if err := logger.Close(); err != nil {
+				t.Fatal(err)
+			}
+			logger.retention = &RetentionPolicy{MaxAge: time.Minute}
+
+			// Subdirectories of the output directory are left alone
+			nested := filepath.Join(tmpDir, "sub", name)
+			for _, path := range []string{filepath.Join(tmpDir, name), nested} {
+				if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, nil, 0600); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.Chtimes(path, time.Unix(0, 0), time.Unix(0, 0)); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			logger.enforceRetention(time.Now())
+
+			if got := listFiles(t, tmpDir); strings.Join(got, ",") != strings.Join(tc.want, ",") {
+				t.Errorf("expected %v, got %v", tc.want, got)
+			}
+			if _, err := os.Stat(nested); err != nil {
+				t.Errorf("expected nested file to be kept: %v", err)
+			}
+		})
+	}
+}
+
+func TestRetention_Background(t *testing.T) {
+	tmpDir := t.TempDir()
+	var logs bytes.Buffer
+	var n int
+	logger := New(
+		WithOutputDir(tmpDir),
+		WithLogger(slog.New(slog.NewTextHandler(&logs, nil))),
+		// Custom names are deleted because the Logger wrote them
+		WithFileNameFn(func(req *http.Request) string {
+			n++
+			return filepath.Join(tmpDir, fmt.Sprintf("custom-%d.har", n))
+		}),
+		WithRetention(RetentionPolicy{MaxFiles: 2, Interval: 10 * time.Millisecond}),
+	)
+	defer logger.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: logger}
+	for i := 0; i < 5; i++ {
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(listFiles(t, tmpDir)) > 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("retention was not enforced: %v", listFiles(t, tmpDir))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := logger.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// Close is idempotent
+	if err := logger.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(logs.String(), "reason=\"max files\"") {
+		t.Errorf("deletions were not logged:\n%s", logs.String())
+	}
+}
+
+func listFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names
+}
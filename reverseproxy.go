@@ -0,0 +1,77 @@
+package harlog
+
+import (
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+
+	"github.com/google/uuid"
+)
+
+// ReverseProxy is an httputil.ReverseProxy that records both the request
+// received from the client and the rewritten request sent to the target.
+// The two entries share a Link with the same ID and the roles LinkInbound
+// and LinkOutbound.
+//
+// Responses are streamed to the client while they are recorded, so the
+// outbound entry is written once the response body has been relayed. Set
+// FlushInterval of the embedded ReverseProxy to control flushing; responses
+// without a known length, such as server-sent events, are flushed
+// immediately.
+type ReverseProxy struct {
+	*httputil.ReverseProxy
+	logger  *Logger
+	handler http.Handler
+}
+
+// NewReverseProxy creates a ReverseProxy forwarding requests to target and
+// recording them with a Logger configured by opts. Requests are sent through
+// the transport set by WithTransport (default: http.DefaultTransport).
+func NewReverseProxy(target *url.URL, opts ...Option) *ReverseProxy {
+	logger := New(opts...)
+	p := &ReverseProxy{logger: logger}
+	p.ReverseProxy = &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(target)
+			pr.SetXForwarded()
+			decodeByTransport(pr.Out.Header)
+		},
+		Transport: &recordingTransport{logger: logger},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			logger.logger.Error("failed to forward reverse proxy request",
+				"error", err,
+				"method", r.Method,
+				"url", r.URL.String(),
+			)
+			w.WriteHeader(http.StatusBadGateway)
+		},
+	}
+	p.handler = logger.Middleware(p.ReverseProxy)
+	return p
+}
+
+// Logger returns the Logger recording the traffic
+func (p *ReverseProxy) Logger() *Logger {
+	return p.logger
+}
+
+// Close stops background work of the Logger
+func (p *ReverseProxy) Close() error {
+	return p.logger.Close()
+}
+
+// ServeHTTP implements http.Handler
+func (p *ReverseProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r = r.WithContext(withLinkID(r.Context(), uuid.New().String()))
+	p.handler.ServeHTTP(w, r)
+}
+
+// recordingTransport records requests sent by ReverseProxy without buffering
+// their responses
+type recordingTransport struct {
+	logger *Logger
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.logger.roundTrip(req, t.logger.transport, reverseRoundTrip)
+}
@@ -0,0 +1,162 @@
+package harlog
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func waitLinked(t *testing.T, rec *Recorder, role string) HAREntry {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	entry, err := rec.WaitFor(ctx, func(entry *HAREntry) bool {
+		return entry.Link != nil && entry.Link.Role == role
+	})
+	if err != nil {
+		t.Fatalf("no %s entry: %v", role, err)
+	}
+	return entry
+}
+
+func TestReverseProxy(t *testing.T) {
+	t.Parallel()
+
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprintf(w, "%s %s %s", r.Method, r.URL.RequestURI(), body)
+	}))
+	defer backend.Close()
+
+	target, _ := url.Parse(backend.URL + "/base")
+	rec := NewRecorder()
+	proxy := NewReverseProxy(target, WithFileOutput(false), WithSink(rec))
+	defer proxy.Close()
+	front := httptest.NewServer(proxy)
+	defer front.Close()
+
+	resp, err := http.Post(front.URL+"/users?id=1", "application/json", strings.NewReader(`{"name": "alice"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != `POST /base/users?id=1 {"name": "alice"}` {
+		t.Errorf("unexpected response: %q", body)
+	}
+
+	inbound := waitLinked(t, rec, LinkInbound)
+	outbound := waitLinked(t, rec, LinkOutbound)
+	if inbound.Link.ID == "" || inbound.Link.ID != outbound.Link.ID {
+		t.Errorf("expected entries with the same link ID, got %q and %q", inbound.Link.ID, outbound.Link.ID)
+	}
+
+	if inbound.Request.URL != "/users?id=1" {
+		t.Errorf("expected inbound URL /users?id=1, got %s", inbound.Request.URL)
+	}
+	if outbound.Request.URL != backend.URL+"/base/users?id=1" {
+		t.Errorf("expected outbound URL %s/base/users?id=1, got %s", backend.URL, outbound.Request.URL)
+	}
+	forwarded := false
+	for _, h := range outbound.Request.Headers {
+		forwarded = forwarded || h.Name == "X-Forwarded-For"
+	}
+	if !forwarded {
+		t.Errorf("expected X-Forwarded-For in outbound request: %+v", outbound.Request.Headers)
+	}
+	for _, entry := range []HAREntry{inbound, outbound} {
+		if entry.Request.PostData == nil || entry.Request.PostData.Text != `{"name": "alice"}` {
+			t.Errorf("unexpected %s request body: %+v", entry.Link.Role, entry.Request.PostData)
+		}
+		if entry.Response.Content.Text != string(body) {
+			t.Errorf("unexpected %s response body: %q", entry.Link.Role, entry.Response.Content.Text)
+		}
+	}
+}
+
+func TestReverseProxy_Streaming(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: 1\n\n")
+		w.(http.Flusher).Flush()
+		<-release
+		fmt.Fprint(w, "data: 2\n\n")
+	}))
+	defer backend.Close()
+
+	target, _ := url.Parse(backend.URL)
+	rec := NewRecorder()
+	proxy := NewReverseProxy(target, WithFileOutput(false), WithSink(rec))
+	front := httptest.NewServer(proxy)
+	defer front.Close()
+
+	resp, err := http.Get(front.URL + "/events")
+	if err != nil {
+		close(release)
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	// The first event arrives before the backend finishes the response
+	r := bufio.NewReader(resp.Body)
+	line, err := r.ReadString('\n')
+	close(release)
+	if err != nil || line != "data: 1\n" {
+		t.Fatalf("expected first event, got %q (%v)", line, err)
+	}
+	if rec.Len() != 0 {
+		t.Errorf("expected no entry before the response ends, got %d", rec.Len())
+	}
+	if _, err := io.ReadAll(r); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, role := range []string{LinkInbound, LinkOutbound} {
+		entry := waitLinked(t, rec, role)
+		if len(entry.EventStream) != 2 || entry.EventStream[0].Data != "1" || entry.EventStream[1].Data != "2" {
+			t.Errorf("unexpected %s events: %+v", role, entry.EventStream)
+		}
+	}
+}
+
+func TestReverseProxy_Unreachable(t *testing.T) {
+	t.Parallel()
+
+	backend := httptest.NewServer(http.NotFoundHandler())
+	target, _ := url.Parse(backend.URL)
+	backend.Close()
+
+	rec := NewRecorder()
+	proxy := NewReverseProxy(target, WithFileOutput(false), WithSink(rec), WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))))
+	front := httptest.NewServer(proxy)
+	defer front.Close()
+
+	resp, err := http.Get(front.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadGateway {
+		t.Errorf("expected status 502, got %d", resp.StatusCode)
+	}
+
+	inbound := waitLinked(t, rec, LinkInbound)
+	if inbound.Response.Status != http.StatusBadGateway {
+		t.Errorf("expected recorded status 502, got %d", inbound.Response.Status)
+	}
+	if rec.Len() != 1 {
+		t.Errorf("expected only the inbound entry, got %d entries", rec.Len())
+	}
+}
@@ -0,0 +1,214 @@
+package harlog
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// SlogOption represents a configuration option for SlogSink
+type SlogOption func(*slogConfig)
+
+// WithSlogMessage sets the message of records (default: "http")
+func WithSlogMessage(msg string) SlogOption {
+	return func(c *slogConfig) {
+		c.message = msg
+	}
+}
+
+// WithSlogGroup sets the group that holds the attributes of an entry
+// (default: "http"). An empty name puts them at the top level.
+func WithSlogGroup(name string) SlogOption {
+	return func(c *slogConfig) {
+		c.group = name
+	}
+}
+
+// WithSlogHeaders sets the request and response headers included in records.
+// Headers are omitted by default.
+func WithSlogHeaders(names ...string) SlogOption {
+	return func(c *slogConfig) {
+		c.headers = names
+	}
+}
+
+// WithSlogBodyLimit sets the maximum number of bytes of request and response
+// bodies included in records (default: 1024). Longer bodies are truncated and
+// zero or a negative value omits bodies.
+func WithSlogBodyLimit(n int) SlogOption {
+	return func(c *slogConfig) {
+		c.bodyLimit = n
+	}
+}
+
+// WithSlogLevel sets the function that chooses the level of a record from the
+// response status. Status 0 means no response was received. The default
+// logs 5xx and status 0 at Error, 4xx at Warn and others at Info.
+func WithSlogLevel(fn func(status int) slog.Level) SlogOption {
+	return func(c *slogConfig) {
+		c.level = fn
+	}
+}
+
+type slogConfig struct {
+	message   string
+	group     string
+	headers   []string
+	bodyLimit int
+	level     func(status int) slog.Level
+}
+
+// SlogSink is a Sink that emits each HAR entry as a structured slog record
+// with method, URL, status, durations, sizes, selected headers and truncated
+// bodies
+type SlogSink struct {
+	logger *slog.Logger
+	cfg    slogConfig
+}
+
+// NewSlogSink creates a SlogSink writing to logger
+func NewSlogSink(logger *slog.Logger, opts ...SlogOption) *SlogSink {
+	s := &SlogSink{
+		logger: logger,
+		cfg: slogConfig{
+			message:   "http",
+			group:     "http",
+			bodyLimit: 1024,
+			level:     defaultSlogLevel,
+		},
+	}
+	for _, opt := range opts {
+		opt(&s.cfg)
+	}
+	return s
+}
+
+// WithSlogOutput emits every recorded entry to the Logger's slog.Logger (see
+// WithLogger) through a SlogSink. Combine it with WithFileOutput(false) to
+// log entries instead of writing HAR files.
+func WithSlogOutput(opts ...SlogOption) Option {
+	return func(l *Logger) {
+		l.slogOutput = true
+		l.slogOptions = opts
+	}
+}
+
+func defaultSlogLevel(status int) slog.Level {
+	switch {
+	case status == 0 || status >= 500:
+		return slog.LevelError
+	case status >= 400:
+		return slog.LevelWarn
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// WriteEntry implements Sink
+func (s *SlogSink) WriteEntry(req *http.Request, entry *HAREntry) error {
+	ctx := context.Background()
+	if req != nil {
+		ctx = req.Context()
+	}
+
+	level := s.cfg.level(entry.Response.Status)
+	if !s.logger.Enabled(ctx, level) {
+		return nil
+	}
+
+	attrs := s.attrs(entry)
+	if s.cfg.group != "" {
+		attrs = []slog.Attr{{Key: s.cfg.group, Value: slog.GroupValue(attrs...)}}
+	}
+	s.logger.LogAttrs(ctx, level, s.cfg.message, attrs...)
+	return nil
+}
+
+func (s *SlogSink) attrs(entry *HAREntry) []slog.Attr {
+	attrs := []slog.Attr{
+		slog.String("method", entry.Request.Method),
+		slog.String("url", entry.Request.URL),
+		slog.Int("status", entry.Response.Status),
+		slog.Duration("duration", msDuration(entry.Time)),
+		slog.Group("timings",
+			slog.Duration("send", msDuration(entry.Timings.Send)),
+			slog.Duration("wait", msDuration(entry.Timings.Wait)),
+			slog.Duration("receive", msDuration(entry.Timings.Receive)),
+		),
+		slog.Int("request_size", entry.Request.BodySize),
+		slog.Int("response_size", entry.Response.Content.Size),
+	}
+	if entry.Comment != "" {
+		attrs = append(attrs, slog.String("comment", entry.Comment))
+	}
+	if len(entry.Tags) > 0 {
+		keys := make([]string, 0, len(entry.Tags))
+		for key := range entry.Tags {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		tags := make([]any, 0, len(keys))
+		for _, key := range keys {
+			tags = append(tags, slog.String(key, entry.Tags[key]))
+		}
+		attrs = append(attrs, slog.Group("tags", tags...))
+	}
+
+	if len(s.cfg.headers) > 0 {
+		if headers := s.headerAttrs(entry.Request.Headers); len(headers) > 0 {
+			attrs = append(attrs, slog.Group("request_headers", headers...))
+		}
+		if headers := s.headerAttrs(entry.Response.Headers); len(headers) > 0 {
+			attrs = append(attrs, slog.Group("response_headers", headers...))
+		}
+	}
+
+	if s.cfg.bodyLimit > 0 {
+		if entry.Request.PostData != nil && entry.Request.PostData.Text != "" {
+			attrs = append(attrs, slog.String("request_body", truncate(entry.Request.PostData.Text, s.cfg.bodyLimit)))
+		}
+		if entry.Response.Content.Text != "" {
+			attrs = append(attrs, slog.String("response_body", truncate(entry.Response.Content.Text, s.cfg.bodyLimit)))
+		}
+	}
+
+	return attrs
+}
+
+// headerAttrs returns the selected headers; values of repeated headers are
+// joined with ", "
+func (s *SlogSink) headerAttrs(headers []HARHeader) []any {
+	var attrs []any
+	for _, name := range s.cfg.headers {
+		var values []string
+		for _, h := range headers {
+			if strings.EqualFold(h.Name, name) {
+				values = append(values, h.Value)
+			}
+		}
+		if len(values) > 0 {
+			attrs = append(attrs, slog.String(http.CanonicalHeaderKey(name), strings.Join(values, ", ")))
+		}
+	}
+	return attrs
+}
+
+func msDuration(ms float64) time.Duration {
+	return time.Duration(ms * float64(time.Millisecond))
+}
+
+// truncate cuts s to at most n bytes without splitting a UTF-8 sequence and
+// marks the cut with "..."
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n] + "..."
+}
@@ -0,0 +1,153 @@
+package harlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSlogSink(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := t.TempDir()
+	var buf bytes.Buffer
+	logger := New(
+		WithOutputDir(tmpDir),
+		WithFileOutput(false),
+		WithLogger(slog.New(slog.NewJSONHandler(&buf, nil))),
+		WithSlogOutput(
+			WithSlogHeaders("content-type", "X-Missing"),
+			WithSlogBodyLimit(8),
+		),
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id": 1, "name": "alice"}`))
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: logger}
+	for _, path := range []string{"/users", "/fail", "/skipped"} {
+		req, err := http.NewRequest(http.MethodPost, server.URL+path, strings.NewReader("name=alice"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if path == "/skipped" {
+			req = req.WithContext(Skip(req.Context()))
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+
+	var records []map[string]any
+	decoder := json.NewDecoder(&buf)
+	for decoder.More() {
+		var record map[string]any
+		if err := decoder.Decode(&record); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %d: %v", len(records), records)
+	}
+
+	ok := records[0]
+	if ok["level"] != "INFO" || ok["msg"] != "http" {
+		t.Errorf("unexpected record: %v", ok)
+	}
+	attrs, _ := ok["http"].(map[string]any)
+	if attrs["method"] != "POST" || attrs["url"] != server.URL+"/users" || attrs["status"] != float64(200) {
+		t.Errorf("unexpected attributes: %v", attrs)
+	}
+	if attrs["request_body"] != "name=ali..." || attrs["response_body"] != `{"id": 1...` {
+		t.Errorf("bodies were not truncated: %v", attrs)
+	}
+	if attrs["response_size"] != float64(26) {
+		t.Errorf("expected response size 26, got %v", attrs["response_size"])
+	}
+	headers, _ := attrs["response_headers"].(map[string]any)
+	if len(headers) != 1 || headers["Content-Type"] != "application/json" {
+		t.Errorf("unexpected headers: %v", headers)
+	}
+	if _, ok := attrs["timings"].(map[string]any); !ok {
+		t.Errorf("expected timings group: %v", attrs)
+	}
+
+	if records[1]["level"] != "ERROR" {
+		t.Errorf("expected 5xx at ERROR level, got %v", records[1]["level"])
+	}
+
+	if files, _ := os.ReadDir(tmpDir); len(files) != 0 {
+		t.Errorf("expected no HAR files, got %d", len(files))
+	}
+}
+
+func TestSlogSink_Options(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewSlogSink(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelWarn})),
+		WithSlogGroup(""),
+		WithSlogMessage("request"),
+		WithSlogBodyLimit(0),
+		WithSlogLevel(func(status int) slog.Level {
+			if status == http.StatusNotFound {
+				return slog.LevelInfo
+			}
+			return slog.LevelWarn
+		}),
+	)
+
+	entry := &HAREntry{
+		Request:  HARRequest{Method: "GET", URL: "https://example.com/"},
+		Response: HARResponse{Status: 404, Content: HARContent{Text: "not found"}},
+		Tags:     map[string]string{"env": "test"},
+	}
+	if err := sink.WriteEntry(httptest.NewRequest("GET", "/", nil), entry); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("expected record below the handler level to be dropped, got %s", buf.String())
+	}
+
+	entry.Response.Status = 200
+	if err := sink.WriteEntry(httptest.NewRequest("GET", "/", nil), entry); err != nil {
+		t.Fatal(err)
+	}
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatal(err)
+	}
+	if record["msg"] != "request" || record["level"] != "WARN" || record["method"] != "GET" {
+		t.Errorf("unexpected record: %v", record)
+	}
+	if _, ok := record["response_body"]; ok {
+		t.Errorf("expected body to be omitted: %v", record)
+	}
+	if tags, _ := record["tags"].(map[string]any); tags["env"] != "test" {
+		t.Errorf("unexpected tags: %v", record["tags"])
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	if got := truncate("héllo", 2); got != "h..." {
+		t.Errorf("expected cut before multi-byte rune, got %q", got)
+	}
+	if got := truncate("hello", 5); got != "hello" {
+		t.Errorf("expected no cut, got %q", got)
+	}
+}
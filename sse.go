@@ -0,0 +1,258 @@
+package harlog
+
+import (
+	"bytes"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WithEventStreamCheckpoint saves the entries of server-sent event streams
+// every interval while the stream is open, so that long-lived streams reach
+// the disk before they end (default: 0, disabled). Checkpoints overwrite the
+// HAR file of the entry and are not passed to sinks.
+func WithEventStreamCheckpoint(interval time.Duration) Option {
+	return func(l *Logger) {
+		l.eventStreamCheckpoint = interval
+	}
+}
+
+const (
+	defaultEventStreamEventSize = 64 * 1024
+	defaultEventStreamEvents    = 10000
+	// maxEventFieldName bounds the part of a line before the data that is
+	// kept while the line is parsed
+	maxEventFieldName = 16
+)
+
+// WithEventStreamEventSize sets the maximum number of bytes of data recorded
+// for each server-sent event (default: 64 KiB). Longer data is truncated,
+// and zero or a negative size records no data.
+func WithEventStreamEventSize(size int) Option {
+	return func(l *Logger) {
+		l.eventStreamEventSize = size
+	}
+}
+
+// WithEventStreamMaxEvents sets the maximum number of server-sent events
+// recorded for a stream (default: 10000). Later events are relayed but not
+// recorded, and counted in the custom _eventStreamDropped field.
+func WithEventStreamMaxEvents(n int) Option {
+	return func(l *Logger) {
+		l.eventStreamMaxEvents = n
+	}
+}
+
+// isEventStream reports whether h describes a text/event-stream body
+func isEventStream(h http.Header) bool {
+	mediaType, _, err := mime.ParseMediaType(h.Get("Content-Type"))
+	return err == nil && mediaType == "text/event-stream"
+}
+
+// eventStream parses server-sent events from a response body as it is
+// written. It is safe for concurrent use.
+type eventStream struct {
+	maxSize   int
+	maxEvents int
+
+	mu      sync.Mutex
+	events  []HARServerSentEvent
+	dropped int
+	size    int
+
+	line    []byte
+	started bool
+	lastCR  bool
+	id      string
+	event   string
+	retry   int
+	data    strings.Builder
+	hasData bool
+
+	stop     chan struct{}
+	stopped  chan struct{}
+	stopOnce sync.Once
+}
+
+func (l *Logger) newEventStream() *eventStream {
+	return &eventStream{
+		maxSize:   l.eventStreamEventSize,
+		maxEvents: l.eventStreamMaxEvents,
+		stop:      make(chan struct{}),
+		stopped:   make(chan struct{}),
+	}
+}
+
+// Write parses b, which may end in the middle of a line
+func (s *eventStream) Write(b []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.size += len(b)
+	for _, c := range b {
+		// Lines end with CRLF, LF or CR
+		if s.lastCR {
+			s.lastCR = false
+			if c == '\n' {
+				continue
+			}
+		}
+		switch c {
+		case '\r':
+			s.lastCR = true
+			s.processLine()
+		case '\n':
+			s.processLine()
+		default:
+			// Data beyond the limit is dropped as the line is read
+			if len(s.line) < maxEventFieldName+max(s.maxSize, 0) {
+				s.line = append(s.line, c)
+			}
+		}
+	}
+	return len(b), nil
+}
+
+func (s *eventStream) processLine() {
+	line := s.line
+	s.line = s.line[:0]
+	if !s.started {
+		s.started = true
+		line = bytes.TrimPrefix(line, []byte("\xef\xbb\xbf"))
+	}
+
+	if len(line) == 0 {
+		s.dispatch()
+		return
+	}
+	if line[0] == ':' {
+		// Comment
+		return
+	}
+
+	field, value := string(line), ""
+	if i := bytes.IndexByte(line, ':'); i >= 0 {
+		field = string(line[:i])
+		value = strings.TrimPrefix(string(line[i+1:]), " ")
+	}
+	switch field {
+	case "data":
+		if s.hasData {
+			s.writeData("\n")
+		}
+		s.writeData(value)
+		s.hasData = true
+	case "event":
+		s.event = value
+	case "id":
+		if !strings.ContainsRune(value, 0) {
+			s.id = value
+		}
+	case "retry":
+		if n, err := strconv.Atoi(value); err == nil && strings.Trim(value, "0123456789") == "" {
+			s.retry = n
+		}
+	}
+}
+
+// writeData adds data to the event, up to the maximum size
+func (s *eventStream) writeData(data string) {
+	if room := s.maxSize - s.data.Len(); room < len(data) {
+		data = data[:max(room, 0)]
+	}
+	s.data.WriteString(data)
+}
+
+// dispatch records the event made of the fields since the last blank line
+func (s *eventStream) dispatch() {
+	if s.hasData || s.event != "" || s.id != "" || s.retry != 0 {
+		if len(s.events) < s.maxEvents {
+			s.events = append(s.events, HARServerSentEvent{
+				Time:  float64(time.Now().UnixMicro()) / 1e6,
+				ID:    s.id,
+				Event: s.event,
+				Data:  s.data.String(),
+				Retry: s.retry,
+			})
+		} else {
+			s.dropped++
+		}
+	}
+	s.id, s.event, s.retry = "", "", 0
+	s.data.Reset()
+	s.hasData = false
+}
+
+// apply stores the events parsed so far in entry, whose response is
+// recorded without text
+func (s *eventStream) apply(entry *HAREntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry.EventStream = slices.Clone(s.events)
+	entry.EventStreamDropped = s.dropped
+	entry.Response.Content.Size = s.size
+	entry.Response.Content.Text = ""
+	entry.Response.BodySize = s.size
+}
+
+// startCheckpoints calls fn every interval until close. It does nothing if
+// interval is not positive.
+func (s *eventStream) startCheckpoints(interval time.Duration, fn func()) {
+	if interval <= 0 {
+		close(s.stopped)
+		return
+	}
+	go func() {
+		defer close(s.stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				fn()
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+// close stops checkpoints and waits for a running one to finish
+func (s *eventStream) close() {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+	<-s.stopped
+}
+
+// recordEventStream records resp, a server-sent event stream, as its body is
+// read by the caller. The entry is saved once the body has been read to the
+// end or closed, and at checkpoints until then.
+func (l *Logger) recordEventStream(req *http.Request, resp *http.Response, ctrl *captureControl, entry *HAREntry, start time.Time) {
+	entry.Response = captureResponseHeader(resp)
+	stream := l.newEventStream()
+
+	var path string
+	stream.startCheckpoints(l.eventStreamCheckpoint, func() {
+		snapshot := *entry
+		stream.apply(&snapshot)
+		snapshot.Time = float64(time.Since(start).Milliseconds())
+		path = l.checkpointEntry(req, l.outboundEntry(ctrl, &snapshot), path)
+	})
+
+	resp.Body = &recordingBody{
+		ReadCloser: resp.Body,
+		w:          stream,
+		done: func(int) {
+			stream.close()
+			stream.apply(entry)
+			entry.Response.Trailers = convertHeaders(resp.Trailer)
+			entry.Time = float64(time.Since(start).Milliseconds())
+			l.writeEntryTo(req, l.outboundEntry(ctrl, entry), path)
+		},
+	}
+}
@@ -0,0 +1,197 @@
+package harlog
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEventStreamParser(t *testing.T) {
+	stream := New(WithFileOutput(false)).newEventStream()
+	input := "\xef\xbb\xbf: comment\r\n" +
+		"id: 1\r\n" +
+		"event: greeting\r\n" +
+		"data: hello\r\n" +
+		"data:  world\r\n\r\n" +
+		"data\rretry: 3000\r\r" +
+		"id: a\x00b\n" +
+		"retry: 1a\n" +
+		"data: third\n\n" +
+		"id: 4\n\n" +
+		"data: incomplete\n"
+
+	// Feed one byte at a time to cover lines split across writes
+	for i := 0; i < len(input); i++ {
+		_, _ = stream.Write([]byte{input[i]})
+	}
+
+	want := []HARServerSentEvent{
+		{ID: "1", Event: "greeting", Data: "hello\n world"},
+		{Data: "", Retry: 3000},
+		{Data: "third"},
+		{ID: "4"},
+	}
+	if len(stream.events) != len(want) {
+		t.Fatalf("expected %d events, got %d: %+v", len(want), len(stream.events), stream.events)
+	}
+	for i, event := range stream.events {
+		if event.ID != want[i].ID || event.Event != want[i].Event || event.Data != want[i].Data || event.Retry != want[i].Retry {
+			t.Errorf("event %d: expected %+v, got %+v", i, want[i], event)
+		}
+		if event.Time == 0 {
+			t.Errorf("event %d: expected time", i)
+		}
+	}
+	if stream.size != len(input) {
+		t.Errorf("expected size %d, got %d", len(input), stream.size)
+	}
+}
+
+func TestEventStreamLimits(t *testing.T) {
+	stream := New(WithFileOutput(false), WithEventStreamEventSize(4), WithEventStreamMaxEvents(2)).newEventStream()
+	_, _ = io.WriteString(stream, "data: first\n\ndata: ab\ndata: cd\n\ndata: third\n\ndata: fourth\n\n")
+
+	if len(stream.events) != 2 {
+		t.Fatalf("expected 2 events, got %+v", stream.events)
+	}
+	if stream.events[0].Data != "firs" || stream.events[1].Data != "ab\nc" {
+		t.Errorf("expected truncated data, got %+v", stream.events)
+	}
+
+	var entry HAREntry
+	stream.apply(&entry)
+	if entry.EventStreamDropped != 2 {
+		t.Errorf("expected 2 dropped events, got %d", entry.EventStreamDropped)
+	}
+}
+
+// eventStreamHandler sends an event, waits for release and sends another
+func eventStreamHandler(release <-chan struct{}) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+		fmt.Fprint(w, "id: 1\ndata: first\n\n")
+		w.(http.Flusher).Flush()
+		<-release
+		fmt.Fprint(w, "id: 2\nevent: done\ndata: second\n\n")
+	})
+}
+
+func checkEvents(t *testing.T, side string, entry HAREntry) {
+	t.Helper()
+	if len(entry.EventStream) != 2 {
+		t.Fatalf("%s: expected 2 events, got %+v", side, entry.EventStream)
+	}
+	first, second := entry.EventStream[0], entry.EventStream[1]
+	if first.ID != "1" || first.Data != "first" || second.ID != "2" || second.Event != "done" || second.Data != "second" {
+		t.Errorf("%s: unexpected events: %+v", side, entry.EventStream)
+	}
+	if first.Time > second.Time {
+		t.Errorf("%s: expected events in order of time: %+v", side, entry.EventStream)
+	}
+	if entry.Response.Content.Text != "" {
+		t.Errorf("%s: expected no content text, got %q", side, entry.Response.Content.Text)
+	}
+	if size := len("id: 1\ndata: first\n\nid: 2\nevent: done\ndata: second\n\n"); entry.Response.Content.Size != size {
+		t.Errorf("%s: expected content size %d, got %d", side, size, entry.Response.Content.Size)
+	}
+}
+
+func TestEventStream_Client(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	server := httptest.NewServer(eventStreamHandler(release))
+	defer server.Close()
+
+	rec := NewRecorder()
+	client := &http.Client{Transport: New(WithFileOutput(false), WithSink(rec))}
+
+	// The response is returned while the stream is open
+	resp, err := client.Get(server.URL + "/events")
+	if err != nil {
+		close(release)
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	br := bufio.NewReader(resp.Body)
+	line, err := br.ReadString('\n')
+	close(release)
+	if err != nil || line != "id: 1\n" {
+		t.Fatalf("expected first event, got %q (%v)", line, err)
+	}
+	if _, err := io.ReadAll(br); err != nil {
+		t.Fatal(err)
+	}
+
+	entry, ok := rec.Last()
+	if !ok {
+		t.Fatal("expected entry after the stream ended")
+	}
+	checkEvents(t, "client", entry)
+}
+
+func TestEventStream_MiddlewareCheckpoint(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	release := make(chan struct{})
+	rec := NewRecorder()
+	logger := New(WithOutputDir(dir), WithSink(rec), WithEventStreamCheckpoint(10*time.Millisecond))
+	server := httptest.NewServer(logger.Middleware(eventStreamHandler(release)))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/events")
+	if err != nil {
+		close(release)
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	// A checkpoint saves the first event while the stream is open
+	var checkpoint *HAR
+	for deadline := time.Now().Add(5 * time.Second); checkpoint == nil && time.Now().Before(deadline); {
+		files, _ := filepath.Glob(filepath.Join(dir, "*.har"))
+		if len(files) == 1 {
+			checkpoint, _ = ReadHARFile(files[0])
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	close(release)
+	if checkpoint == nil || len(checkpoint.Log.Entries) != 1 {
+		t.Fatalf("expected checkpoint file, got %+v", checkpoint)
+	}
+	if events := checkpoint.Log.Entries[0].EventStream; len(events) != 1 || events[0].Data != "first" {
+		t.Errorf("expected first event in checkpoint, got %+v", events)
+	}
+	if rec.Len() != 0 {
+		t.Errorf("expected checkpoints not to reach sinks, got %d entries", rec.Len())
+	}
+
+	if _, err := io.ReadAll(resp.Body); err != nil {
+		t.Fatal(err)
+	}
+	waitEntry(t, rec, http.MethodGet, "/events")
+
+	// The complete entry overwrites the checkpoint
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("expected a single file, got %d", len(files))
+	}
+	har, err := ReadHARFile(filepath.Join(dir, files[0].Name()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkEvents(t, "server", har.Log.Entries[0])
+	if rec.Len() != 1 {
+		t.Errorf("expected 1 entry in sink, got %d", rec.Len())
+	}
+}
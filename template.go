@@ -0,0 +1,76 @@
+package harlog
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	numericSegment = regexp.MustCompile(`^[0-9]+$`)
+	uuidSegment    = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	hexSegment     = regexp.MustCompile(`^[0-9a-fA-F]{16,}$`)
+)
+
+// IsIDSegment reports whether a URL path segment looks like an identifier:
+// a number, a UUID or a hexadecimal string of at least 16 characters
+func IsIDSegment(segment string) bool {
+	return numericSegment.MatchString(segment) ||
+		uuidSegment.MatchString(segment) ||
+		hexSegment.MatchString(segment)
+}
+
+// PathTemplate replaces identifier segments of a URL path with parameters
+// named after the preceding segment, e.g. "/users/42/posts/7" becomes
+// "/users/{userId}/posts/{postId}"
+func PathTemplate(path string) string {
+	segments := strings.Split(path, "/")
+	used := make(map[string]int)
+
+	for i, segment := range segments {
+		if !IsIDSegment(segment) {
+			continue
+		}
+
+		name := "id"
+		if i > 0 && segments[i-1] != "" && !strings.HasPrefix(segments[i-1], "{") {
+			name = paramName(segments[i-1])
+		}
+		used[name]++
+		if n := used[name]; n > 1 {
+			name += strconv.Itoa(n)
+		}
+		segments[i] = "{" + name + "}"
+	}
+
+	return strings.Join(segments, "/")
+}
+
+// paramName derives a camel case parameter name such as "userId" from a
+// collection segment such as "users"
+func paramName(segment string) string {
+	var words []string
+	for _, w := range strings.FieldsFunc(segment, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9')
+	}) {
+		words = append(words, strings.ToLower(w))
+	}
+	if len(words) == 0 {
+		return "id"
+	}
+
+	last := words[len(words)-1]
+	switch {
+	case strings.HasSuffix(last, "ies"):
+		last = strings.TrimSuffix(last, "ies") + "y"
+	case strings.HasSuffix(last, "s") && !strings.HasSuffix(last, "ss"):
+		last = strings.TrimSuffix(last, "s")
+	}
+	words[len(words)-1] = last
+
+	name := words[0]
+	for _, w := range words[1:] {
+		name += strings.ToUpper(w[:1]) + w[1:]
+	}
+	return name + "Id"
+}
@@ -0,0 +1,26 @@
+package harlog
+
+import "testing"
+
+func TestPathTemplate(t *testing.T) {
+	testCases := []struct {
+		path string
+		want string
+	}{
+		{"/users", "/users"},
+		{"/users/42", "/users/{userId}"},
+		{"/users/42/posts/7", "/users/{userId}/posts/{postId}"},
+		{"/categories/3", "/categories/{categoryId}"},
+		{"/orders/0b5a5d5e-2d0f-4b6e-9f0c-1c2d3e4f5a6b/items", "/orders/{orderId}/items"},
+		{"/commits/3f2a9c1d4e5b6a7f8091", "/commits/{commitId}"},
+		{"/1/2", "/{id}/{id2}"},
+		{"/api-keys/12", "/api-keys/{apiKeyId}"},
+		{"/v1/status", "/v1/status"},
+	}
+
+	for _, tc := range testCases {
+		if got := PathTemplate(tc.path); got != tc.want {
+			t.Errorf("PathTemplate(%q): expected %s, got %s", tc.path, tc.want, got)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package harlog
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// captureTLS records the state of the connection a request or response was
+// sent over, or returns nil if the connection was not encrypted
+func captureTLS(cs *tls.ConnectionState) *HARTLS {
+	if cs == nil {
+		return nil
+	}
+	t := &HARTLS{
+		Version:     tls.VersionName(cs.Version),
+		CipherSuite: tls.CipherSuiteName(cs.CipherSuite),
+		ALPN:        cs.NegotiatedProtocol,
+		ServerName:  cs.ServerName,
+		Resumed:     cs.DidResume,
+	}
+	for _, cert := range cs.PeerCertificates {
+		t.PeerCertificates = append(t.PeerCertificates, summarizeCertificate(cert))
+	}
+	return t
+}
+
+// summarizeCertificate records the facts of cert needed to identify it
+func summarizeCertificate(cert *x509.Certificate) HARCertificate {
+	sum := sha256.Sum256(cert.Raw)
+	c := HARCertificate{
+		Subject:           cert.Subject.String(),
+		Issuer:            cert.Issuer.String(),
+		NotBefore:         cert.NotBefore.UTC().Format(time.RFC3339),
+		NotAfter:          cert.NotAfter.UTC().Format(time.RFC3339),
+		SHA256Fingerprint: hex.EncodeToString(sum[:]),
+	}
+	c.SANs = append(c.SANs, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		c.SANs = append(c.SANs, ip.String())
+	}
+	c.SANs = append(c.SANs, cert.EmailAddresses...)
+	for _, uri := range cert.URIs {
+		c.SANs = append(c.SANs, uri.String())
+	}
+	return c
+}
+
+// ConnectionState returns the recorded state of the connection. Peer
+// certificates are only available as summaries in PeerCertificates of t.
+func (t *HARTLS) ConnectionState() *tls.ConnectionState {
+	return &tls.ConnectionState{
+		Version:            tlsVersion(t.Version),
+		HandshakeComplete:  true,
+		DidResume:          t.Resumed,
+		CipherSuite:        cipherSuite(t.CipherSuite),
+		NegotiatedProtocol: t.ALPN,
+		ServerName:         t.ServerName,
+	}
+}
+
+// tlsVersion returns the version named name by tls.VersionName
+func tlsVersion(name string) uint16 {
+	for _, v := range []uint16{tls.VersionTLS10, tls.VersionTLS11, tls.VersionTLS12, tls.VersionTLS13} {
+		if tls.VersionName(v) == name {
+			return v
+		}
+	}
+	return parseHexID(name)
+}
+
+// cipherSuite returns the ID of the cipher suite named name by
+// tls.CipherSuiteName
+func cipherSuite(name string) uint16 {
+	for _, suites := range [][]*tls.CipherSuite{tls.CipherSuites(), tls.InsecureCipherSuites()} {
+		for _, s := range suites {
+			if s.Name == name {
+				return s.ID
+			}
+		}
+	}
+	return parseHexID(name)
+}
+
+// parseHexID parses the names such as 0x1301 given to unknown IDs
+func parseHexID(name string) uint16 {
+	id, err := strconv.ParseUint(strings.TrimPrefix(name, "0x"), 16, 16)
+	if err != nil || !strings.HasPrefix(name, "0x") {
+		return 0
+	}
+	return uint16(id)
+}
@@ -0,0 +1,102 @@
+package harlog
+
+import (
+	"crypto/tls"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTLS(t *testing.T) {
+	t.Parallel()
+
+	serverRec, clientRec := NewRecorder(), NewRecorder()
+	serverLogger := New(WithFileOutput(false), WithSink(serverRec))
+	server := httptest.NewUnstartedServer(serverLogger.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "ok")
+	})))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	// New connections resume the session of the first one
+	transport := server.Client().Transport.(*http.Transport).Clone()
+	transport.TLSClientConfig.ClientSessionCache = tls.NewLRUClientSessionCache(1)
+	transport.TLSClientConfig.ServerName = "example.com"
+	transport.DisableKeepAlives = true
+	client := &http.Client{Transport: New(WithFileOutput(false), WithSink(clientRec), WithTransport(transport))}
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+
+	entries := clientRec.Entries()
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 client entries, got %d", len(entries))
+	}
+	first := entries[0].TLS
+	if first == nil {
+		t.Fatal("expected TLS details")
+	}
+	if first.Version != "TLS 1.3" || !strings.HasPrefix(first.CipherSuite, "TLS_") || first.ALPN != "h2" || first.ServerName != "example.com" || first.Resumed {
+		t.Errorf("unexpected TLS details: %+v", first)
+	}
+	if len(first.PeerCertificates) != 1 {
+		t.Fatalf("expected the server certificate, got %+v", first.PeerCertificates)
+	}
+	cert := first.PeerCertificates[0]
+	if !strings.Contains(cert.Subject, "Acme Co") || !strings.Contains(strings.Join(cert.SANs, ","), "127.0.0.1") ||
+		len(cert.SHA256Fingerprint) != 64 || cert.NotAfter == "" {
+		t.Errorf("unexpected certificate summary: %+v", cert)
+	}
+	if !entries[1].TLS.Resumed {
+		t.Errorf("expected resumed session, got %+v", entries[1].TLS)
+	}
+
+	entry := waitEntry(t, serverRec, http.MethodGet, "/")
+	if entry.TLS == nil || entry.TLS.Version != "TLS 1.3" || entry.TLS.ServerName != "example.com" || len(entry.TLS.PeerCertificates) != 0 {
+		t.Errorf("unexpected server TLS details: %+v", entry.TLS)
+	}
+
+	// The parser surfaces the details
+	msg, err := ConvertEntry(&entries[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := msg.Response.TLS
+	if state == nil || state.Version != tls.VersionTLS13 || tls.CipherSuiteName(state.CipherSuite) != first.CipherSuite ||
+		state.NegotiatedProtocol != "h2" || state.ServerName != "example.com" {
+		t.Errorf("unexpected connection state: %+v", state)
+	}
+	if msg.TLS == nil || len(msg.TLS.PeerCertificates) != 1 {
+		t.Errorf("expected certificate summaries, got %+v", msg.TLS)
+	}
+
+	// Plain connections have no TLS details
+	plain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer plain.Close()
+	resp, err := client.Get(plain.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if last, _ := clientRec.Last(); last.TLS != nil {
+		t.Errorf("expected no TLS details, got %+v", last.TLS)
+	}
+}
+
+func TestHARTLS_ConnectionState(t *testing.T) {
+	state := (&HARTLS{Version: "0x0305", CipherSuite: "TLS_RSA_WITH_RC4_128_SHA"}).ConnectionState()
+	if state.Version != 0x0305 || state.CipherSuite != tls.TLS_RSA_WITH_RC4_128_SHA {
+		t.Errorf("unexpected connection state: %+v", state)
+	}
+	if state := (&HARTLS{Version: "unknown"}).ConnectionState(); state.Version != 0 {
+		t.Errorf("expected unknown version, got %x", state.Version)
+	}
+}
@@ -4,57 +4,180 @@ import (
 	"bytes"
 	"io"
 	"net/http"
+	"sync"
 	"time"
 )
 
 // RoundTrip implements http.RoundTripper
 func (l *Logger) RoundTrip(req *http.Request) (*http.Response, error) {
+	return l.roundTrip(req, l.transport, clientRoundTrip)
+}
+
+// roundTripKind tells roundTrip on behalf of whom a request is sent
+type roundTripKind int
+
+const (
+	// clientRoundTrip sends a request of an http.Client using the Logger,
+	// whose redirects are recorded as chains
+	clientRoundTrip roundTripKind = iota
+	// forwardRoundTrip sends a request received by a Proxy
+	forwardRoundTrip
+	// reverseRoundTrip sends a request received by a reverse proxy. The
+	// response body is passed through as it is read instead of being
+	// buffered, and the entry is saved once the body has been consumed.
+	reverseRoundTrip
+)
+
+// roundTrip sends req through transport and records the exchange
+func (l *Logger) roundTrip(req *http.Request, transport http.RoundTripper, kind roundTripKind) (*http.Response, error) {
+	ctrl := controlFrom(req.Context())
+	if !l.shouldCapture(ctrl) {
+		return transport.RoundTrip(req)
+	}
+	withBody := l.shouldCaptureBody(ctrl)
+	req = l.withoutWebSocketExtensions(req)
+
 	start := time.Now()
 	harEntry := &HAREntry{
 		StartedDateTime: start.Format(time.RFC3339),
 	}
 
 	// Record request
-	harEntry.Request = l.captureRequest(req)
+	harEntry.Request = l.captureRequest(req, withBody)
 
 	// Execute the actual request
-	resp, err := l.transport.RoundTrip(req)
+	req = traceInformational(req)
+	resp, err := transport.RoundTrip(req)
 	if err != nil {
 		return nil, err
 	}
+	harEntry.TLS = captureTLS(resp.TLS)
+	// Proxied clients follow redirects with requests of their own
+	if kind == clientRoundTrip {
+		req = followRedirects(req, resp)
+		harEntry.Redirect = redirectHopFrom(req.Context()).har()
+	}
+	if resp.StatusCode == http.StatusSwitchingProtocols {
+		l.recordUpgrade(req, resp, ctrl, harEntry, start)
+		return resp, nil
+	}
+	// Reading an event stream to the end would block until it is closed
+	if isEventStream(resp.Header) {
+		l.recordEventStream(req, resp, ctrl, harEntry, start)
+		return resp, nil
+	}
 
 	// Record response
-	body, err := l.captureResponseWithBody(resp)
-	if err != nil {
-		return nil, err
+	if kind == reverseRoundTrip {
+		l.recordStreamedResponse(req, resp, ctrl, harEntry, start, withBody)
+		markRedirect(req, resp)
+		return resp, nil
+	}
+	if withBody {
+		body, err := l.captureResponseWithBody(resp)
+		if err != nil {
+			return nil, err
+		}
+		harEntry.Response = body
+	} else {
+		harEntry.Response = captureResponseHeader(resp)
 	}
 
-	harEntry.Response = body
 	harEntry.Time = float64(time.Since(start).Milliseconds())
+	l.writeEntry(req, l.outboundEntry(ctrl, harEntry))
+	markRedirect(req, resp)
+
+	return resp, nil
+}
 
-	// Save HAR entry
-	if err := l.saveHAR(req, harEntry); err != nil {
-		l.logger.Error("failed to save HAR",
-			"error", err,
-			"path", req.URL.Path,
-			"method", req.Method,
-			"host", req.Host,
-		)
+// recordUpgrade records resp, a 101 response to req, without consuming its
+// body, which is the upgraded connection. For WebSocket connections, the
+// entry is saved with the messages once the connection is closed.
+func (l *Logger) recordUpgrade(req *http.Request, resp *http.Response, ctrl *captureControl, entry *HAREntry, start time.Time) {
+	entry.Response = captureResponseHeader(resp)
+	entry.Response.Content.Size = 0
+	entry.Response.BodySize = 0
+	entry.Time = float64(time.Since(start).Milliseconds())
+
+	recorded := l.recordWebSocket(req, resp, func(messages []HARWebSocketMessage, dropped int) {
+		entry.WebSocketMessages, entry.WebSocketMessagesDropped = messages, dropped
+		l.writeEntry(req, l.outboundEntry(ctrl, entry))
+	})
+	if !recorded {
+		l.writeEntry(req, l.outboundEntry(ctrl, entry))
 	}
+}
 
-	return resp, nil
+// recordStreamedResponse records resp, a response to req, as its body is read
+// and saves the entry once the body has been read to the end or closed
+func (l *Logger) recordStreamedResponse(req *http.Request, resp *http.Response, ctrl *captureControl, entry *HAREntry, start time.Time, withBody bool) {
+	entry.Response = captureResponseHeader(resp)
+
+	var body bytes.Buffer
+	recorded := &recordingBody{
+		ReadCloser: resp.Body,
+		done: func(size int) {
+			entry.Response.Content.Size = size
+			entry.Response.Content.Text = body.String()
+			entry.Response.BodySize = size
+			entry.Response.Trailers = convertHeaders(resp.Trailer)
+			entry.Time = float64(time.Since(start).Milliseconds())
+			l.writeEntry(req, l.outboundEntry(ctrl, entry))
+		},
+	}
+	if withBody {
+		recorded.w = &body
+	}
+	resp.Body = recorded
 }
 
-func (l *Logger) captureResponseWithBody(resp *http.Response) (HARResponse, error) {
-	headers := make([]HARHeader, 0)
-	for name, values := range resp.Header {
-		for _, value := range values {
-			headers = append(headers, HARHeader{
-				Name:  name,
-				Value: value,
-			})
-		}
+// outboundEntry applies the per-request settings to entry, recorded for an
+// outgoing request, and returns it
+func (l *Logger) outboundEntry(ctrl *captureControl, entry *HAREntry) *HAREntry {
+	l.applyControl(ctrl, entry)
+	if entry.Link != nil {
+		entry.Link.Role = LinkOutbound
 	}
+	return entry
+}
+
+// recordingBody passes a response body through while copying it to w, if
+// not nil, and calls done with the size once the body has been read to the
+// end or closed
+type recordingBody struct {
+	io.ReadCloser
+	w    io.Writer
+	size int
+	once sync.Once
+	done func(size int)
+}
+
+func (b *recordingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.size += n
+	if b.w != nil {
+		_, _ = b.w.Write(p[:n])
+	}
+	if err != nil {
+		b.finish()
+	}
+	return n, err
+}
+
+func (b *recordingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.finish()
+	return err
+}
+
+func (b *recordingBody) finish() {
+	b.once.Do(func() {
+		b.done(b.size)
+	})
+}
+
+func (l *Logger) captureResponseWithBody(resp *http.Response) (HARResponse, error) {
+	headers := convertHeaders(resp.Header)
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
@@ -77,7 +200,34 @@ func (l *Logger) captureResponseWithBody(resp *http.Response) (HARResponse, erro
 			MimeType: resp.Header.Get("Content-Type"),
 			Text:     string(body),
 		},
+		RedirectURL: resp.Header.Get("Location"),
 		HeadersSize: -1, // Not implemented
 		BodySize:    len(body),
+		// Trailers are received with the end of the body
+		Trailers:      convertHeaders(resp.Trailer),
+		Informational: informationalFor(resp),
 	}, nil
 }
+
+// captureResponseHeader records the response without consuming its body
+func captureResponseHeader(resp *http.Response) HARResponse {
+	size := int(resp.ContentLength)
+	if size < 0 {
+		size = -1
+	}
+
+	return HARResponse{
+		Status:      resp.StatusCode,
+		StatusText:  resp.Status,
+		HTTPVersion: resp.Proto,
+		Headers:     convertHeaders(resp.Header),
+		Content: HARContent{
+			Size:     size,
+			MimeType: resp.Header.Get("Content-Type"),
+		},
+		RedirectURL:   resp.Header.Get("Location"),
+		HeadersSize:   -1, // Not implemented
+		BodySize:      size,
+		Informational: informationalFor(resp),
+	}
+}
@@ -1,7 +1,9 @@
 package harlog
 
 import (
+	"encoding/json"
 	"net/http"
+	"time"
 )
 
 // HAR represents the root of a HAR log
@@ -11,9 +13,16 @@ type HAR struct {
 
 // HARLog represents the main content of a HAR file
 type HARLog struct {
-	Version string     `json:"version"`
-	Creator HARCreator `json:"creator"`
-	Entries []HAREntry `json:"entries"`
+	Version string      `json:"version"`
+	Creator HARCreator  `json:"creator"`
+	Browser *HARCreator `json:"browser,omitempty"`
+	Pages   []HARPage   `json:"pages,omitempty"`
+	Entries []HAREntry  `json:"entries"`
+	Comment string      `json:"comment,omitempty"`
+
+	// Creators lists the creators of the source logs when logs written by
+	// different tools are merged
+	Creators []HARCreator `json:"_creators,omitempty"`
 }
 
 // HARCreator represents the creator of the HAR file
@@ -22,14 +31,170 @@ type HARCreator struct {
 	Version string `json:"version"`
 }
 
+// HARPage represents a page that groups entries
+type HARPage struct {
+	StartedDateTime string         `json:"startedDateTime"`
+	ID              string         `json:"id"`
+	Title           string         `json:"title"`
+	PageTimings     HARPageTimings `json:"pageTimings"`
+	Comment         string         `json:"comment,omitempty"`
+}
+
+// HARPageTimings represents timings of page load events
+type HARPageTimings struct {
+	OnContentLoad float64 `json:"onContentLoad,omitempty"`
+	OnLoad        float64 `json:"onLoad,omitempty"`
+}
+
 // HAREntry represents a single HTTP request/response pair
 type HAREntry struct {
+	Pageref         string      `json:"pageref,omitempty"`
 	StartedDateTime string      `json:"startedDateTime"`
 	Time            float64     `json:"time"`
 	Request         HARRequest  `json:"request"`
 	Response        HARResponse `json:"response"`
 	Cache           HARCache    `json:"cache"`
 	Timings         HARTimings  `json:"timings"`
+	Comment         string      `json:"comment,omitempty"`
+
+	// Tags holds user-defined key/value pairs attached via WithTag
+	Tags map[string]string `json:"_tags,omitempty"`
+
+	// Tunnel describes a CONNECT tunnel relayed by Proxy without decryption
+	Tunnel *HARTunnel `json:"_tunnel,omitempty"`
+
+	// Link relates the entries recorded by ReverseProxy for one exchange
+	Link *HARLink `json:"_link,omitempty"`
+
+	// WebSocketMessages holds the messages exchanged over a WebSocket
+	// connection established by the request, in the format of Chrome
+	WebSocketMessages []HARWebSocketMessage `json:"_webSocketMessages,omitempty"`
+	// WebSocketMessagesDropped counts the messages beyond the limit set by
+	// WithWebSocketMaxMessages, which are not recorded
+	WebSocketMessagesDropped int `json:"_webSocketMessagesDropped,omitempty"`
+
+	// EventStream holds the events of a text/event-stream response, whose
+	// content text is left empty
+	EventStream []HARServerSentEvent `json:"_eventStream,omitempty"`
+	// EventStreamDropped counts the events beyond the limit set by
+	// WithEventStreamMaxEvents, which are not recorded
+	EventStreamDropped int `json:"_eventStreamDropped,omitempty"`
+
+	// GRPC describes the gRPC call of the entry, filled in by processors such
+	// as the one of package hargrpc
+	GRPC *HARGRPC `json:"_grpc,omitempty"`
+
+	// GraphQL describes the GraphQL operation of the request
+	GraphQL *HARGraphQL `json:"_graphql,omitempty"`
+
+	// TLS describes the TLS connection the exchange was made over
+	TLS *HARTLS `json:"_tls,omitempty"`
+
+	// Redirect places the entry in a chain of redirects followed by a client
+	Redirect *HARRedirect `json:"_redirect,omitempty"`
+}
+
+// HARRedirect represents a hop of a redirect chain. The entries of a chain
+// share ChainID, and Hop is 0 for the request that was redirected first.
+type HARRedirect struct {
+	ChainID string `json:"chainId"`
+	Hop     int    `json:"hop"`
+}
+
+// HARTLS represents the state of a TLS connection. Version and CipherSuite
+// are named as by tls.VersionName and tls.CipherSuiteName.
+type HARTLS struct {
+	Version     string `json:"version"`
+	CipherSuite string `json:"cipherSuite"`
+	// ALPN is the protocol negotiated with ALPN, e.g. h2
+	ALPN string `json:"alpn,omitempty"`
+	// ServerName is the name sent by the client with SNI
+	ServerName string `json:"serverName,omitempty"`
+	Resumed    bool   `json:"resumed"`
+	// PeerCertificates summarizes the certificate chain of the peer, leaf
+	// first: the server's for requests sent and the client's for requests
+	// received
+	PeerCertificates []HARCertificate `json:"peerCertificates,omitempty"`
+}
+
+// HARCertificate summarizes an X.509 certificate. SANs lists the DNS names,
+// IP addresses, email addresses and URIs of the certificate.
+type HARCertificate struct {
+	Subject           string   `json:"subject"`
+	Issuer            string   `json:"issuer"`
+	SANs              []string `json:"sans,omitempty"`
+	NotBefore         string   `json:"notBefore"`
+	NotAfter          string   `json:"notAfter"`
+	SHA256Fingerprint string   `json:"sha256Fingerprint"`
+}
+
+// HARGraphQL represents a GraphQL operation. OperationType is empty for
+// persisted queries sent without the document.
+type HARGraphQL struct {
+	OperationName string          `json:"operationName,omitempty"`
+	OperationType string          `json:"operationType,omitempty"`
+	Variables     json.RawMessage `json:"variables,omitempty"`
+	// Errors is the number of errors in the response, which servers may send
+	// with status 200
+	Errors int `json:"errors,omitempty"`
+}
+
+// HARGRPC represents a gRPC call. Code is meaningful only if Status, the
+// name of the code such as OK or NOT_FOUND, is set.
+type HARGRPC struct {
+	Service string `json:"service"`
+	Method  string `json:"method"`
+	// Protocol is grpc, grpc-web or connect
+	Protocol string `json:"protocol"`
+	Code     int    `json:"code"`
+	Status   string `json:"status,omitempty"`
+	Message  string `json:"message,omitempty"`
+}
+
+// HARServerSentEvent represents an event of a server-sent event stream
+type HARServerSentEvent struct {
+	// Time is the Unix time in seconds when the event was complete
+	Time  float64 `json:"time"`
+	ID    string  `json:"id,omitempty"`
+	Event string  `json:"event,omitempty"`
+	Data  string  `json:"data"`
+	Retry int     `json:"retry,omitempty"`
+}
+
+// HARWebSocketMessage represents a WebSocket message. Data of text messages
+// is the text itself and data of other messages is base64 encoded.
+type HARWebSocketMessage struct {
+	// Type is WebSocketSend or WebSocketReceive
+	Type string `json:"type"`
+	// Time is the Unix time in seconds when the message was complete
+	Time   float64 `json:"time"`
+	Opcode int     `json:"opcode"`
+	Data   string  `json:"data"`
+}
+
+// HARTunnel represents a CONNECT tunnel. The bytes relayed in each direction
+// are counted but not recorded.
+type HARTunnel struct {
+	// Target is the host and port the tunnel was opened to
+	Target        string `json:"target"`
+	BytesSent     int64  `json:"bytesSent"`
+	BytesReceived int64  `json:"bytesReceived"`
+}
+
+// Roles of linked entries
+const (
+	// LinkInbound marks the request received by the recorder
+	LinkInbound = "inbound"
+	// LinkOutbound marks the request sent by the recorder
+	LinkOutbound = "outbound"
+)
+
+// HARLink represents the relation between an entry and the other entries
+// with the same ID, e.g. the request received by ReverseProxy and the
+// rewritten request sent to the target
+type HARLink struct {
+	ID   string `json:"id"`
+	Role string `json:"role"`
 }
 
 // HARRequest represents an HTTP request
@@ -51,8 +216,23 @@ type HARResponse struct {
 	HTTPVersion string      `json:"httpVersion"`
 	Headers     []HARHeader `json:"headers"`
 	Content     HARContent  `json:"content"`
-	HeadersSize int         `json:"headersSize"`
-	BodySize    int         `json:"bodySize"`
+	// RedirectURL is the target of the Location header
+	RedirectURL string `json:"redirectURL"`
+	HeadersSize int    `json:"headersSize"`
+	BodySize    int    `json:"bodySize"`
+
+	// Trailers holds the trailer fields sent after the body
+	Trailers []HARHeader `json:"_trailers,omitempty"`
+	// Informational holds the 1xx responses sent before this one
+	Informational []HARInformational `json:"_informational,omitempty"`
+}
+
+// HARInformational represents an informational (1xx) response, such as
+// 100 Continue or 103 Early Hints
+type HARInformational struct {
+	Status     int         `json:"status"`
+	StatusText string      `json:"statusText"`
+	Headers    []HARHeader `json:"headers"`
 }
 
 // HARHeader represents an HTTP header
@@ -96,6 +276,23 @@ type HARTimings struct {
 type HTTPMessage struct {
 	Request  *http.Request
 	Response *http.Response
+
+	// WebSocketMessages holds the messages exchanged after a WebSocket
+	// handshake
+	WebSocketMessages []WebSocketMessage
+
+	// TLS holds the recorded TLS details including the peer certificates.
+	// The state is also set to the TLS field of Request and Response.
+	TLS *HARTLS
+}
+
+// WebSocketMessage represents a WebSocket message with decoded data
+type WebSocketMessage struct {
+	// Type is WebSocketSend or WebSocketReceive
+	Type   string
+	Time   time.Time
+	Opcode int
+	Data   []byte
 }
 
 // HTTPMessages represents a collection of HTTP messages
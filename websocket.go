@@ -0,0 +1,493 @@
+package harlog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Directions of WebSocket messages, from the point of view of the client as
+// in Chrome DevTools
+const (
+	// WebSocketSend marks a message sent by the client
+	WebSocketSend = "send"
+	// WebSocketReceive marks a message received by the client
+	WebSocketReceive = "receive"
+)
+
+// WebSocket opcodes
+const (
+	WebSocketOpText   = 1
+	WebSocketOpBinary = 2
+	WebSocketOpClose  = 8
+	WebSocketOpPing   = 9
+	WebSocketOpPong   = 10
+)
+
+const (
+	defaultWebSocketMessageSize = 64 * 1024
+	defaultWebSocketMessages    = 10000
+	// maxHandshakeSize limits the response head read from a hijacked
+	// connection
+	maxHandshakeSize = 64 * 1024
+)
+
+// WithWebSocketMessageSize sets the maximum number of bytes recorded for
+// each WebSocket message (default: 64 KiB). Longer data is truncated, and
+// zero or a negative size records no data.
+func WithWebSocketMessageSize(size int) Option {
+	return func(l *Logger) {
+		l.wsMessageSize = size
+	}
+}
+
+// WithWebSocketMaxMessages sets the maximum number of WebSocket messages
+// recorded for a connection (default: 10000). Later messages are relayed but
+// not recorded, and counted in the custom _webSocketMessagesDropped field.
+func WithWebSocketMaxMessages(n int) Option {
+	return func(l *Logger) {
+		l.wsMaxMessages = n
+	}
+}
+
+// WithWebSocketExtensions lets recorded WebSocket handshakes negotiate
+// extensions such as permessage-deflate (default: false). By default, offers
+// of extensions are removed from the handshakes of captured requests so that
+// messages can be read. When enabled, the messages of connections that
+// negotiate an extension are not recorded, only the handshake.
+func WithWebSocketExtensions(enabled bool) Option {
+	return func(l *Logger) {
+		l.wsExtensions = enabled
+	}
+}
+
+// isWebSocketUpgrade reports whether r is a WebSocket handshake request
+func isWebSocketUpgrade(r *http.Request) bool {
+	return headerContainsToken(r.Header, "Connection", "upgrade") &&
+		headerContainsToken(r.Header, "Upgrade", "websocket")
+}
+
+func headerContainsToken(h http.Header, name, token string) bool {
+	for _, value := range h.Values(name) {
+		for _, t := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// wsRecorder collects the messages exchanged over a WebSocket connection
+type wsRecorder struct {
+	maxSize     int
+	maxMessages int
+
+	mu       sync.Mutex
+	messages []HARWebSocketMessage
+	dropped  int
+	closed   bool
+	onClose  func(messages []HARWebSocketMessage, dropped int)
+
+	send    *wsFrameParser
+	receive *wsFrameParser
+	// handshake is the response head written to a hijacked connection
+	handshake *http.Response
+}
+
+func (l *Logger) newWSRecorder() *wsRecorder {
+	rec := &wsRecorder{
+		maxSize:     l.wsMessageSize,
+		maxMessages: l.wsMaxMessages,
+	}
+	rec.send = &wsFrameParser{rec: rec, typ: WebSocketSend}
+	rec.receive = &wsFrameParser{rec: rec, typ: WebSocketReceive}
+	return rec
+}
+
+func (r *wsRecorder) add(typ string, opcode byte, data []byte) {
+	msg := HARWebSocketMessage{
+		Type:   typ,
+		Time:   float64(time.Now().UnixMicro()) / 1e6,
+		Opcode: int(opcode),
+	}
+	if opcode == WebSocketOpText {
+		msg.Data = string(trimIncompleteRune(data))
+	} else if len(data) > 0 {
+		msg.Data = base64.StdEncoding.EncodeToString(data)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.messages) < r.maxMessages {
+		r.messages = append(r.messages, msg)
+	} else {
+		r.dropped++
+	}
+}
+
+// whenClosed calls fn with the recorded messages and the number of messages
+// beyond the limit once the connection is closed, or immediately if it
+// already is
+func (r *wsRecorder) whenClosed(fn func(messages []HARWebSocketMessage, dropped int)) {
+	r.mu.Lock()
+	if !r.closed {
+		r.onClose = fn
+		r.mu.Unlock()
+		return
+	}
+	messages, dropped := r.messages, r.dropped
+	r.mu.Unlock()
+	fn(messages, dropped)
+}
+
+func (r *wsRecorder) close() {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return
+	}
+	r.closed = true
+	fn, messages, dropped := r.onClose, r.messages, r.dropped
+	r.mu.Unlock()
+
+	if fn != nil {
+		fn(messages, dropped)
+	}
+}
+
+// serverConn wraps conn hijacked from Middleware, whose buffered bytes are
+// read first. If readHead is true, the handshake response is expected to be
+// written to conn before the frames.
+func (r *wsRecorder) serverConn(conn net.Conn, buffered *bufio.Reader, readHead bool) *wsServerConn {
+	if readHead {
+		r.receive.readHead = true
+		r.receive.onHead = func(head []byte) {
+			resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(head)), nil)
+			if err != nil {
+				return
+			}
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.handshake = resp
+		}
+	}
+	return &wsServerConn{Conn: conn, buffered: buffered, rec: r}
+}
+
+// handshakeResponse returns the response head read from the connection, if
+// any
+func (r *wsRecorder) handshakeResponse() *http.Response {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.handshake
+}
+
+// trimIncompleteRune removes a UTF-8 sequence cut by truncation from the end
+// of data
+func trimIncompleteRune(data []byte) []byte {
+	for i := 0; i < utf8.UTFMax && len(data) > 0; i++ {
+		if r, size := utf8.DecodeLastRune(data); r != utf8.RuneError || size != 1 {
+			break
+		}
+		data = data[:len(data)-1]
+	}
+	return data
+}
+
+// wsFrameParser reassembles the messages of one direction of a WebSocket
+// connection from the bytes passing through it. Payloads beyond the size
+// limit are counted but not kept.
+type wsFrameParser struct {
+	rec *wsRecorder
+	typ string
+
+	// head collects an HTTP response head preceding the frames, which is
+	// written to a hijacked connection by the handler itself
+	readHead bool
+	head     []byte
+	onHead   func(head []byte)
+
+	header    []byte
+	inFrame   bool
+	fin       bool
+	opcode    byte
+	masked    bool
+	mask      [4]byte
+	remaining uint64
+	offset    uint64
+
+	msgOpcode byte
+	data      []byte
+	control   []byte
+	broken    bool
+}
+
+func (p *wsFrameParser) feed(b []byte) {
+	for len(b) > 0 && !p.broken {
+		switch {
+		case p.readHead:
+			b = p.feedHead(b)
+		case !p.inFrame:
+			p.header = append(p.header, b[0])
+			b = b[1:]
+			p.parseHeader()
+		default:
+			n := uint64(len(b))
+			if n > p.remaining {
+				n = p.remaining
+			}
+			p.feedPayload(b[:n])
+			b = b[n:]
+			p.remaining -= n
+			if p.remaining == 0 {
+				p.endFrame()
+			}
+		}
+	}
+}
+
+func (p *wsFrameParser) feedHead(b []byte) []byte {
+	start := len(p.head)
+	p.head = append(p.head, b...)
+	// The terminator may span the previous chunk
+	from := max(start-3, 0)
+	i := bytes.Index(p.head[from:], []byte("\r\n\r\n"))
+	if i < 0 {
+		if len(p.head) > maxHandshakeSize {
+			p.broken = true
+		}
+		return nil
+	}
+
+	end := from + i + 4
+	rest := b[end-start:]
+	p.head = p.head[:end]
+	p.readHead = false
+	if p.onHead != nil {
+		p.onHead(p.head)
+	}
+	return rest
+}
+
+func (p *wsFrameParser) parseHeader() {
+	h := p.header
+	if len(h) < 2 {
+		return
+	}
+	size := 2
+	switch h[1] & 0x7f {
+	case 126:
+		size += 2
+	case 127:
+		size += 8
+	}
+	masked := h[1]&0x80 != 0
+	if masked {
+		size += 4
+	}
+	if len(h) < size {
+		return
+	}
+
+	p.fin = h[0]&0x80 != 0
+	p.opcode = h[0] & 0x0f
+	p.masked = masked
+	pos := 2
+	switch n := h[1] & 0x7f; n {
+	case 126:
+		p.remaining = uint64(h[2])<<8 | uint64(h[3])
+		pos += 2
+	case 127:
+		p.remaining = 0
+		for _, c := range h[2:10] {
+			p.remaining = p.remaining<<8 | uint64(c)
+		}
+		pos += 8
+	default:
+		p.remaining = uint64(n)
+	}
+	if masked {
+		copy(p.mask[:], h[pos:pos+4])
+	}
+
+	p.header = p.header[:0]
+	p.inFrame = true
+	p.offset = 0
+	if p.remaining == 0 {
+		p.endFrame()
+	}
+}
+
+func (p *wsFrameParser) feedPayload(chunk []byte) {
+	buf := &p.data
+	if p.opcode >= WebSocketOpClose {
+		buf = &p.control
+	}
+	for i, c := range chunk {
+		if len(*buf) >= p.rec.maxSize {
+			break
+		}
+		if p.masked {
+			c ^= p.mask[(p.offset+uint64(i))%4]
+		}
+		*buf = append(*buf, c)
+	}
+	p.offset += uint64(len(chunk))
+}
+
+func (p *wsFrameParser) endFrame() {
+	p.inFrame = false
+	if p.opcode >= WebSocketOpClose {
+		p.rec.add(p.typ, p.opcode, p.control)
+		p.control = nil
+		return
+	}
+
+	// Continuation frames have the opcode 0 and extend the current message
+	if p.opcode != 0 {
+		p.msgOpcode = p.opcode
+	}
+	if p.fin {
+		p.rec.add(p.typ, p.msgOpcode, p.data)
+		p.data = nil
+	}
+}
+
+// wsServerConn records the frames passing through a connection hijacked
+// from Middleware. Bytes read come from the client and bytes written go to
+// it.
+type wsServerConn struct {
+	net.Conn
+	buffered *bufio.Reader
+	rec      *wsRecorder
+	once     sync.Once
+}
+
+func (c *wsServerConn) Read(b []byte) (int, error) {
+	var n int
+	var err error
+	if c.buffered != nil && c.buffered.Buffered() > 0 {
+		n, err = c.buffered.Read(b)
+	} else {
+		n, err = c.Conn.Read(b)
+	}
+	c.rec.send.feed(b[:n])
+	return n, err
+}
+
+func (c *wsServerConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	c.rec.receive.feed(b[:n])
+	return n, err
+}
+
+func (c *wsServerConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(c.rec.close)
+	return err
+}
+
+// wsClientBody records the frames passing through the body of a 101
+// response returned by RoundTrip. Bytes written go to the server and bytes
+// read come from it.
+type wsClientBody struct {
+	io.ReadWriteCloser
+	rec  *wsRecorder
+	once sync.Once
+}
+
+func (b *wsClientBody) Read(p []byte) (int, error) {
+	n, err := b.ReadWriteCloser.Read(p)
+	b.rec.receive.feed(p[:n])
+	return n, err
+}
+
+func (b *wsClientBody) Write(p []byte) (int, error) {
+	n, err := b.ReadWriteCloser.Write(p)
+	b.rec.send.feed(p[:n])
+	return n, err
+}
+
+func (b *wsClientBody) Close() error {
+	err := b.ReadWriteCloser.Close()
+	b.once.Do(b.rec.close)
+	return err
+}
+
+// recordWebSocket replaces the body of resp, a 101 response to the
+// WebSocket handshake req, to record the frames exchanged through it. done
+// is called with the messages and the number of messages beyond the limit
+// once the connection is closed. It reports false, leaving resp untouched,
+// if resp is not such a response.
+func (l *Logger) recordWebSocket(req *http.Request, resp *http.Response, done func(messages []HARWebSocketMessage, dropped int)) bool {
+	if resp.StatusCode != http.StatusSwitchingProtocols || !isWebSocketUpgrade(req) {
+		return false
+	}
+	rwc, ok := resp.Body.(io.ReadWriteCloser)
+	if !ok || resp.Header.Get("Sec-WebSocket-Extensions") != "" {
+		return false
+	}
+
+	rec := l.newWSRecorder()
+	resp.Body = &wsClientBody{ReadWriteCloser: rwc, rec: rec}
+	rec.whenClosed(done)
+	return true
+}
+
+// withoutWebSocketExtensions returns req without offers of WebSocket
+// extensions, which would compress the frames beyond recognition, unless
+// enabled by WithWebSocketExtensions
+func (l *Logger) withoutWebSocketExtensions(req *http.Request) *http.Request {
+	if l.wsExtensions || !isWebSocketUpgrade(req) || req.Header.Get("Sec-WebSocket-Extensions") == "" {
+		return req
+	}
+	req = req.Clone(req.Context())
+	req.Header.Del("Sec-WebSocket-Extensions")
+	return req
+}
+
+// negotiatedWebSocketExtension reports whether the handshake response with
+// headers accepted a WebSocket extension, whose frames cannot be read
+func negotiatedWebSocketExtension(headers []HARHeader) bool {
+	for _, h := range headers {
+		if strings.EqualFold(h.Name, "Sec-WebSocket-Extensions") && h.Value != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeWebSocketMessages converts recorded messages, decoding the base64
+// data of non-text messages
+func decodeWebSocketMessages(messages []HARWebSocketMessage) ([]WebSocketMessage, error) {
+	if len(messages) == 0 {
+		return nil, nil
+	}
+	decoded := make([]WebSocketMessage, 0, len(messages))
+	for i, msg := range messages {
+		data := []byte(msg.Data)
+		if msg.Opcode != WebSocketOpText {
+			var err error
+			if data, err = base64.StdEncoding.DecodeString(msg.Data); err != nil {
+				return nil, fmt.Errorf("invalid data of WebSocket message %d: %w", i, err)
+			}
+		}
+		sec := int64(msg.Time)
+		decoded = append(decoded, WebSocketMessage{
+			Type:   msg.Type,
+			Time:   time.Unix(sec, int64((msg.Time-float64(sec))*1e9)),
+			Opcode: msg.Opcode,
+			Data:   data,
+		})
+	}
+	return decoded, nil
+}
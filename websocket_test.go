@@ -0,0 +1,419 @@
+package harlog
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// wsEchoHandler completes a WebSocket handshake on the hijacked connection,
+// accepting permessage-deflate if offered, and echoes every frame until the
+// client closes the connection
+func wsEchoHandler(t *testing.T, extensions *string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if extensions != nil {
+			*extensions = r.Header.Get("Sec-WebSocket-Extensions")
+		}
+		conn, brw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+
+		sum := sha1.Sum([]byte(r.Header.Get("Sec-WebSocket-Key") + "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"))
+		accepted := ""
+		if strings.Contains(r.Header.Get("Sec-WebSocket-Extensions"), "permessage-deflate") {
+			accepted = "Sec-WebSocket-Extensions: permessage-deflate\r\n"
+		}
+		brw.WriteString("HTTP/1.1 101 Switching Protocols\r\n" +
+			"Upgrade: websocket\r\n" +
+			"Connection: Upgrade\r\n" +
+			accepted +
+			"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(sum[:]) + "\r\n\r\n")
+		brw.Flush()
+
+		for {
+			fin, opcode, payload, err := readWSFrame(brw.Reader)
+			if err != nil {
+				return
+			}
+			writeWSFrame(brw.Writer, fin, opcode, payload, false)
+			brw.Flush()
+			if opcode == WebSocketOpClose {
+				return
+			}
+		}
+	})
+}
+
+func writeWSFrame(w io.Writer, fin bool, opcode byte, payload []byte, mask bool) {
+	var hdr []byte
+	b0 := opcode
+	if fin {
+		b0 |= 0x80
+	}
+	b1 := byte(0)
+	if mask {
+		b1 = 0x80
+	}
+	switch n := len(payload); {
+	case n < 126:
+		hdr = []byte{b0, b1 | byte(n)}
+	case n <= 0xffff:
+		hdr = binary.BigEndian.AppendUint16([]byte{b0, b1 | 126}, uint16(n))
+	default:
+		hdr = binary.BigEndian.AppendUint64([]byte{b0, b1 | 127}, uint64(n))
+	}
+
+	data := payload
+	if mask {
+		key := []byte{0x12, 0x34, 0x56, 0x78}
+		hdr = append(hdr, key...)
+		data = make([]byte, len(payload))
+		for i, c := range payload {
+			data[i] = c ^ key[i%4]
+		}
+	}
+	_, _ = w.Write(append(hdr, data...))
+}
+
+func readWSFrame(r io.Reader) (bool, byte, []byte, error) {
+	var hdr [2]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return false, 0, nil, err
+	}
+	n := uint64(hdr[1] & 0x7f)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		n = binary.BigEndian.Uint64(ext[:])
+	}
+	var key [4]byte
+	masked := hdr[1]&0x80 != 0
+	if masked {
+		if _, err := io.ReadFull(r, key[:]); err != nil {
+			return false, 0, nil, err
+		}
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return false, 0, nil, err
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= key[i%4]
+		}
+	}
+	return hdr[0]&0x80 != 0, hdr[0] & 0x0f, payload, nil
+}
+
+// dialWebSocket performs a WebSocket handshake with client and returns the
+// connection
+func dialWebSocket(t *testing.T, client *http.Client, rawURL string) io.ReadWriteCloser {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	req.Header.Set("Sec-WebSocket-Extensions", "permessage-deflate; client_max_window_bits")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("expected status 101, got %d", resp.StatusCode)
+	}
+	conn, ok := resp.Body.(io.ReadWriteCloser)
+	if !ok {
+		t.Fatal("response body is not writable")
+	}
+	return conn
+}
+
+// exchangeWebSocket sends frames and waits for their echoes
+func exchangeWebSocket(t *testing.T, conn io.ReadWriteCloser) {
+	t.Helper()
+	br := bufio.NewReader(conn)
+	expectEcho := func(opcode byte, payload []byte) {
+		t.Helper()
+		_, gotOp, got, err := readWSFrame(br)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if gotOp != opcode || !bytes.Equal(got, payload) {
+			t.Fatalf("expected echo of opcode %d %q, got opcode %d %q", opcode, payload, gotOp, got)
+		}
+	}
+
+	writeWSFrame(conn, true, WebSocketOpText, []byte("hello"), true)
+	expectEcho(WebSocketOpText, []byte("hello"))
+
+	// A fragmented message
+	writeWSFrame(conn, false, WebSocketOpText, []byte("wor"), true)
+	expectEcho(WebSocketOpText, []byte("wor"))
+	writeWSFrame(conn, true, 0, []byte("ld"), true)
+	expectEcho(0, []byte("ld"))
+
+	writeWSFrame(conn, true, WebSocketOpBinary, []byte{0x00, 0x01, 0xfe, 0xff}, true)
+	expectEcho(WebSocketOpBinary, []byte{0x00, 0x01, 0xfe, 0xff})
+
+	large := bytes.Repeat([]byte("x"), 300)
+	writeWSFrame(conn, true, WebSocketOpText, large, true)
+	expectEcho(WebSocketOpText, large)
+
+	writeWSFrame(conn, true, WebSocketOpClose, []byte{0x03, 0xe8}, true)
+	expectEcho(WebSocketOpClose, []byte{0x03, 0xe8})
+	if err := conn.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func waitWebSocketEntry(t *testing.T, rec *Recorder) HAREntry {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	entry, err := rec.WaitFor(ctx, func(entry *HAREntry) bool {
+		return len(entry.WebSocketMessages) > 0
+	})
+	if err != nil {
+		t.Fatalf("no WebSocket entry: %v", err)
+	}
+	return entry
+}
+
+func checkWebSocketMessages(t *testing.T, side string, messages []HARWebSocketMessage) {
+	t.Helper()
+	binaryData := base64.StdEncoding.EncodeToString([]byte{0x00, 0x01, 0xfe, 0xff})
+	closeData := base64.StdEncoding.EncodeToString([]byte{0x03, 0xe8})
+	want := []HARWebSocketMessage{
+		{Type: WebSocketSend, Opcode: WebSocketOpText, Data: "hello"},
+		{Type: WebSocketReceive, Opcode: WebSocketOpText, Data: "hello"},
+		{Type: WebSocketSend, Opcode: WebSocketOpText, Data: "world"},
+		{Type: WebSocketReceive, Opcode: WebSocketOpText, Data: "world"},
+		{Type: WebSocketSend, Opcode: WebSocketOpBinary, Data: binaryData},
+		{Type: WebSocketReceive, Opcode: WebSocketOpBinary, Data: binaryData},
+		{Type: WebSocketSend, Opcode: WebSocketOpText, Data: strings.Repeat("x", 300)},
+		{Type: WebSocketReceive, Opcode: WebSocketOpText, Data: strings.Repeat("x", 300)},
+		{Type: WebSocketSend, Opcode: WebSocketOpClose, Data: closeData},
+		{Type: WebSocketReceive, Opcode: WebSocketOpClose, Data: closeData},
+	}
+	if len(messages) != len(want) {
+		t.Fatalf("%s: expected %d messages, got %d: %+v", side, len(want), len(messages), messages)
+	}
+	for i, msg := range messages {
+		if msg.Type != want[i].Type || msg.Opcode != want[i].Opcode || msg.Data != want[i].Data {
+			t.Errorf("%s: message %d: expected %+v, got %+v", side, i, want[i], msg)
+		}
+		if msg.Time < float64(time.Now().Add(-time.Minute).Unix()) {
+			t.Errorf("%s: message %d: unexpected time %f", side, i, msg.Time)
+		}
+	}
+}
+
+func TestWebSocket(t *testing.T) {
+	t.Parallel()
+
+	serverRec := NewRecorder()
+	var extensions string
+	server := httptest.NewServer(New(WithFileOutput(false), WithSink(serverRec)).Middleware(wsEchoHandler(t, &extensions)))
+	defer server.Close()
+
+	clientRec := NewRecorder()
+	client := &http.Client{Transport: New(WithFileOutput(false), WithSink(clientRec))}
+
+	conn := dialWebSocket(t, client, server.URL+"/ws")
+	exchangeWebSocket(t, conn)
+
+	if extensions != "" {
+		t.Errorf("expected extension offers to be removed, got %q", extensions)
+	}
+
+	serverEntry := waitWebSocketEntry(t, serverRec)
+	clientEntry := waitWebSocketEntry(t, clientRec)
+	checkWebSocketMessages(t, "server", serverEntry.WebSocketMessages)
+	checkWebSocketMessages(t, "client", clientEntry.WebSocketMessages)
+
+	for side, entry := range map[string]HAREntry{"server": serverEntry, "client": clientEntry} {
+		if entry.Response.Status != http.StatusSwitchingProtocols {
+			t.Errorf("%s: expected status 101, got %d", side, entry.Response.Status)
+		}
+		accepted := false
+		for _, h := range entry.Response.Headers {
+			accepted = accepted || h.Name == "Sec-Websocket-Accept"
+		}
+		if !accepted {
+			t.Errorf("%s: expected Sec-WebSocket-Accept in response: %+v", side, entry.Response.Headers)
+		}
+	}
+
+	msg, err := ConvertEntry(&clientEntry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msg.WebSocketMessages) != 10 {
+		t.Fatalf("expected 10 parsed messages, got %d", len(msg.WebSocketMessages))
+	}
+	if got := msg.WebSocketMessages[4].Data; !bytes.Equal(got, []byte{0x00, 0x01, 0xfe, 0xff}) {
+		t.Errorf("expected decoded binary data, got %v", got)
+	}
+	if got := msg.WebSocketMessages[0].Data; string(got) != "hello" {
+		t.Errorf("expected text data hello, got %q", got)
+	}
+}
+
+func TestWebSocket_Limits(t *testing.T) {
+	t.Parallel()
+
+	limits := []Option{
+		WithFileOutput(false),
+		WithWebSocketMessageSize(4),
+		WithWebSocketMaxMessages(3),
+	}
+	serverRec, clientRec := NewRecorder(), NewRecorder()
+	server := httptest.NewServer(New(append(limits, WithSink(serverRec))...).Middleware(wsEchoHandler(t, nil)))
+	defer server.Close()
+
+	client := &http.Client{Transport: New(append(limits, WithSink(clientRec))...)}
+	conn := dialWebSocket(t, client, server.URL)
+	exchangeWebSocket(t, conn)
+
+	for side, rec := range map[string]*Recorder{"server": serverRec, "client": clientRec} {
+		entry := waitWebSocketEntry(t, rec)
+		messages := entry.WebSocketMessages
+		if len(messages) != 3 {
+			t.Fatalf("%s: expected 3 messages, got %d", side, len(messages))
+		}
+		for i, want := range []string{"hell", "hell", "worl"} {
+			if messages[i].Data != want {
+				t.Errorf("%s: message %d: expected %q, got %q", side, i, want, messages[i].Data)
+			}
+		}
+		if entry.WebSocketMessagesDropped != 7 {
+			t.Errorf("%s: expected 7 dropped messages, got %d", side, entry.WebSocketMessagesDropped)
+		}
+	}
+}
+
+func TestWebSocket_Extensions(t *testing.T) {
+	t.Parallel()
+
+	// Requests that are not captured keep their offers
+	var extensions string
+	skipped := httptest.NewServer(New(WithFileOutput(false), WithDefaultCapture(false)).Middleware(wsEchoHandler(t, &extensions)))
+	defer skipped.Close()
+	exchangeWebSocket(t, dialWebSocket(t, skipped.Client(), skipped.URL))
+	if !strings.Contains(extensions, "permessage-deflate") {
+		t.Errorf("expected extension offers to be kept, got %q", extensions)
+	}
+
+	// Negotiated extensions leave only the handshake to record
+	serverRec, clientRec := NewRecorder(), NewRecorder()
+	server := httptest.NewServer(New(WithFileOutput(false), WithSink(serverRec), WithWebSocketExtensions(true)).
+		Middleware(wsEchoHandler(t, &extensions)))
+	defer server.Close()
+	client := &http.Client{Transport: New(WithFileOutput(false), WithSink(clientRec), WithWebSocketExtensions(true))}
+	exchangeWebSocket(t, dialWebSocket(t, client, server.URL+"/ws"))
+
+	for side, rec := range map[string]*Recorder{"server": serverRec, "client": clientRec} {
+		entry := waitEntry(t, rec, http.MethodGet, "/ws")
+		if !negotiatedWebSocketExtension(entry.Response.Headers) {
+			t.Errorf("%s: expected negotiated extension, got %+v", side, entry.Response.Headers)
+		}
+		if len(entry.WebSocketMessages) != 0 {
+			t.Errorf("%s: expected no messages, got %d", side, len(entry.WebSocketMessages))
+		}
+	}
+}
+
+func TestWebSocket_OptIn(t *testing.T) {
+	t.Parallel()
+
+	// The handler opts in after the offers have reached it, and declines them
+	rec := NewRecorder()
+	echo := wsEchoHandler(t, nil)
+	server := httptest.NewServer(New(WithFileOutput(false), WithSink(rec), WithDefaultCapture(false)).
+		Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			Capture(r.Context())
+			r.Header.Del("Sec-WebSocket-Extensions")
+			echo.ServeHTTP(w, r)
+		})))
+	defer server.Close()
+	exchangeWebSocket(t, dialWebSocket(t, server.Client(), server.URL+"/ws"))
+
+	entry := waitEntry(t, rec, http.MethodGet, "/ws")
+	checkWebSocketMessages(t, "server", entry.WebSocketMessages)
+}
+
+func TestWebSocket_ReverseProxy(t *testing.T) {
+	t.Parallel()
+
+	backend := httptest.NewServer(wsEchoHandler(t, nil))
+	defer backend.Close()
+
+	target, _ := url.Parse(backend.URL)
+	rec := NewRecorder()
+	front := httptest.NewServer(NewReverseProxy(target, WithFileOutput(false), WithSink(rec)))
+	defer front.Close()
+
+	conn := dialWebSocket(t, front.Client(), front.URL)
+	exchangeWebSocket(t, conn)
+
+	for _, role := range []string{LinkInbound, LinkOutbound} {
+		entry := waitLinked(t, rec, role)
+		checkWebSocketMessages(t, role, entry.WebSocketMessages)
+	}
+}
+
+func TestWebSocketFrameParser(t *testing.T) {
+	rec := &wsRecorder{maxSize: 4, maxMessages: 10}
+	p := &wsFrameParser{rec: rec, typ: WebSocketReceive}
+
+	var buf bytes.Buffer
+	writeWSFrame(&buf, true, WebSocketOpText, []byte("あいう"), true)
+	writeWSFrame(&buf, true, WebSocketOpBinary, bytes.Repeat([]byte{0xff}, 70000), false)
+	writeWSFrame(&buf, true, WebSocketOpPing, nil, false)
+
+	// Feed one byte at a time to cover frames split across reads
+	for _, c := range buf.Bytes() {
+		p.feed([]byte{c})
+	}
+
+	want := []HARWebSocketMessage{
+		{Type: WebSocketReceive, Opcode: WebSocketOpText, Data: "あ"},
+		{Type: WebSocketReceive, Opcode: WebSocketOpBinary, Data: base64.StdEncoding.EncodeToString([]byte{0xff, 0xff, 0xff, 0xff})},
+		{Type: WebSocketReceive, Opcode: WebSocketOpPing, Data: ""},
+	}
+	if len(rec.messages) != len(want) {
+		t.Fatalf("expected %d messages, got %d: %+v", len(want), len(rec.messages), rec.messages)
+	}
+	for i, msg := range rec.messages {
+		if msg.Type != want[i].Type || msg.Opcode != want[i].Opcode || msg.Data != want[i].Data {
+			t.Errorf("message %d: expected %+v, got %+v", i, want[i], msg)
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package harlog
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -9,24 +11,173 @@ import (
 	"strings"
 )
 
-func (l *Logger) saveHAR(req *http.Request, entry *HAREntry) error {
+// Sink receives every HAR entry recorded by a Logger
+type Sink interface {
+	WriteEntry(req *http.Request, entry *HAREntry) error
+}
+
+// Processor modifies an entry recorded for req, e.g. to decode its bodies
+type Processor func(req *http.Request, entry *HAREntry)
+
+// WriteEntry saves entry, produced outside of the Logger for req, like a
+// recorded one. The per-request settings of the context of req apply, so the
+// entry is dropped if capture is disabled. Errors are logged, and nil is
+// returned, so that a Logger can be used as a Sink.
+func (l *Logger) WriteEntry(req *http.Request, entry *HAREntry) error {
+	ctrl := controlFrom(req.Context())
+	if !l.shouldCapture(ctrl) {
+		return nil
+	}
+	l.applyControl(ctrl, entry)
+	l.writeEntry(req, entry)
+	return nil
+}
+
+// process runs the processors on entry
+func (l *Logger) process(req *http.Request, entry *HAREntry) {
+	for _, p := range l.processors {
+		p(req, entry)
+	}
+}
+
+// writeEntry delivers the entry to the output file and all sinks
+func (l *Logger) writeEntry(req *http.Request, entry *HAREntry) {
+	l.writeEntryTo(req, entry, "")
+}
+
+// writeEntryTo is writeEntry saving the file to path, as returned by
+// checkpointEntry, unless it is empty
+func (l *Logger) writeEntryTo(req *http.Request, entry *HAREntry, path string) {
+	l.process(req, entry)
+	if l.fileOutput {
+		var err error
+		if hop := redirectHopFrom(req.Context()); hop != nil && l.groupRedirects {
+			_, err = l.saveRedirectHop(req, entry, hop)
+		} else {
+			_, err = l.saveHAR(req, []HAREntry{*entry}, path)
+		}
+		if err != nil {
+			l.logger.Error("failed to save HAR",
+				"error", err,
+				"path", req.URL.Path,
+				"method", req.Method,
+				"host", req.Host,
+			)
+		}
+	}
+
+	for _, sink := range l.sinks {
+		if err := sink.WriteEntry(req, entry); err != nil {
+			l.logger.Error("failed to write HAR entry to sink",
+				"error", err,
+				"path", req.URL.Path,
+				"method", req.Method,
+				"host", req.Host,
+			)
+		}
+	}
+}
+
+// checkpointEntry saves an incomplete entry to a HAR file without passing it
+// to sinks. The file is saved to path unless it is empty. The path of the
+// file is returned so that later checkpoints and the complete entry
+// overwrite it.
+func (l *Logger) checkpointEntry(req *http.Request, entry *HAREntry, path string) string {
+	if !l.fileOutput {
+		return path
+	}
+	// The snapshot shares the request body with the complete entry, which is
+	// processed again
+	if entry.Request.PostData != nil {
+		postData := *entry.Request.PostData
+		entry.Request.PostData = &postData
+	}
+	l.process(req, entry)
+	saved, err := l.saveHAR(req, []HAREntry{*entry}, path)
+	if err != nil {
+		l.logger.Error("failed to save HAR checkpoint",
+			"error", err,
+			"path", req.URL.Path,
+			"method", req.Method,
+			"host", req.Host,
+		)
+		return path
+	}
+	return saved
+}
+
+// saveHAR writes entries as a HAR file to path, or to the file named for req
+// if path is empty, and returns the path of the file
+func (l *Logger) saveHAR(req *http.Request, entries []HAREntry, path string) (string, error) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
+	if path == "" {
+		if op := entries[0].GraphQL; op != nil {
+			req = req.WithContext(context.WithValue(req.Context(), graphQLCtxKey{}, op))
+		}
+		var err error
+		if path, err = l.harFilePath(req); err != nil {
+			return "", err
+		}
+	}
+
+	har := HAR{
+		Log: HARLog{
+			Version: "1.2",
+			Creator: HARCreator{
+				Name:    "harlog",
+				Version: "1.0",
+			},
+			Entries: entries,
+		},
+	}
+
+	// Encode before creating the file so that no partial file is left on error
+	var buf bytes.Buffer
+	w, err := compressWriter(&buf, compressionFor(path, l.compression), l.compressionLevel)
+	if err != nil {
+		return "", err
+	}
+	if err := json.NewEncoder(w).Encode(har); err != nil {
+		return "", fmt.Errorf("failed to encode HAR: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return "", fmt.Errorf("failed to compress HAR: %w", err)
+	}
+
+	if err := os.WriteFile(path, buf.Bytes(), 0600); err != nil {
+		return "", fmt.Errorf("failed to create file: %w", err)
+	}
+	if l.written != nil {
+		l.written[path] = true
+	}
+	return path, nil
+}
+
+// harFilePath returns the absolute path of the HAR file for req, with the
+// extension of the compression
+func (l *Logger) harFilePath(req *http.Request) (string, error) {
 	// Create output directory if it doesn't exist
 	if err := os.MkdirAll(l.outputDir, 0750); err != nil {
-		return fmt.Errorf("failed to create output directory: %w", err)
+		return "", fmt.Errorf("failed to create output directory: %w", err)
 	}
 
 	// Get filename and validate it's within the output directory
 	filename := l.fileNameFn(req)
+	if name := fileNameFrom(req.Context()); name != "" {
+		filename = name
+		if !filepath.IsAbs(filename) {
+			filename = filepath.Join(l.outputDir, filename)
+		}
+	}
 	absOutputDir, err := filepath.Abs(l.outputDir)
 	if err != nil {
-		return fmt.Errorf("failed to get absolute path of output directory: %w", err)
+		return "", fmt.Errorf("failed to get absolute path of output directory: %w", err)
 	}
 	absFilename, err := filepath.Abs(filename)
 	if err != nil {
-		return fmt.Errorf("failed to get absolute path of file: %w", err)
+		return "", fmt.Errorf("failed to get absolute path of file: %w", err)
 	}
 
 	// Clean paths and ensure they are in canonical form
@@ -36,32 +187,14 @@ func (l *Logger) saveHAR(req *http.Request, entry *HAREntry) error {
 	// Check if the file path is within the output directory
 	rel, err := filepath.Rel(absOutputDir, absFilename)
 	if err != nil {
-		return fmt.Errorf("failed to get relative path: %w", err)
+		return "", fmt.Errorf("failed to get relative path: %w", err)
 	}
 	if strings.HasPrefix(rel, ".."+string(filepath.Separator)) || rel == ".." {
-		return fmt.Errorf("file path %s is outside of output directory %s", filename, l.outputDir)
+		return "", fmt.Errorf("file path %s is outside of output directory %s", filename, l.outputDir)
 	}
 
-	har := HAR{
-		Log: HARLog{
-			Version: "1.2",
-			Creator: HARCreator{
-				Name:    "harlog",
-				Version: "1.0",
-			},
-			Entries: []HAREntry{*entry},
-		},
+	if ext := compressionFor(absFilename, l.compression).extension(); !strings.HasSuffix(absFilename, ext) {
+		absFilename += ext
 	}
-
-	file, err := os.OpenFile(absFilename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
-	if err != nil {
-		return fmt.Errorf("failed to create file: %w", err)
-	}
-	defer file.Close()
-
-	if err := json.NewEncoder(file).Encode(har); err != nil {
-		return fmt.Errorf("failed to encode HAR: %w", err)
-	}
-
-	return nil
+	return absFilename, nil
 }